@@ -0,0 +1,290 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var (
+	errTypeNotFound = errors.New("type not found")
+	errNotAStruct   = errors.New("not a struct type")
+	errGeneric      = errors.New("generic types aren't supported")
+	errNoPackage    = errors.New("no Go files")
+)
+
+type structInfo struct {
+	Name   string
+	Fields []fieldInfo
+}
+
+type fieldInfo struct {
+	Name string
+	Type string
+	// Compare is "cmp" for fields compared with cmp.Compare, "method" for
+	// fields that have a Compare method, and empty for fields that can't be
+	// compared.
+	Compare string
+	Pointer bool
+}
+
+type fileInfo struct {
+	Package string
+	Imports []string
+	Structs []structInfo
+	UsesCmp bool
+}
+
+var orderedTypes = []string{
+	"string",
+	"int", "int8", "int16", "int32", "int64",
+	"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+	"float32", "float64", "byte", "rune",
+}
+
+// generate returns the source of a file that registers accessors for the
+// given structs, declared in the package in dir.
+func generate(dir string, typeNames []string, output string) ([]byte, error) {
+	info, err := parsePackage(dir, typeNames, output)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := fileTemplate.Execute(&buf, info); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func parsePackage(dir string, typeNames []string, output string) (*fileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	info := &fileInfo{}
+	found := make(map[string]structInfo, len(typeNames))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".go" ||
+			strings.HasSuffix(name, "_test.go") || name == output {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		info.Package = file.Name.Name
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.TypeSpec)
+				if !slices.Contains(typeNames, spec.Name.Name) {
+					continue
+				}
+				s, imports, err := parseStruct(spec, file.Imports)
+				if err != nil {
+					return nil, err
+				}
+				found[s.Name] = s
+				for _, imp := range imports {
+					if !slices.Contains(info.Imports, imp) {
+						info.Imports = append(info.Imports, imp)
+					}
+				}
+			}
+		}
+	}
+	if info.Package == "" {
+		return nil, fmt.Errorf("%w in %s", errNoPackage, dir)
+	}
+	for _, name := range typeNames {
+		s, ok := found[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errTypeNotFound, name)
+		}
+		info.Structs = append(info.Structs, s)
+		for _, f := range s.Fields {
+			info.UsesCmp = info.UsesCmp || f.Compare == "cmp"
+		}
+	}
+	slices.Sort(info.Imports)
+	return info, nil
+}
+
+// parseStruct returns the fields of a struct type that get accessors, and the
+// imports their types need.
+func parseStruct(spec *ast.TypeSpec, imports []*ast.ImportSpec) (structInfo, []string, error) {
+	s := structInfo{Name: spec.Name.Name}
+	if spec.TypeParams != nil {
+		return s, nil, fmt.Errorf("%w: %s", errGeneric, s.Name)
+	}
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return s, nil, fmt.Errorf("%w: %s", errNotAStruct, s.Name)
+	}
+	var needed []string
+	for _, field := range st.Fields.List {
+		f := fieldInfo{Type: types.ExprString(field.Type)}
+		switch typ := field.Type.(type) {
+		case *ast.Ident:
+			if !slices.Contains(orderedTypes, typ.Name) && typ.Name != "bool" {
+				// Named types of the package are left to reflection, since
+				// their underlying type isn't known without type checking.
+				continue
+			}
+			if typ.Name != "bool" {
+				f.Compare = "cmp"
+			}
+		case *ast.SelectorExpr:
+			pkg, ok := typ.X.(*ast.Ident)
+			if !ok || typ.Sel.Name != "Time" || importPath(imports, pkg.Name) != "time" {
+				continue
+			}
+			f.Compare = "method"
+		case *ast.StarExpr:
+			f.Pointer = true
+		default:
+			continue
+		}
+		imps, ok := typeImports(field.Type, imports)
+		if !ok {
+			continue
+		}
+		for _, name := range field.Names {
+			if name.IsExported() {
+				f.Name = name.Name
+				s.Fields = append(s.Fields, f)
+				needed = append(needed, imps...)
+			}
+		}
+	}
+	return s, needed, nil
+}
+
+// typeImports returns the import specs (in source form) that a type
+// expression refers to.
+func typeImports(expr ast.Expr, imports []*ast.ImportSpec) ([]string, bool) {
+	var result []string
+	ok := true
+	ast.Inspect(expr, func(node ast.Node) bool {
+		sel, isSel := node.(*ast.SelectorExpr)
+		if !isSel {
+			return true
+		}
+		pkg, isIdent := sel.X.(*ast.Ident)
+		if !isIdent {
+			return true
+		}
+		for _, imp := range imports {
+			if importName(imp) == pkg.Name {
+				if imp.Name != nil {
+					result = append(result, imp.Name.Name+" "+imp.Path.Value)
+				} else {
+					result = append(result, imp.Path.Value)
+				}
+				return false
+			}
+		}
+		ok = false
+		return false
+	})
+	return result, ok
+}
+
+func importPath(imports []*ast.ImportSpec, name string) string {
+	for _, imp := range imports {
+		if importName(imp) == name {
+			p, _ := strconv.Unquote(imp.Path.Value)
+			return p
+		}
+	}
+	return ""
+}
+
+func importName(imp *ast.ImportSpec) string {
+	if imp.Name != nil {
+		return imp.Name.Name
+	}
+	p, _ := strconv.Unquote(imp.Path.Value)
+	return path.Base(p)
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by storegen; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- if .UsesCmp}}
+	"cmp"
+{{- end}}
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+
+	"github.com/ArnaudCalmettes/store/fields"
+)
+
+func init() {
+{{- range .Structs}}
+{{- $struct := .Name}}
+	fields.Register(&fields.Accessors[{{$struct}}]{
+		Getters: map[string]any{
+		{{- range .Fields}}
+			"{{.Name}}": func(obj *{{$struct}}) {{.Type}} { return obj.{{.Name}} },
+		{{- end}}
+		},
+		Comparators: map[string]func(a, b *{{$struct}}) int{
+		{{- range .Fields}}
+		{{- if eq .Compare "cmp"}}
+			"{{.Name}}": func(a, b *{{$struct}}) int { return cmp.Compare(a.{{.Name}}, b.{{.Name}}) },
+		{{- else if eq .Compare "method"}}
+			"{{.Name}}": func(a, b *{{$struct}}) int { return a.{{.Name}}.Compare(b.{{.Name}}) },
+		{{- end}}
+		{{- end}}
+		},
+		NilCheckers: map[string]func(*{{$struct}}) bool{
+		{{- range .Fields}}
+		{{- if .Pointer}}
+			"{{.Name}}": func(obj *{{$struct}}) bool { return obj.{{.Name}} == nil },
+		{{- end}}
+		{{- end}}
+		},
+	})
+{{- end}}
+}
+`))
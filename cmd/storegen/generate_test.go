@@ -0,0 +1,76 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	dir := filepath.Join("testdata", "model")
+	t.Run("nominal", func(t *testing.T) {
+		src, err := generate(dir, []string{"Item"}, "store_accessors.go")
+		Require(t,
+			NoError(err),
+		)
+		golden := filepath.Join(dir, "store_accessors.go.golden")
+		if *update {
+			Require(t,
+				NoError(os.WriteFile(golden, src, 0o644)),
+			)
+		}
+		want, err := os.ReadFile(golden)
+		Expect(t,
+			NoError(err),
+			Equal(string(want), string(src)),
+		)
+	})
+	t.Run("type not found", func(t *testing.T) {
+		_, err := generate(dir, []string{"Item", "Missing"}, "store_accessors.go")
+		Expect(t,
+			IsError(errTypeNotFound, err),
+		)
+	})
+	t.Run("generic type", func(t *testing.T) {
+		_, err := generate(dir, []string{"Pair"}, "store_accessors.go")
+		Expect(t,
+			IsError(errGeneric, err),
+		)
+	})
+	t.Run("not a struct", func(t *testing.T) {
+		_, err := generate(dir, []string{"NotAStruct"}, "store_accessors.go")
+		Expect(t,
+			IsError(errNotAStruct, err),
+		)
+	})
+	t.Run("no package", func(t *testing.T) {
+		_, err := generate(t.TempDir(), []string{"Item"}, "store_accessors.go")
+		Expect(t,
+			IsError(errNoPackage, err),
+		)
+	})
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	stdtime "time"
+)
+
+type Status string
+
+type Base struct {
+	ID string
+}
+
+type Item struct {
+	Base
+
+	Name      string
+	Count     uint16
+	Price     float64
+	Active    bool
+	Status    Status
+	Tags      []string
+	Parent    *Item
+	CreatedAt stdtime.Time
+	DeletedAt *stdtime.Time
+	hidden    int
+}
+
+type Pair[K comparable] struct {
+	Key K
+}
+
+type NotAStruct int
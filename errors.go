@@ -21,6 +21,9 @@ package store
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 )
 
 type ErrorMap struct {
@@ -30,6 +33,10 @@ type ErrorMap struct {
 	ErrDeserialize   error
 	ErrInvalidOption error
 	ErrInvalidFilter error
+	ErrNotAnInteger  error
+	ErrOverflow      error
+	ErrConflict      error
+	ErrInvalidKey    error
 }
 
 var (
@@ -39,6 +46,10 @@ var (
 	ErrDeserialize   = errors.New("couldn't deserialize data")
 	ErrInvalidOption = errors.New("invalid option")
 	ErrInvalidFilter = errors.New("invalid filter")
+	ErrNotAnInteger  = errors.New("value is not an integer")
+	ErrOverflow      = errors.New("integer overflow")
+	ErrConflict      = errors.New("conflicting concurrent update")
+	ErrInvalidKey    = errors.New("invalid key")
 )
 
 func (e *ErrorMap) InitDefaultErrors() {
@@ -60,4 +71,94 @@ func (e *ErrorMap) InitDefaultErrors() {
 	if e.ErrInvalidFilter == nil {
 		e.ErrInvalidFilter = ErrInvalidFilter
 	}
+	if e.ErrNotAnInteger == nil {
+		e.ErrNotAnInteger = ErrNotAnInteger
+	}
+	if e.ErrOverflow == nil {
+		e.ErrOverflow = ErrOverflow
+	}
+	if e.ErrConflict == nil {
+		e.ErrConflict = ErrConflict
+	}
+	if e.ErrInvalidKey == nil {
+		e.ErrInvalidKey = ErrInvalidKey
+	}
+}
+
+// BatchError is returned by batch operations that failed for some of their
+// keys. It maps each failed key to its own error, and matches any of them
+// with errors.Is.
+type BatchError struct {
+	Errors map[string]error
+}
+
+// Add records the error for given key.
+func (e *BatchError) Add(key string, err error) {
+	if e.Errors == nil {
+		e.Errors = make(map[string]error)
+	}
+	e.Errors[key] = err
+}
+
+// ErrOrNil returns nil if no error was recorded, and e otherwise.
+func (e *BatchError) ErrOrNil() error {
+	if e == nil || len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *BatchError) Error() string {
+	keys := make([]string, 0, len(e.Errors))
+	for key := range e.Errors {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	msgs := make([]string, len(keys))
+	for i, key := range keys {
+		msgs[i] = fmt.Sprintf("%q: %v", key, e.Errors[key])
+	}
+	return fmt.Sprintf("batch failed for %d key(s): %s",
+		len(keys), strings.Join(msgs, "; "),
+	)
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// AsBatchError returns the BatchError wrapped by err, or a new empty one if err
+// is nil. ok is false if err is a non-nil error that isn't a BatchError.
+func AsBatchError(err error) (batch *BatchError, ok bool) {
+	if err == nil {
+		return &BatchError{}, true
+	}
+	if errors.As(err, &batch) {
+		return batch, true
+	}
+	return nil, false
+}
+
+// ModelError is returned by the TryNew* constructors when a type can't be
+// used as the model of a store. It lists every problem found with the type,
+// and matches each of them with errors.Is.
+type ModelError struct {
+	Model    string
+	Problems []error
+}
+
+func (e *ModelError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, err := range e.Problems {
+		problems[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid model %s: %s", e.Model, strings.Join(problems, "; "))
+}
+
+func (e *ModelError) Unwrap() []error {
+	return e.Problems
 }
@@ -0,0 +1,92 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package fields holds typed accessors for the fields of structs, that stores
+// evaluating filters and orderings in Go use instead of reflection when they
+// are available. Accessors are usually generated by cmd/storegen, and
+// registered when the package that declares the struct is initialized.
+package fields
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Accessors holds typed accessors for the fields of T, by field name. Fields
+// that are missing from a map are accessed through reflection.
+type Accessors[T any] struct {
+	// Getters are functions of type func(*T) F, where F is the type of the
+	// field.
+	Getters map[string]any
+
+	// Comparators compare two items by the value of a field, for fields of an
+	// ordered type.
+	Comparators map[string]func(a, b *T) int
+
+	// NilCheckers report whether a field is nil, for fields of a pointer type.
+	NilCheckers map[string]func(*T) bool
+}
+
+var registry sync.Map
+
+// Register makes the accessors of T available to the stores. Registering
+// accessors for T again replaces the previous ones.
+func Register[T any](accessors *Accessors[T]) {
+	registry.Store(reflect.TypeFor[T](), accessors)
+}
+
+// Lookup returns the accessors registered for T, or nil if there are none.
+func Lookup[T any]() *Accessors[T] {
+	accessors, ok := registry.Load(reflect.TypeFor[T]())
+	if !ok {
+		return nil
+	}
+	return accessors.(*Accessors[T])
+}
+
+// Getter returns the registered getter of a field of T, if it has type
+// func(*T) F.
+func Getter[T, F any](name string) (func(*T) F, bool) {
+	accessors := Lookup[T]()
+	if accessors == nil {
+		return nil, false
+	}
+	getter, ok := accessors.Getters[name].(func(*T) F)
+	return getter, ok
+}
+
+// Comparator returns the registered comparator of a field of T, if any.
+func Comparator[T any](name string) (func(a, b *T) int, bool) {
+	accessors := Lookup[T]()
+	if accessors == nil {
+		return nil, false
+	}
+	comparator, ok := accessors.Comparators[name]
+	return comparator, ok
+}
+
+// NilChecker returns the registered nil checker of a field of T, if any.
+func NilChecker[T any](name string) (func(*T) bool, bool) {
+	accessors := Lookup[T]()
+	if accessors == nil {
+		return nil, false
+	}
+	isNil, ok := accessors.NilCheckers[name]
+	return isNil, ok
+}
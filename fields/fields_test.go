@@ -0,0 +1,70 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package fields
+
+import (
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+type registered struct {
+	Name string
+	Ptr  *int
+}
+
+type unregistered struct {
+	Name string
+}
+
+func TestRegistry(t *testing.T) {
+	Register(&Accessors[registered]{
+		Getters: map[string]any{
+			"Name": func(obj *registered) string { return obj.Name },
+		},
+		Comparators: map[string]func(a, b *registered) int{
+			"Name": func(a, b *registered) int { return len(a.Name) - len(b.Name) },
+		},
+		NilCheckers: map[string]func(*registered) bool{
+			"Ptr": func(obj *registered) bool { return obj.Ptr == nil },
+		},
+	})
+
+	getter, ok := Getter[registered, string]("Name")
+	Require(t,
+		Equal(true, ok),
+	)
+	_, wrongType := Getter[registered, int]("Name")
+	_, missing := Getter[registered, string]("Missing")
+	_, unknown := Getter[unregistered, string]("Name")
+	compare, hasComparator := Comparator[registered]("Name")
+	isNil, hasNilChecker := NilChecker[registered]("Ptr")
+	Expect(t,
+		Equal("foo", getter(&registered{Name: "foo"})),
+		Equal(false, wrongType),
+		Equal(false, missing),
+		Equal(false, unknown),
+		Equal(true, hasComparator),
+		Equal(-1, compare(&registered{Name: "b"}, &registered{Name: "aa"})),
+		Equal(true, hasNilChecker),
+		Equal(true, isNil(&registered{})),
+		IsNilPointer(Lookup[unregistered]()),
+	)
+}
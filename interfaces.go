@@ -19,12 +19,31 @@
 
 package store
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type ErrorMapSetter interface {
 	SetErrorMap(ErrorMap)
 }
 
+// ClockSetter is implemented by stores that maintain timestamps, such as the
+// fields tagged with store:"created_at" and store:"updated_at". The clock
+// defaults to time.Now.
+type ClockSetter interface {
+	SetClock(now func() time.Time)
+}
+
+// Comparer is implemented by field types that define their own ordering, like
+// time.Time. Compare returns a negative number if the receiver is less than
+// other, zero if they are equal, and a positive number otherwise. Stores that
+// filter and sort in Go use it for such fields, while sql stores compare the
+// values returned by driver.Valuer.
+type Comparer[T any] interface {
+	Compare(other T) int
+}
+
 type Resetter interface {
 	Reset(ctx context.Context) error
 }
@@ -33,3 +52,16 @@ type Serializer[T any] interface {
 	Serialize(*T) (string, error)
 	Deserialize(string) (*T, error)
 }
+
+// BinarySerializer is the byte-oriented counterpart of Serializer.
+// SerializeAppend appends the serialized object to dst and returns the
+// extended buffer, so that callers can reuse their buffers.
+type BinarySerializer[T any] interface {
+	SerializeAppend(dst []byte, obj *T) ([]byte, error)
+	DeserializeBytes(data []byte) (*T, error)
+}
+
+type Counter interface {
+	Incr(ctx context.Context, key string, delta int64) (int64, error)
+	IncrMany(ctx context.Context, deltas map[string]int64) (map[string]int64, error)
+}
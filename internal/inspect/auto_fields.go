@@ -0,0 +1,156 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package inspect
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var errAutoFieldType = errors.New("unsupported type for automatic field")
+
+// AutoFields maintains the fields of T that are tagged with
+// store:"created_at", store:"updated_at" or store:"version".
+//
+// Timestamps must be of type time.Time or *time.Time, and versions of an
+// integer type.
+type AutoFields[T any] struct {
+	createdAt []int
+	updatedAt []int
+	version   []int
+}
+
+// NewAutoFields returns the automatic fields of T, or nil if T doesn't have
+// any.
+func NewAutoFields[T any]() (*AutoFields[T], error) {
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	a := &AutoFields[T]{}
+	for _, field := range reflect.VisibleFields(typ) {
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("store")
+		switch {
+		case HasStoreOption(tag, "created_at"):
+			if !isTimestampType(field.Type) {
+				return nil, fmt.Errorf("%w: %s is a %s", errAutoFieldType, field.Name, field.Type)
+			}
+			a.createdAt = field.Index
+		case HasStoreOption(tag, "updated_at"):
+			if !isTimestampType(field.Type) {
+				return nil, fmt.Errorf("%w: %s is a %s", errAutoFieldType, field.Name, field.Type)
+			}
+			a.updatedAt = field.Index
+		case HasStoreOption(tag, "version"):
+			if !isIntegerType(field.Type) {
+				return nil, fmt.Errorf("%w: %s is a %s", errAutoFieldType, field.Name, field.Type)
+			}
+			a.version = field.Index
+		}
+	}
+	if a.createdAt == nil && a.updatedAt == nil && a.version == nil {
+		return nil, nil
+	}
+	return a, nil
+}
+
+// HasStoreOption reports whether a store struct tag contains option.
+func HasStoreOption(tag string, option string) bool {
+	for _, opt := range strings.Split(tag, ",") {
+		if strings.TrimSpace(opt) == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Touch updates the automatic fields of item before it is written at time
+// now. prev is the value item replaces, or nil if it is new: its creation
+// time is kept, and its version is incremented. prev may be item itself.
+func (a *AutoFields[T]) Touch(item, prev *T, now time.Time) {
+	if a == nil {
+		return
+	}
+	val := reflect.ValueOf(item).Elem()
+	var prevVal reflect.Value
+	if prev != nil {
+		prevVal = reflect.ValueOf(prev).Elem()
+	}
+	if a.createdAt != nil {
+		field := val.FieldByIndex(a.createdAt)
+		switch {
+		case prev != nil && !prevVal.FieldByIndex(a.createdAt).IsZero():
+			field.Set(prevVal.FieldByIndex(a.createdAt))
+		case field.IsZero():
+			setTimestamp(field, now)
+		}
+	}
+	if a.updatedAt != nil {
+		setTimestamp(val.FieldByIndex(a.updatedAt), now)
+	}
+	if a.version != nil {
+		field := val.FieldByIndex(a.version)
+		var next int64 = 1
+		if prev != nil {
+			next = integerValue(prevVal.FieldByIndex(a.version)) + 1
+		}
+		if field.CanInt() {
+			field.SetInt(next)
+		} else {
+			field.SetUint(uint64(next))
+		}
+	}
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+func isTimestampType(typ reflect.Type) bool {
+	return typ == timeType || typ == reflect.PointerTo(timeType)
+}
+
+func isIntegerType(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func setTimestamp(field reflect.Value, now time.Time) {
+	if field.Kind() == reflect.Pointer {
+		field.Set(reflect.ValueOf(&now))
+		return
+	}
+	field.Set(reflect.ValueOf(now))
+}
+
+func integerValue(v reflect.Value) int64 {
+	if v.CanInt() {
+		return v.Int()
+	}
+	return int64(v.Uint())
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package inspect
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+func TestAutoFields(t *testing.T) {
+	type Model struct {
+		Name     string
+		Created  *time.Time `store:"index,created_at"`
+		Updated  time.Time  `store:"updated_at"`
+		Revision uint16     `store:"version"`
+	}
+	t.Run("none", func(t *testing.T) {
+		auto, err := NewAutoFields[struct{ Name string }]()
+		Expect(t,
+			NoError(err),
+			IsNilPointer(auto),
+		)
+	})
+	t.Run("invalid type", func(t *testing.T) {
+		_, err := NewAutoFields[struct {
+			Version string `store:"version"`
+		}]()
+		Expect(t, IsError(errAutoFieldType, err))
+	})
+	t.Run("Touch", func(t *testing.T) {
+		auto, err := NewAutoFields[Model]()
+		Require(t, NoError(err))
+		t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		t1 := t0.Add(time.Hour)
+
+		item := &Model{Name: "new"}
+		auto.Touch(item, nil, t0)
+		Expect(t, Equal(&Model{Name: "new", Created: &t0, Updated: t0, Revision: 1}, item))
+
+		next := &Model{Name: "next"}
+		auto.Touch(next, item, t1)
+		Expect(t, Equal(&Model{Name: "next", Created: &t0, Updated: t1, Revision: 2}, next))
+
+		auto.Touch(next, next, t1)
+		Expect(t, Equal(3, int(next.Revision)))
+	})
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package inspect
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// IsCollectionOperator reports whether op applies to slice, array or map
+// fields rather than to single values.
+func IsCollectionOperator(op string) bool {
+	switch op {
+	case "contains", "contains_any", "contains_all", "has_key":
+		return true
+	}
+	return false
+}
+
+// collectionPredicate evaluates the collection operators: "contains" selects
+// the items whose field has the value as an element, "contains_any" and
+// "contains_all" the items whose field has any or all the elements of a
+// slice, and "has_key" the items whose map field has the value as a key.
+func collectionPredicate[T any](name string, op string, value any) (func(*T) bool, error) {
+	field, err := structField[T](name)
+	if err != nil {
+		return nil, err
+	}
+	fieldType := field.Type
+	pointer := fieldType.Kind() == reflect.Pointer
+	if pointer {
+		fieldType = fieldType.Elem()
+	}
+	switch kind := fieldType.Kind(); {
+	case op == "has_key" && kind == reflect.Map:
+	case op != "has_key" && (kind == reflect.Slice || kind == reflect.Array):
+	default:
+		return nil, fmt.Errorf("%w: %q not supported with type %s",
+			errInvalidOperator, op, field.Type,
+		)
+	}
+	var elemType reflect.Type
+	if op == "has_key" {
+		elemType = fieldType.Key()
+	} else {
+		elemType = fieldType.Elem()
+	}
+
+	var values []reflect.Value
+	switch v := reflect.ValueOf(value); op {
+	case "contains_any", "contains_all":
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, fmt.Errorf("%w: %q expects a slice, not %T",
+				errTypeMismatch, op, value,
+			)
+		}
+		for i := range v.Len() {
+			values = append(values, v.Index(i))
+		}
+	default:
+		values = []reflect.Value{v}
+	}
+	matchers := make([]func(reflect.Value) bool, len(values))
+	for i, v := range values {
+		if v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer && !v.IsNil() {
+			v = v.Elem()
+		}
+		if !v.IsValid() || v.Kind() == reflect.Pointer || !isSupported(v.Type()) {
+			return nil, fmt.Errorf("%w: %q with %T", errTypeNotSupported, op, value)
+		}
+		compare := kindComparator(elemType, v.Type())
+		if compare == nil {
+			return nil, fmt.Errorf("%w: field %s has elements of type %s, not %s",
+				errTypeMismatch, field.Name, elemType, v.Type(),
+			)
+		}
+		if op == "has_key" {
+			matchers[i] = hasKey(v, compare)
+		} else {
+			matchers[i] = hasElem(v, compare)
+		}
+	}
+
+	index := field.Index
+	get := func(obj *T) (reflect.Value, bool) {
+		f := reflect.ValueOf(obj).Elem().FieldByIndex(index)
+		if pointer {
+			if f.IsNil() {
+				return f, false
+			}
+			f = f.Elem()
+		}
+		return f, true
+	}
+	all := op == "contains_all"
+	pred := func(obj *T) bool {
+		f, ok := get(obj)
+		if !ok {
+			return false
+		}
+		for _, match := range matchers {
+			if match(f) != all {
+				return !all
+			}
+		}
+		return all
+	}
+	return pred, nil
+}
+
+func hasElem(v reflect.Value, compare func(x, y reflect.Value) int) func(reflect.Value) bool {
+	return func(f reflect.Value) bool {
+		for i := range f.Len() {
+			if compare(f.Index(i), v) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func hasKey(v reflect.Value, compare func(x, y reflect.Value) int) func(reflect.Value) bool {
+	return func(f reflect.Value) bool {
+		if v.Type() == f.Type().Key() {
+			return f.MapIndex(v).IsValid()
+		}
+		for iter := f.MapRange(); iter.Next(); {
+			if compare(iter.Key(), v) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package inspect
+
+import (
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+type collectionTest struct {
+	Tags     []string
+	Statuses [2]status
+	Scores   *[]int
+	Labels   map[string]string
+	Sizes    map[uint8]bool
+}
+
+func TestCollectionPredicates(t *testing.T) {
+	scores := []int{1, 2, 3}
+	obj := &collectionTest{
+		Tags:     []string{"go", "sql"},
+		Statuses: [2]status{"active", "draft"},
+		Scores:   &scores,
+		Labels:   map[string]string{"env": "prod"},
+		Sizes:    map[uint8]bool{42: true},
+	}
+	testCases := []struct {
+		Name   string
+		Filter *FilterSpec
+		Expect bool
+	}{
+		{"contains", Where("Tags", "contains", "go"), true},
+		{"doesn't contain", Where("Tags", "contains", "rust"), false},
+		{"contains named type", Where("Statuses", "contains", "draft"), true},
+		{"contains through pointer", Where("Scores", "contains", int64(2)), true},
+		{"contains any", Where("Tags", "contains_any", []string{"rust", "sql"}), true},
+		{"contains any none", Where("Tags", "contains_any", []string{"rust", "c"}), false},
+		{"contains any empty", Where("Tags", "contains_any", []string{}), false},
+		{"contains all", Where("Tags", "contains_all", []any{"sql", "go"}), true},
+		{"contains all but one", Where("Tags", "contains_all", []string{"go", "rust"}), false},
+		{"contains all empty", Where("Tags", "contains_all", []string{}), true},
+		{"has key", Where("Labels", "has_key", "env"), true},
+		{"doesn't have key", Where("Labels", "has_key", "team"), false},
+		{"has key of another type", Where("Sizes", "has_key", 42), true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			pred, err := NewPredicate[collectionTest](tc.Filter)
+			Require(t,
+				NoError(err),
+			)
+			Expect(t,
+				Equal(tc.Expect, pred(obj)),
+			)
+		})
+	}
+	t.Run("nil collections", func(t *testing.T) {
+		pred, err := NewPredicate[collectionTest](Any(
+			Where("Tags", "contains", "go"),
+			Where("Scores", "contains", 1),
+			Where("Labels", "has_key", "env"),
+		))
+		Expect(t,
+			NoError(err),
+			Equal(false, pred(&collectionTest{})),
+		)
+	})
+	t.Run("errors", func(t *testing.T) {
+		_, err := NewPredicate[collectionTest](Where("Labels", "contains", "prod"))
+		Expect(t,
+			IsError(errInvalidOperator, err),
+		)
+		_, err = NewPredicate[collectionTest](Where("Tags", "has_key", 0))
+		Expect(t,
+			IsError(errInvalidOperator, err),
+		)
+		_, err = NewPredicate[collectionTest](Where("Tags", "contains", 1))
+		Expect(t,
+			IsError(errTypeMismatch, err),
+		)
+		_, err = NewPredicate[collectionTest](Where("Tags", "contains_any", "go"))
+		Expect(t,
+			IsError(errTypeMismatch, err),
+		)
+		_, err = NewPredicate[collectionTest](Where("Tags", "contains", nil))
+		Expect(t,
+			IsError(errTypeNotSupported, err),
+		)
+		_, err = NewPredicate[collectionTest](Where("Missing", "contains", "go"))
+		Expect(t,
+			IsError(errNoSuchField, err),
+		)
+	})
+}
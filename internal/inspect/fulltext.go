@@ -0,0 +1,104 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package inspect
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/ArnaudCalmettes/store"
+)
+
+var errEmptyQuery = errors.New("full-text query has no words")
+
+// Tokenize splits text into lowercase words, made of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Relevance scores how relevant text is to the given query words: it is the
+// proportion of the words of text that are query words, or 0 if any query
+// word is missing.
+func Relevance(text string, terms []string) float64 {
+	words := Tokenize(text)
+	counts := make(map[string]int, len(words))
+	for _, word := range words {
+		counts[word]++
+	}
+	var hits int
+	for _, term := range terms {
+		if counts[term] == 0 {
+			return 0
+		}
+		hits += counts[term]
+		counts[term] = 0
+	}
+	return float64(hits) / float64(len(words))
+}
+
+func matchPredicate[T any](field string, value any) (func(*T) bool, error) {
+	query, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q expects a string query, not %T",
+			errInvalidOperator, "match", value,
+		)
+	}
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return nil, errEmptyQuery
+	}
+	f, err := FieldSelector[T, string](field)
+	if err != nil {
+		return nil, err
+	}
+	pred := func(obj *T) bool {
+		return Relevance(f(obj), terms) > 0
+	}
+	return pred, nil
+}
+
+func relevanceCmp[T any](order *store.OrderBySpec) (func(*T, *T) int, error) {
+	terms := Tokenize(order.Query)
+	if len(terms) == 0 {
+		return nil, errEmptyQuery
+	}
+	get, err := FieldSelector[T, string](order.Field)
+	if err != nil {
+		return nil, err
+	}
+	scores := make(map[*T]float64)
+	score := func(obj *T) float64 {
+		s, ok := scores[obj]
+		if !ok {
+			s = Relevance(get(obj), terms)
+			scores[obj] = s
+		}
+		return s
+	}
+	if order.Descending {
+		return func(a, b *T) int { return cmp.Compare(score(a), score(b)) }, nil
+	}
+	return func(a, b *T) int { return cmp.Compare(score(b), score(a)) }, nil
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package inspect
+
+import (
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+type textTest struct {
+	Text  string
+	Count int
+}
+
+func TestTokenize(t *testing.T) {
+	Expect(t,
+		Equal([]string{"hello", "wörld", "42"}, Tokenize("Hello, WÖRLD! (42)")),
+		SliceHasLength(0, Tokenize(" ?! ")),
+	)
+}
+
+func TestRelevance(t *testing.T) {
+	Expect(t,
+		Equal(1.0, Relevance("go go", []string{"go"})),
+		Equal(0.5, Relevance("go fish", []string{"go"})),
+		Equal(0.5, Relevance("go fish go fish", []string{"fish"})),
+		Equal(0.0, Relevance("go fish", []string{"go", "rust"})),
+		Equal(0.0, Relevance("", []string{"go"})),
+	)
+}
+
+func TestMatchPredicate(t *testing.T) {
+	t.Run("nominal", func(t *testing.T) {
+		pred, err := NewPredicate[textTest](Match("Text", "Fish GO"))
+		Expect(t,
+			NoError(err),
+			Equal(true, pred(&textTest{Text: "go, fish!"})),
+			Equal(false, pred(&textTest{Text: "go fishing"})),
+		)
+	})
+	t.Run("empty query", func(t *testing.T) {
+		_, err := NewPredicate[textTest](Match("Text", "!?"))
+		Expect(t,
+			IsError(errEmptyQuery, err),
+		)
+	})
+	t.Run("not a string query", func(t *testing.T) {
+		_, err := NewPredicate[textTest](Where("Text", "match", 42))
+		Expect(t,
+			IsError(errInvalidOperator, err),
+		)
+	})
+	t.Run("not a string field", func(t *testing.T) {
+		_, err := NewPredicate[textTest](Match("Count", "42"))
+		Expect(t,
+			IsError(errTypeMismatch, err),
+		)
+	})
+}
+
+func TestRelevanceCmp(t *testing.T) {
+	best := &textTest{Text: "go go"}
+	good := &textTest{Text: "go fish"}
+	none := &textTest{Text: "fish"}
+	t.Run("nominal", func(t *testing.T) {
+		cmp, err := NewCmp[textTest](ByRelevance("Text", "go"))
+		Expect(t,
+			NoError(err),
+			Equal(-1, cmp(best, good)),
+			Equal(-1, cmp(good, none)),
+			Equal(1, cmp(none, best)),
+			Equal(0, cmp(none, none)),
+		)
+	})
+	t.Run("descending", func(t *testing.T) {
+		cmp, err := NewCmp[textTest](ByRelevance("Text", "go").Desc())
+		Expect(t,
+			NoError(err),
+			Equal(1, cmp(best, good)),
+			Equal(-1, cmp(none, best)),
+		)
+	})
+	t.Run("empty query", func(t *testing.T) {
+		_, err := NewCmp[textTest](ByRelevance("Text", "!?"))
+		Expect(t,
+			IsError(errEmptyQuery, err),
+		)
+	})
+}
@@ -0,0 +1,443 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package inspect
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+
+	"github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/fields"
+)
+
+// Fields and values whose types aren't an exact match are compared through
+// reflection, based on their underlying kind: any string, bool or number
+// type works, numbers of different types are compared by value, and types
+// with a Compare method (see store.Comparer) are compared with it.
+
+var intType = reflect.TypeFor[int]()
+
+// compareMethod returns a function that calls the Compare method of t, if it
+// has one with the signature of store.Comparer[t].
+func compareMethod(t reflect.Type) (func(x, y reflect.Value) int, bool) {
+	if t.Kind() == reflect.Interface {
+		return nil, false
+	}
+	isComparer := func(m reflect.Method) bool {
+		mt := m.Type
+		return mt.NumIn() == 2 && mt.In(1) == t && mt.NumOut() == 1 && mt.Out(0) == intType
+	}
+	if m, ok := t.MethodByName("Compare"); ok && isComparer(m) {
+		compare := func(x, y reflect.Value) int {
+			return int(m.Func.Call([]reflect.Value{x, y})[0].Int())
+		}
+		return compare, true
+	}
+	if m, ok := reflect.PointerTo(t).MethodByName("Compare"); ok && isComparer(m) {
+		compare := func(x, y reflect.Value) int {
+			p := reflect.New(t)
+			p.Elem().Set(x)
+			return int(m.Func.Call([]reflect.Value{p, y})[0].Int())
+		}
+		return compare, true
+	}
+	return nil, false
+}
+
+func isNumber(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// isSupported reports whether values of type t can be compared at all.
+func isSupported(t reflect.Type) bool {
+	_, ok := compareMethod(t)
+	return ok || isNumber(t) || t.Kind() == reflect.String || t.Kind() == reflect.Bool
+}
+
+// kindComparator returns a function that compares values of type a with
+// values of type b, or nil if they can't be compared.
+func kindComparator(a, b reflect.Type) func(x, y reflect.Value) int {
+	if compare, ok := compareMethod(a); ok {
+		switch {
+		case a == b:
+			return compare
+		case a.Kind() == b.Kind() && b.ConvertibleTo(a):
+			return func(x, y reflect.Value) int { return compare(x, y.Convert(a)) }
+		}
+		return nil
+	}
+	switch {
+	case isNumber(a) && isNumber(b):
+		return compareNumbers
+	case a.Kind() == reflect.String && b.Kind() == reflect.String:
+		return func(x, y reflect.Value) int { return cmp.Compare(x.String(), y.String()) }
+	case a.Kind() == reflect.Bool && b.Kind() == reflect.Bool:
+		return func(x, y reflect.Value) int { return compareBools(x.Bool(), y.Bool()) }
+	}
+	return nil
+}
+
+func compareBools(x, y bool) int {
+	switch {
+	case x == y:
+		return 0
+	case y:
+		return -1
+	}
+	return 1
+}
+
+// compareNumbers compares numbers of any kind by value.
+func compareNumbers(x, y reflect.Value) int {
+	switch {
+	case x.CanFloat() || y.CanFloat():
+		return cmp.Compare(toFloat(x), toFloat(y))
+	case x.CanInt() && y.CanInt():
+		return cmp.Compare(x.Int(), y.Int())
+	case x.CanUint() && y.CanUint():
+		return cmp.Compare(x.Uint(), y.Uint())
+	case x.CanInt():
+		if x.Int() < 0 {
+			return -1
+		}
+		return cmp.Compare(uint64(x.Int()), y.Uint())
+	default:
+		if y.Int() < 0 {
+			return 1
+		}
+		return cmp.Compare(x.Uint(), uint64(y.Int()))
+	}
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch {
+	case v.CanInt():
+		return float64(v.Int())
+	case v.CanUint():
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
+
+// kindPredicate compares a field with a value of another type. Pointer
+// fields are dereferenced, and like NULL in SQL, a nil pointer doesn't match
+// any comparison.
+func kindPredicate[T any](field reflect.StructField, op string, value any) (func(*T) bool, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if !isSupported(v.Type()) {
+		return nil, fmt.Errorf("%w: %s", errTypeNotSupported, v.Type())
+	}
+	fieldType := field.Type
+	pointer := fieldType.Kind() == reflect.Pointer
+	if pointer {
+		fieldType = fieldType.Elem()
+	}
+	compare := kindComparator(fieldType, v.Type())
+	if compare == nil {
+		return nil, fmt.Errorf("%w: field %s is of type %s, not %s",
+			errTypeMismatch, field.Name, field.Type, v.Type(),
+		)
+	}
+	if fieldType.Kind() == reflect.Bool && op != "=" && op != "!=" {
+		return nil, fmt.Errorf("%w: %q not supported with type %s",
+			errInvalidOperator, op, field.Type,
+		)
+	}
+	test, err := comparisonTest(op, field.Type)
+	if err != nil {
+		return nil, err
+	}
+	if pred, ok := getterPredicate[T](field, v, test); ok {
+		return pred, nil
+	}
+	index := field.Index
+	pred := func(obj *T) bool {
+		f := reflect.ValueOf(obj).Elem().FieldByIndex(index)
+		if pointer {
+			if f.IsNil() {
+				return false
+			}
+			f = f.Elem()
+		}
+		return test(compare(f, v))
+	}
+	return pred, nil
+}
+
+// comparisonTest returns a function that tells whether the result of a
+// comparison satisfies op.
+func comparisonTest(op string, typ reflect.Type) (func(int) bool, error) {
+	switch op {
+	case ">":
+		return func(c int) bool { return c > 0 }, nil
+	case ">=":
+		return func(c int) bool { return c >= 0 }, nil
+	case "=":
+		return func(c int) bool { return c == 0 }, nil
+	case "!=":
+		return func(c int) bool { return c != 0 }, nil
+	case "<=":
+		return func(c int) bool { return c <= 0 }, nil
+	case "<":
+		return func(c int) bool { return c < 0 }, nil
+	}
+	return nil, fmt.Errorf("%w: %q not supported with type %s",
+		errInvalidOperator, op, typ,
+	)
+}
+
+// kindCmp orders items by a field of any supported type. Pointer fields are
+// dereferenced, and nil pointers come first.
+func kindCmp[T any](field reflect.StructField, order *store.OrderBySpec) (func(*T, *T) int, error) {
+	fieldType := field.Type
+	pointer := fieldType.Kind() == reflect.Pointer
+	if pointer {
+		fieldType = fieldType.Elem()
+	}
+	if !isSupported(fieldType) {
+		return nil, fmt.Errorf("%w: %s", errTypeNotSupported, field.Type)
+	}
+	if cmpFunc, ok := getterCmp[T](field); ok {
+		if order.Descending {
+			return func(a, b *T) int { return cmpFunc(b, a) }, nil
+		}
+		return cmpFunc, nil
+	}
+	compare := kindComparator(fieldType, fieldType)
+	index := field.Index
+	get := func(obj *T) (reflect.Value, bool) {
+		f := reflect.ValueOf(obj).Elem().FieldByIndex(index)
+		if pointer {
+			if f.IsNil() {
+				return f, false
+			}
+			f = f.Elem()
+		}
+		return f, true
+	}
+	cmpFunc := func(a, b *T) int {
+		x, okX := get(a)
+		y, okY := get(b)
+		if !okX || !okY {
+			return cmp.Compare(boolToInt(okX), boolToInt(okY))
+		}
+		return compare(x, y)
+	}
+	if order.Descending {
+		return func(a, b *T) int { return cmpFunc(b, a) }, nil
+	}
+	return cmpFunc, nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Fields of a builtin type, or of a pointer to one, are read with their
+// generated getter when one is registered (see package fields), and compared
+// without reflection. The value they are compared with is converted once,
+// when the predicate is built.
+
+type number interface {
+	int | int8 | int16 | int32 | int64 |
+		uint | uint8 | uint16 | uint32 | uint64 | uintptr |
+		float32 | float64
+}
+
+// registeredGetter returns the registered getter of a field of T, or nil.
+func registeredGetter[T any](name string) any {
+	if accessors := fields.Lookup[T](); accessors != nil {
+		return accessors.Getters[name]
+	}
+	return nil
+}
+
+// getterPredicate is like kindPredicate, for fields that have a typed getter.
+func getterPredicate[T any](field reflect.StructField, v reflect.Value, test func(int) bool) (func(*T) bool, bool) {
+	getter := registeredGetter[T](field.Name)
+	if getter == nil {
+		return nil, false
+	}
+	fieldType := field.Type
+	if fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+	switch fieldType.Kind() {
+	case reflect.String:
+		y := v.String()
+		return typedPredicate[T](getter, func(x string) int { return cmp.Compare(x, y) }, test)
+	case reflect.Bool:
+		y := v.Bool()
+		return typedPredicate[T](getter, func(x bool) int { return compareBools(x, y) }, test)
+	case reflect.Int:
+		return typedPredicate[T](getter, numberComparator[int](v), test)
+	case reflect.Int8:
+		return typedPredicate[T](getter, numberComparator[int8](v), test)
+	case reflect.Int16:
+		return typedPredicate[T](getter, numberComparator[int16](v), test)
+	case reflect.Int32:
+		return typedPredicate[T](getter, numberComparator[int32](v), test)
+	case reflect.Int64:
+		return typedPredicate[T](getter, numberComparator[int64](v), test)
+	case reflect.Uint:
+		return typedPredicate[T](getter, numberComparator[uint](v), test)
+	case reflect.Uint8:
+		return typedPredicate[T](getter, numberComparator[uint8](v), test)
+	case reflect.Uint16:
+		return typedPredicate[T](getter, numberComparator[uint16](v), test)
+	case reflect.Uint32:
+		return typedPredicate[T](getter, numberComparator[uint32](v), test)
+	case reflect.Uint64:
+		return typedPredicate[T](getter, numberComparator[uint64](v), test)
+	case reflect.Uintptr:
+		return typedPredicate[T](getter, numberComparator[uintptr](v), test)
+	case reflect.Float32:
+		return typedPredicate[T](getter, numberComparator[float32](v), test)
+	case reflect.Float64:
+		return typedPredicate[T](getter, numberComparator[float64](v), test)
+	}
+	return nil, false
+}
+
+// typedPredicate returns a predicate that tests the result of compare on the
+// field read by getter, if getter has type func(*T) F or func(*T) *F. Named
+// types don't match, and are left to reflection.
+func typedPredicate[T, F any](getter any, compare func(F) int, test func(int) bool) (func(*T) bool, bool) {
+	switch get := getter.(type) {
+	case func(*T) F:
+		return func(obj *T) bool { return test(compare(get(obj))) }, true
+	case func(*T) *F:
+		return func(obj *T) bool {
+			f := get(obj)
+			return f != nil && test(compare(*f))
+		}, true
+	}
+	return nil, false
+}
+
+// numberComparator returns a function that compares numbers of type F with
+// y, like compareNumbers.
+func numberComparator[F number](y reflect.Value) func(F) int {
+	kind := reflect.TypeFor[F]().Kind()
+	isFloat := kind == reflect.Float32 || kind == reflect.Float64
+	isUint := !isFloat && !reflect.Zero(reflect.TypeFor[F]()).CanInt()
+	switch {
+	case isFloat || y.CanFloat():
+		yf := toFloat(y)
+		return func(x F) int { return cmp.Compare(float64(x), yf) }
+	case y.CanInt() && isUint:
+		yi := y.Int()
+		if yi < 0 {
+			return func(F) int { return 1 }
+		}
+		return func(x F) int { return cmp.Compare(uint64(x), uint64(yi)) }
+	case y.CanInt():
+		yi := y.Int()
+		return func(x F) int { return cmp.Compare(int64(x), yi) }
+	case isUint:
+		yu := y.Uint()
+		return func(x F) int { return cmp.Compare(uint64(x), yu) }
+	default:
+		yu := y.Uint()
+		return func(x F) int {
+			if x < 0 {
+				return -1
+			}
+			return cmp.Compare(uint64(x), yu)
+		}
+	}
+}
+
+// getterCmp is like kindCmp, for fields that have a typed getter.
+func getterCmp[T any](field reflect.StructField) (func(a, b *T) int, bool) {
+	getter := registeredGetter[T](field.Name)
+	if getter == nil {
+		return nil, false
+	}
+	fieldType := field.Type
+	if fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+	switch fieldType.Kind() {
+	case reflect.String:
+		return typedCmp[T](getter, cmp.Compare[string])
+	case reflect.Bool:
+		return typedCmp[T](getter, compareBools)
+	case reflect.Int:
+		return typedCmp[T](getter, cmp.Compare[int])
+	case reflect.Int8:
+		return typedCmp[T](getter, cmp.Compare[int8])
+	case reflect.Int16:
+		return typedCmp[T](getter, cmp.Compare[int16])
+	case reflect.Int32:
+		return typedCmp[T](getter, cmp.Compare[int32])
+	case reflect.Int64:
+		return typedCmp[T](getter, cmp.Compare[int64])
+	case reflect.Uint:
+		return typedCmp[T](getter, cmp.Compare[uint])
+	case reflect.Uint8:
+		return typedCmp[T](getter, cmp.Compare[uint8])
+	case reflect.Uint16:
+		return typedCmp[T](getter, cmp.Compare[uint16])
+	case reflect.Uint32:
+		return typedCmp[T](getter, cmp.Compare[uint32])
+	case reflect.Uint64:
+		return typedCmp[T](getter, cmp.Compare[uint64])
+	case reflect.Uintptr:
+		return typedCmp[T](getter, cmp.Compare[uintptr])
+	case reflect.Float32:
+		return typedCmp[T](getter, cmp.Compare[float32])
+	case reflect.Float64:
+		return typedCmp[T](getter, cmp.Compare[float64])
+	}
+	return nil, false
+}
+
+// typedCmp returns a function that compares items by the field read by
+// getter, if getter has type func(*T) F or func(*T) *F. Like in kindCmp, nil
+// pointers come first.
+func typedCmp[T, F any](getter any, compare func(x, y F) int) (func(a, b *T) int, bool) {
+	switch get := getter.(type) {
+	case func(*T) F:
+		return func(a, b *T) int { return compare(get(a), get(b)) }, true
+	case func(*T) *F:
+		return func(a, b *T) int {
+			x, y := get(a), get(b)
+			if x == nil || y == nil {
+				return cmp.Compare(boolToInt(x != nil), boolToInt(y != nil))
+			}
+			return compare(*x, *y)
+		}, true
+	}
+	return nil, false
+}
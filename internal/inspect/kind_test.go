@@ -0,0 +1,225 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package inspect
+
+import (
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/fields"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+type status string
+
+// version has a Compare method with a value receiver.
+type version struct {
+	Major, Minor int
+}
+
+func (v version) Compare(other version) int {
+	if v.Major != other.Major {
+		return v.Major - other.Major
+	}
+	return v.Minor - other.Minor
+}
+
+// reverse has a Compare method with a pointer receiver.
+type reverse int
+
+func (r *reverse) Compare(other reverse) int {
+	return int(other - *r)
+}
+
+type kindTest struct {
+	Status  status
+	Count   uint16
+	Score   *int
+	Ratio   float32
+	Flag    bool
+	Version version
+	Reverse reverse
+}
+
+// typedKindTest has generated getters for its fields of builtin types.
+type typedKindTest kindTest
+
+func init() {
+	fields.Register(&fields.Accessors[typedKindTest]{
+		Getters: map[string]any{
+			"Count": func(obj *typedKindTest) uint16 { return obj.Count },
+			"Score": func(obj *typedKindTest) *int { return obj.Score },
+			"Ratio": func(obj *typedKindTest) float32 { return obj.Ratio },
+			"Flag":  func(obj *typedKindTest) bool { return obj.Flag },
+		},
+	})
+}
+
+// newKindPredicate returns the predicate of filter for kindTest, through
+// reflection or generated getters.
+func newKindPredicate(typed bool, filter *FilterSpec) (func(*kindTest) bool, error) {
+	if !typed {
+		return NewPredicate[kindTest](filter)
+	}
+	pred, err := NewPredicate[typedKindTest](filter)
+	if err != nil {
+		return nil, err
+	}
+	return func(obj *kindTest) bool { return pred((*typedKindTest)(obj)) }, nil
+}
+
+func TestKindPredicates(t *testing.T) {
+	t.Run("reflection", func(t *testing.T) { testKindPredicates(t, false) })
+	t.Run("getters", func(t *testing.T) { testKindPredicates(t, true) })
+}
+
+func testKindPredicates(t *testing.T, typed bool) {
+	five := 5
+	obj := &kindTest{
+		Status:  "active",
+		Count:   300,
+		Score:   &five,
+		Ratio:   0.5,
+		Flag:    true,
+		Version: version{1, 2},
+		Reverse: 10,
+	}
+	testCases := []struct {
+		Name   string
+		Filter *FilterSpec
+		Expect bool
+	}{
+		{"named type", Where("Status", "=", "active"), true},
+		{"named type value", Where("Status", "<", status("b")), true},
+		{"small uint", Where("Count", ">", uint8(200)), true},
+		{"negative int", Where("Count", ">", -1), true},
+		{"int overflowing field", Where("Count", "<", 70000), true},
+		{"float with int", Where("Ratio", "<", 1), true},
+		{"int with float", Where("Count", "<=", 299.5), false},
+		{"float with uint", Where("Ratio", ">", uint(0)), true},
+		{"pointer with value", Where("Score", ">=", 5), true},
+		{"pointer with pointer", Where("Score", "=", &five), true},
+		{"bool", Where("Flag", "!=", false), true},
+		{"comparer", Where("Version", ">", version{1, 1}), true},
+		{"comparer equal", Where("Version", "=", version{1, 2}), true},
+		{"pointer receiver comparer", Where("Reverse", ">", reverse(20)), true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			pred, err := newKindPredicate(typed, tc.Filter)
+			Require(t,
+				NoError(err),
+			)
+			Expect(t,
+				Equal(tc.Expect, pred(obj)),
+			)
+		})
+	}
+	t.Run("nil pointer", func(t *testing.T) {
+		for _, op := range []string{"=", "!=", "<", ">"} {
+			pred, err := newKindPredicate(typed, Where("Score", op, 5))
+			Expect(t,
+				NoError(err),
+				Equalf(false, pred(&kindTest{}), "nil pointer shouldn't match %q", op),
+			)
+		}
+	})
+	t.Run("nil pointer value", func(t *testing.T) {
+		pred, err := newKindPredicate(typed, Where("Score", "=", (*int)(nil)))
+		Expect(t,
+			NoError(err),
+			Equal(true, pred(&kindTest{})),
+			Equal(false, pred(obj)),
+		)
+	})
+	t.Run("errors", func(t *testing.T) {
+		_, err := newKindPredicate(typed, Where("Status", "=", 1))
+		Expect(t,
+			IsError(errTypeMismatch, err),
+		)
+		_, err = newKindPredicate(typed, Where("Version", "=", "1.2"))
+		Expect(t,
+			IsError(errTypeMismatch, err),
+		)
+		_, err = newKindPredicate(typed, Where("Flag", "<", true))
+		Expect(t,
+			IsError(errInvalidOperator, err),
+		)
+		_, err = newKindPredicate(typed, Where("Count", "LIKE", 1))
+		Expect(t,
+			IsError(errInvalidOperator, err),
+		)
+		_, err = newKindPredicate(typed, Where("Count", "=", []int{1}))
+		Expect(t,
+			IsError(errTypeNotSupported, err),
+		)
+	})
+}
+
+func TestKindCmp(t *testing.T) {
+	t.Run("reflection", func(t *testing.T) { testKindCmp(t, false) })
+	t.Run("getters", func(t *testing.T) { testKindCmp(t, true) })
+}
+
+func testKindCmp(t *testing.T, typed bool) {
+	one, two := 1, 2
+	testCases := []struct {
+		Field string
+		Less  *kindTest
+		More  *kindTest
+	}{
+		{"Status", &kindTest{Status: "a"}, &kindTest{Status: "b"}},
+		{"Score", &kindTest{Score: &one}, &kindTest{Score: &two}},
+		{"Score", &kindTest{}, &kindTest{Score: &one}},
+		{"Flag", &kindTest{Flag: false}, &kindTest{Flag: true}},
+		{"Version", &kindTest{Version: version{1, 9}}, &kindTest{Version: version{2, 0}}},
+		{"Reverse", &kindTest{Reverse: 2}, &kindTest{Reverse: 1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Field, func(t *testing.T) {
+			cmp, err := newKindCmp(typed, By(tc.Field))
+			Require(t,
+				NoError(err),
+			)
+			desc, err := newKindCmp(typed, By(tc.Field).Desc())
+			Require(t,
+				NoError(err),
+			)
+			Expect(t,
+				Equal(-1, min(1, max(-1, cmp(tc.Less, tc.More)))),
+				Equal(1, min(1, max(-1, cmp(tc.More, tc.Less)))),
+				Equal(0, cmp(tc.Less, tc.Less)),
+				Equal(1, min(1, max(-1, desc(tc.Less, tc.More)))),
+			)
+		})
+	}
+}
+
+// newKindCmp is like newKindPredicate, for orderings.
+func newKindCmp(typed bool, order *OrderBySpec) (func(a, b *kindTest) int, error) {
+	if !typed {
+		return NewCmp[kindTest](order)
+	}
+	cmp, err := NewCmp[typedKindTest](order)
+	if err != nil {
+		return nil, err
+	}
+	return func(a, b *kindTest) int { return cmp((*typedKindTest)(a), (*typedKindTest)(b)) }, nil
+}
@@ -26,13 +26,24 @@ import (
 	"cmp"
 
 	"github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/fields"
 )
 
 func NewCmp[T any](order *store.OrderBySpec) (func(*T, *T) int, error) {
 	var zero T
-	if _, ok := reflect.TypeOf(zero).FieldByName(order.Field); !ok {
+	field, ok := reflect.TypeOf(zero).FieldByName(order.Field)
+	if !ok {
 		return nil, errNoSuchField
 	}
+	if order.Query != "" {
+		return relevanceCmp[T](order)
+	}
+	if compare, ok := fields.Comparator[T](order.Field); ok {
+		if order.Descending {
+			return func(a, b *T) int { return compare(b, a) }, nil
+		}
+		return compare, nil
+	}
 	v := reflect.ValueOf(zero).FieldByName(order.Field).Interface()
 	switch v.(type) {
 	case string:
@@ -64,7 +75,7 @@ func NewCmp[T any](order *store.OrderBySpec) (func(*T, *T) int, error) {
 	case time.Time:
 		return timeCmp[T](order)
 	}
-	return nil, errTypeNotSupported
+	return kindCmp[T](field, order)
 }
 
 func orderedCmp[T any, F cmp.Ordered](order *store.OrderBySpec) (func(*T, *T) int, error) {
@@ -43,6 +43,7 @@ type CmpTest struct {
 	Float64 float64
 	Time    time.Time
 	Ptr     *int
+	Chan    chan int
 }
 
 func TestNewCmp(t *testing.T) {
@@ -104,7 +105,7 @@ func TestNewCmpSupportedTypes(t *testing.T) {
 		"String",
 		"Int", "Int8", "Int16", "Int32", "Int64",
 		"UInt", "UInt8", "UInt16", "UInt32", "UInt64",
-		"Float32", "Float64", "Time",
+		"Float32", "Float64", "Time", "Ptr",
 	}
 	t.Parallel()
 	for _, field := range supported {
@@ -115,8 +116,8 @@ func TestNewCmpSupportedTypes(t *testing.T) {
 			)
 		})
 	}
-	t.Run("Ptr", func(t *testing.T) {
-		_, err := NewCmp[CmpTest](By("Ptr"))
+	t.Run("Chan", func(t *testing.T) {
+		_, err := NewCmp[CmpTest](By("Chan"))
 		Expect(t,
 			IsError(errTypeNotSupported, err),
 		)
@@ -28,6 +28,7 @@ import (
 	"cmp"
 
 	"github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/fields"
 )
 
 var (
@@ -36,6 +37,8 @@ var (
 
 func NewPredicate[T any](filter *store.FilterSpec) (func(*T) bool, error) {
 	switch {
+	case filter == nil:
+		return nil, errInvalidFilter
 	case filter.Where != nil:
 		return predicateFromWhereClause[T](filter.Where)
 	case filter.All != nil:
@@ -52,9 +55,22 @@ var (
 )
 
 func predicateFromWhereClause[T any](w *store.WhereClause) (func(*T) bool, error) {
-	if w.Value == nil {
+	if w.Op == "match" {
+		return matchPredicate[T](w.Field, w.Value)
+	}
+	if IsCollectionOperator(w.Op) {
+		return collectionPredicate[T](w.Field, w.Op, w.Value)
+	}
+	if v := reflect.ValueOf(w.Value); w.Value == nil || v.Kind() == reflect.Pointer && v.IsNil() {
 		return pointerPredicate[T](w.Field, w.Op)
 	}
+	field, err := structField[T](w.Field)
+	if err != nil {
+		return nil, err
+	}
+	if field.Type != reflect.TypeOf(w.Value) {
+		return kindPredicate[T](field, w.Op, w.Value)
+	}
 	switch t := w.Value.(type) {
 	case string:
 		return orderedPredicate[T](w.Field, w.Op, t)
@@ -70,6 +86,10 @@ func predicateFromWhereClause[T any](w *store.WhereClause) (func(*T) bool, error
 		return orderedPredicate[T](w.Field, w.Op, t)
 	case uint:
 		return orderedPredicate[T](w.Field, w.Op, t)
+	case uint8:
+		return orderedPredicate[T](w.Field, w.Op, t)
+	case uint16:
+		return orderedPredicate[T](w.Field, w.Op, t)
 	case uint32:
 		return orderedPredicate[T](w.Field, w.Op, t)
 	case uint64:
@@ -84,7 +104,7 @@ func predicateFromWhereClause[T any](w *store.WhereClause) (func(*T) bool, error
 		return timePredicate[T](w.Field, w.Op, t)
 	}
 
-	return nil, fmt.Errorf("%w: %s", errTypeNotSupported, reflect.TypeOf(w.Value).Name())
+	return kindPredicate[T](field, w.Op, w.Value)
 }
 
 func predicateAll[T any](all []*store.FilterSpec) (func(*T) bool, error) {
@@ -190,18 +210,20 @@ func pointerPredicate[T any](field string, op string) (func(*T) bool, error) {
 		return nil, fmt.Errorf("%w: %s is not a pointer", errTypeMismatch, f.Type.Name())
 	}
 	fieldIndex := f.Index
+	isNil, ok := fields.NilChecker[T](field)
+	if !ok {
+		isNil = func(obj *T) bool {
+			return reflect.ValueOf(obj).Elem().FieldByIndex(fieldIndex).IsNil()
+		}
+	}
 
 	var pred func(*T) bool
 	var err error
 	switch op {
 	case "=":
-		pred = func(obj *T) bool {
-			return reflect.ValueOf(obj).Elem().FieldByIndex(fieldIndex).IsNil()
-		}
+		pred = isNil
 	case "!=":
-		pred = func(obj *T) bool {
-			return !reflect.ValueOf(obj).Elem().FieldByIndex(fieldIndex).IsNil()
-		}
+		pred = func(obj *T) bool { return !isNil(obj) }
 	default:
 		err = fmt.Errorf("%w: %q not supported with pointers",
 			errInvalidOperator, op,
@@ -23,6 +23,8 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+
+	"github.com/ArnaudCalmettes/store/fields"
 )
 
 var (
@@ -31,20 +33,17 @@ var (
 	errTypeMismatch = errors.New("type mismatch")
 )
 
+// FieldSelector returns a function that reads the field called name, which
+// must be of type K. Generated accessors are used if they are registered
+// (see package fields), and reflection otherwise.
 func FieldSelector[T, K any](name string) (func(*T) K, error) {
-	var zeroStruct T
-	var zeroValue K
-	structType := reflect.TypeOf(zeroStruct)
-	fieldType := reflect.TypeOf(zeroValue)
-
-	if structType.Kind() != reflect.Struct {
-		return nil, fmt.Errorf("%s is %w", structType.Name(), errNotAStruct)
+	if getter, ok := fields.Getter[T, K](name); ok {
+		return getter, nil
 	}
-	field, ok := structType.FieldByName(name)
-	if !ok {
-		return nil, fmt.Errorf("%w: %q in type %s",
-			errNoSuchField, name, structType.Name(),
-		)
+	fieldType := reflect.TypeFor[K]()
+	field, err := structField[T](name)
+	if err != nil {
+		return nil, err
 	}
 	if field.Type != fieldType {
 		return nil, fmt.Errorf("%w: field %s is of type %s, not %s",
@@ -52,12 +51,26 @@ func FieldSelector[T, K any](name string) (func(*T) K, error) {
 		)
 	}
 	selector := func(obj *T) K {
-		val := reflect.ValueOf(*obj)
+		val := reflect.ValueOf(obj).Elem()
 		return val.FieldByIndex(field.Index).Interface().(K)
 	}
 	return selector, nil
 }
 
+func structField[T any](name string) (reflect.StructField, error) {
+	structType := reflect.TypeFor[T]()
+	if structType.Kind() != reflect.Struct {
+		return reflect.StructField{}, fmt.Errorf("%s is %w", structType.Name(), errNotAStruct)
+	}
+	field, ok := structType.FieldByName(name)
+	if !ok {
+		return reflect.StructField{}, fmt.Errorf("%w: %q in type %s",
+			errNoSuchField, name, structType.Name(),
+		)
+	}
+	return field, nil
+}
+
 func StringFieldSetter[T any](name string) (func(*T, string), error) {
 	var zeroStruct T
 	typ := reflect.TypeOf(zeroStruct)
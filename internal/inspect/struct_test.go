@@ -23,6 +23,8 @@ import (
 	"testing"
 	"time"
 
+	. "github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/fields"
 	. "github.com/ArnaudCalmettes/store/test/helpers"
 )
 
@@ -114,6 +116,66 @@ func TestFieldSelector(t *testing.T) {
 	})
 }
 
+type generated struct {
+	Name string
+	Ptr  *int
+}
+
+func TestGeneratedAccessors(t *testing.T) {
+	// These accessors disagree with reflection, to tell which one is used.
+	fields.Register(&fields.Accessors[generated]{
+		Getters: map[string]any{
+			"Name": func(obj *generated) string { return "generated " + obj.Name },
+			"Ptr": func(obj *generated) *int {
+				answer := 42
+				return &answer
+			},
+		},
+		Comparators: map[string]func(a, b *generated) int{
+			"Name": func(a, b *generated) int { return len(a.Name) - len(b.Name) },
+		},
+		NilCheckers: map[string]func(*generated) bool{
+			"Ptr": func(obj *generated) bool { return true },
+		},
+	})
+	one := 1
+
+	get, err := FieldSelector[generated, string]("Name")
+	Expect(t,
+		NoError(err),
+		Equal("generated foo", get(&generated{Name: "foo"})),
+	)
+	pred, err := NewPredicate[generated](Where("Name", "=", "generated foo"))
+	Expect(t,
+		NoError(err),
+		Equal(true, pred(&generated{Name: "foo"})),
+	)
+	pred, err = NewPredicate[generated](Where("Ptr", "=", nil))
+	Expect(t,
+		NoError(err),
+		Equal(true, pred(&generated{Ptr: &one})),
+	)
+	pred, err = NewPredicate[generated](Where("Ptr", ">", 40))
+	Expect(t,
+		NoError(err),
+		Equal(true, pred(&generated{})),
+	)
+	cmp, err := NewCmp[generated](By("Name").Desc())
+	Expect(t,
+		NoError(err),
+		Equal(-1, cmp(&generated{Name: "bb"}, &generated{Name: "a"})),
+	)
+	cmp, err = NewCmp[generated](By("Ptr"))
+	Expect(t,
+		NoError(err),
+		Equal(0, cmp(&generated{}, &generated{Ptr: &one})),
+	)
+	_, err = FieldSelector[generated, int]("Name")
+	Expect(t,
+		IsError(errTypeMismatch, err),
+	)
+}
+
 func TestStringFieldSetter(t *testing.T) {
 	type MyStruct struct {
 		ID   string
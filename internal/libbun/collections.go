@@ -0,0 +1,155 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package libbun
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+
+	"github.com/ArnaudCalmettes/store"
+)
+
+// Collection operators (see inspect.IsCollectionOperator) are mapped to
+// array operators with PostgreSQL arrays, to jsonb containment with
+// PostgreSQL JSON columns, and to json_each subqueries with SQLite, which
+// can't store arrays.
+
+var (
+	errNotACollection    = errors.New("field isn't a slice or map stored as JSON or as an array")
+	errCollectionDialect = errors.New("collection operator isn't supported by dialect")
+	errCollectionValue   = errors.New("invalid collection operand")
+)
+
+// collectionQuery returns the condition and the arguments that apply a
+// collection operator.
+func collectionQuery(w *store.WhereClause, spec *TableSpec, d dialect.Name) (string, []any, error) {
+	column := spec.ColumnNames[w.Field]
+	kind := spec.Collections[column]
+	switch {
+	case kind == 0:
+		return "", nil, fmt.Errorf("%w: %s", errNotACollection, w.Field)
+	case (w.Op == "has_key") != (kind == JSONObject):
+		return "", nil, fmt.Errorf("%w: %q with field %s", errInvalidOperator, w.Op, w.Field)
+	case d != dialect.PG && (d != dialect.SQLite || kind == NativeArray):
+		return "", nil, fmt.Errorf("%w: %q with field %s on %s", errCollectionDialect, w.Op, w.Field, d)
+	}
+
+	values, err := collectionValues(w)
+	if err != nil {
+		return "", nil, err
+	}
+	ident := bun.Ident(column)
+	if w.Op == "has_key" {
+		key, err := jsonKey(values[0])
+		if err != nil {
+			return "", nil, err
+		}
+		if d == dialect.PG {
+			return "jsonb_exists(?, ?)", []any{ident, key}, nil
+		}
+		return "EXISTS (SELECT 1 FROM json_each(?) WHERE key = ?)", []any{ident, key}, nil
+	}
+	if w.Op == "contains_all" && d == dialect.PG && kind == JSONArray {
+		array, err := json.Marshal(values)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %w", errCollectionValue, err)
+		}
+		return "? @> ?::jsonb", []any{ident, string(array)}, nil
+	}
+
+	var conds []string
+	var args []any
+	for _, value := range values {
+		switch {
+		case kind == NativeArray:
+			conds = append(conds, "? = ANY(?)")
+			args = append(args, value, ident)
+		case d == dialect.PG:
+			array, err := json.Marshal([]any{value})
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: %w", errCollectionValue, err)
+			}
+			conds = append(conds, "? @> ?::jsonb")
+			args = append(args, ident, string(array))
+		default:
+			conds = append(conds, "EXISTS (SELECT 1 FROM json_each(?) WHERE value = ?)")
+			args = append(args, ident, value)
+		}
+	}
+	switch {
+	case w.Op == "contains_all" && len(conds) == 0:
+		return "1 = 1", nil, nil
+	case len(conds) == 0:
+		return "1 = 0", nil, nil
+	case w.Op == "contains_all":
+		return strings.Join(conds, " AND "), args, nil
+	}
+	return strings.Join(conds, " OR "), args, nil
+}
+
+// collectionValues returns the values that the elements of a collection are
+// compared with.
+func collectionValues(w *store.WhereClause) ([]any, error) {
+	var values []any
+	switch v := reflect.ValueOf(w.Value); w.Op {
+	case "contains_any", "contains_all":
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, fmt.Errorf("%w: %q expects a slice, not %T", errCollectionValue, w.Op, w.Value)
+		}
+		for i := range v.Len() {
+			values = append(values, v.Index(i).Interface())
+		}
+	default:
+		values = []any{w.Value}
+	}
+	for i, value := range values {
+		value, err := filterValue(value)
+		if err != nil {
+			return nil, err
+		}
+		switch value.(type) {
+		case string, int64, uint64, float64, bool:
+		default:
+			return nil, fmt.Errorf("%w: %q with %T", errCollectionValue, w.Op, values[i])
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// jsonKey returns the key of a JSON object that encodes a map key.
+func jsonKey(value any) (string, error) {
+	switch k := value.(type) {
+	case string:
+		return k, nil
+	case int64:
+		return strconv.FormatInt(k, 10), nil
+	case uint64:
+		return strconv.FormatUint(k, 10), nil
+	}
+	return "", fmt.Errorf("%w: %T isn't a valid JSON object key", errCollectionValue, value)
+}
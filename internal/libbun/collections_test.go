@@ -0,0 +1,160 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package libbun
+
+import (
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+type taggedItem struct {
+	bun.BaseModel `bun:"table:items,alias:i"`
+
+	ID     string `bun:",pk"`
+	Tags   []string
+	Codes  []int `bun:",array"`
+	Labels map[string]string
+	Attrs  map[string]string `bun:",hstore"`
+	Blob   []byte
+}
+
+func TestCollectionFilters(t *testing.T) {
+	var model []*taggedItem
+	tableSpec, err := GetTableSpec[taggedItem]()
+	Require(t,
+		NoError(err),
+	)
+	Expect(t,
+		Equal(map[string]CollectionKind{
+			"tags":   JSONArray,
+			"codes":  NativeArray,
+			"labels": JSONObject,
+		}, tableSpec.Collections),
+	)
+
+	testCases := []struct {
+		Name    string
+		Dialect dialect.Name
+		Filter  *FilterSpec
+		Expect  string
+	}{
+		{
+			"sqlite contains", dialect.SQLite,
+			Where("Tags", "contains", "go"),
+			`EXISTS (SELECT 1 FROM json_each("tags") WHERE value = 'go')`,
+		},
+		{
+			"sqlite contains any", dialect.SQLite,
+			Where("Tags", "contains_any", []string{"go", "sql"}),
+			`EXISTS (SELECT 1 FROM json_each("tags") WHERE value = 'go') OR ` +
+				`EXISTS (SELECT 1 FROM json_each("tags") WHERE value = 'sql')`,
+		},
+		{
+			"sqlite contains all", dialect.SQLite,
+			Where("Tags", "contains_all", []string{"go", "sql"}),
+			`EXISTS (SELECT 1 FROM json_each("tags") WHERE value = 'go') AND ` +
+				`EXISTS (SELECT 1 FROM json_each("tags") WHERE value = 'sql')`,
+		},
+		{
+			"sqlite has key", dialect.SQLite,
+			Where("Labels", "has_key", "env"),
+			`EXISTS (SELECT 1 FROM json_each("labels") WHERE key = 'env')`,
+		},
+		{
+			"pg json contains any", dialect.PG,
+			Where("Tags", "contains_any", []string{"go", "sql"}),
+			`"tags" @> '["go"]'::jsonb OR "tags" @> '["sql"]'::jsonb`,
+		},
+		{
+			"pg json contains all", dialect.PG,
+			Where("Tags", "contains_all", []string{"go", "sql"}),
+			`"tags" @> '["go","sql"]'::jsonb`,
+		},
+		{
+			"pg array contains all", dialect.PG,
+			Where("Codes", "contains_all", []int{1, 2}),
+			`1 = ANY("codes") AND 2 = ANY("codes")`,
+		},
+		{
+			"pg has key", dialect.PG,
+			Where("Labels", "has_key", "env"),
+			`jsonb_exists("labels", 'env')`,
+		},
+		{
+			"contains any nothing", dialect.PG,
+			Where("Codes", "contains_any", []int{}),
+			`1 = 0`,
+		},
+		{
+			"contains all nothing", dialect.SQLite,
+			Where("Tags", "contains_all", []string{}),
+			`1 = 1`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			db := bun.NewDB(nil, sqlitedialect.New())
+			if tc.Dialect == dialect.PG {
+				db = bun.NewDB(nil, pgdialect.New())
+			}
+			builder, err := BuilderForFilter(tc.Filter, tableSpec, tc.Dialect)
+			Require(t,
+				NoError(err),
+			)
+			query := db.NewSelect().Model(&model).Column("id").ApplyQueryBuilder(builder).String()
+			Expect(t,
+				Equal(`SELECT "i"."id" FROM "items" AS "i" WHERE (`+tc.Expect+`)`, query),
+			)
+		})
+	}
+
+	errorCases := []struct {
+		Name    string
+		Dialect dialect.Name
+		Filter  *FilterSpec
+		Expect  error
+	}{
+		{"not a collection", dialect.PG, Where("ID", "contains", "a"), errNotACollection},
+		{"hstore", dialect.PG, Where("Attrs", "has_key", "a"), errNotACollection},
+		{"bytes", dialect.PG, Where("Blob", "contains", 1), errNotACollection},
+		{"has_key on a slice", dialect.PG, Where("Tags", "has_key", "a"), errInvalidOperator},
+		{"contains on a map", dialect.PG, Where("Labels", "contains", "a"), errInvalidOperator},
+		{"sqlite array", dialect.SQLite, Where("Codes", "contains", 1), errCollectionDialect},
+		{"mysql", dialect.MySQL, Where("Tags", "contains", "a"), errCollectionDialect},
+		{"not a slice", dialect.PG, Where("Tags", "contains_any", "a"), errCollectionValue},
+		{"invalid element", dialect.PG, Where("Tags", "contains", []string{"a"}), errUnsupportedValue},
+		{"nil element", dialect.PG, Where("Tags", "contains", nil), errCollectionValue},
+		{"invalid key", dialect.PG, Where("Labels", "has_key", 1.5), errCollectionValue},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := BuilderForFilter(tc.Filter, tableSpec, tc.Dialect)
+			Expect(t,
+				IsError(tc.Expect, err),
+			)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package libbun
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+
+	"github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/internal/inspect"
+)
+
+// Full-text search relies on FTS5 with SQLite, where the full-text columns of
+// a table are indexed in a separate table (see FullTextTable), and on
+// tsvectors with the 'simple' configuration with PostgreSQL. In both cases,
+// queries are tokenized with inspect.Tokenize, and match the items that
+// contain all their words.
+
+var (
+	errNotFullText         = errors.New(`field isn't indexed for full-text search (missing store:"fulltext" tag)`)
+	errFullTextDialect     = errors.New("full-text search isn't supported by dialect")
+	errEmptyQuery          = errors.New("full-text query has no words")
+	errInvalidMatchOperand = errors.New(`"match" expects a string query`)
+)
+
+// FullTextTable returns the name of the FTS5 table that indexes the
+// full-text columns of t with SQLite.
+func (t *TableSpec) FullTextTable() string {
+	return t.TableName + "_fts"
+}
+
+func validateMatch(w *store.WhereClause, spec *TableSpec, d dialect.Name) error {
+	query, ok := w.Value.(string)
+	if !ok {
+		return fmt.Errorf("%w, not %T", errInvalidMatchOperand, w.Value)
+	}
+	_, _, err := fullTextTerms(w.Field, query, spec, d)
+	return err
+}
+
+func fullTextTerms(field, query string, spec *TableSpec, d dialect.Name) (string, []string, error) {
+	if d != dialect.SQLite && d != dialect.PG {
+		return "", nil, fmt.Errorf("%w: %s", errFullTextDialect, d)
+	}
+	column := spec.ColumnNames[field]
+	if !slices.Contains(spec.FullTextColumns, column) {
+		return "", nil, fmt.Errorf("%w: %s", errNotFullText, field)
+	}
+	terms := inspect.Tokenize(query)
+	if len(terms) == 0 {
+		return "", nil, errEmptyQuery
+	}
+	return column, terms, nil
+}
+
+// ftsQuery returns an FTS5 query matching the rows whose column contains all
+// the given terms.
+func ftsQuery(column string, terms []string) string {
+	conds := make([]string, len(terms))
+	for i, term := range terms {
+		conds[i] = fmt.Sprintf(`{%s} : "%s"`, column, term)
+	}
+	return strings.Join(conds, " AND ")
+}
+
+func applyMatch(qb bun.QueryBuilder, w *store.WhereClause, spec *TableSpec, d dialect.Name) bun.QueryBuilder {
+	column, terms, _ := fullTextTerms(w.Field, w.Value.(string), spec, d)
+	if d == dialect.SQLite {
+		return qb.Where("rowid IN (SELECT rowid FROM ?0 WHERE ?0 MATCH ?1)",
+			bun.Ident(spec.FullTextTable()), ftsQuery(column, terms),
+		)
+	}
+	return qb.Where("to_tsvector('simple', ?) @@ plainto_tsquery('simple', ?)",
+		bun.Ident(column), strings.Join(terms, " "),
+	)
+}
+
+// RelevanceOrder returns an ORDER BY expression and its arguments, that
+// orders rows by relevance to a full-text query.
+func RelevanceOrder(order *store.OrderBySpec, spec *TableSpec, d dialect.Name) (string, []any, error) {
+	column, terms, err := fullTextTerms(order.Field, order.Query, spec, d)
+	if err != nil {
+		return "", nil, err
+	}
+	if d == dialect.SQLite {
+		// bm25 is lower for more relevant rows, and rows that don't match
+		// have no score.
+		expr := "(SELECT bm25(?0) FROM ?0 WHERE ?0 MATCH ?1 AND ?0.rowid = ?TableAlias.rowid)"
+		if order.Descending {
+			expr += " DESC NULLS FIRST"
+		} else {
+			expr += " ASC NULLS LAST"
+		}
+		return expr, []any{bun.Ident(spec.FullTextTable()), ftsQuery(column, terms)}, nil
+	}
+	// Normalization 2 divides the rank by the length of the document.
+	expr := "ts_rank(to_tsvector('simple', ?), plainto_tsquery('simple', ?), 2)"
+	if order.Descending {
+		expr += " ASC"
+	} else {
+		expr += " DESC"
+	}
+	return expr, []any{bun.Ident(column), strings.Join(terms, " ")}, nil
+}
@@ -24,35 +24,51 @@ import (
 	"fmt"
 
 	"github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/internal/inspect"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 )
 
-func BuilderForFilter(filter *store.FilterSpec, spec *TableSpec) (
+func BuilderForFilter(filter *store.FilterSpec, spec *TableSpec, d dialect.Name) (
 	func(bun.QueryBuilder) bun.QueryBuilder,
 	error,
 ) {
-	if err := validateFilter(filter, spec); err != nil {
+	if err := validateFilter(filter, spec, d); err != nil {
 		return nil, err
 	}
 	builder := func(qb bun.QueryBuilder) bun.QueryBuilder {
-		return applyFilter(qb, filter, spec)
+		return applyFilter(qb, filter, spec, d)
 	}
 	return builder, nil
 }
 
-func applyFilter(qb bun.QueryBuilder, filter *store.FilterSpec, spec *TableSpec) bun.QueryBuilder {
+func applyFilter(qb bun.QueryBuilder, filter *store.FilterSpec, spec *TableSpec, d dialect.Name) bun.QueryBuilder {
 	switch {
+	case filter.Where != nil && filter.Where.Op == "match":
+		qb = applyMatch(qb, filter.Where, spec, d)
+	case filter.Where != nil && inspect.IsCollectionOperator(filter.Where.Op):
+		query, args, _ := collectionQuery(filter.Where, spec, d)
+		qb = qb.Where(query, args...)
 	case filter.Where != nil:
 		w := filter.Where
-		qb = qb.Where("? "+w.Op+" ?", bun.Ident(spec.ColumnNames[w.Field]), w.Value)
+		column := bun.Ident(spec.ColumnNames[w.Field])
+		value, _ := filterValue(w.Value)
+		switch {
+		case value == nil && w.Op == "=":
+			qb = qb.Where("? IS NULL", column)
+		case value == nil:
+			qb = qb.Where("? IS NOT NULL", column)
+		default:
+			qb = qb.Where("? "+w.Op+" ?", column, value)
+		}
 	case filter.All != nil:
 		for _, sub := range filter.All {
-			qb = applyFilter(qb, sub, spec)
+			qb = applyFilter(qb, sub, spec, d)
 		}
 	case filter.Any != nil:
 		for _, sub := range filter.Any {
 			qb.WhereGroup(" OR ", func(q bun.QueryBuilder) bun.QueryBuilder {
-				return applyFilter(qb, sub, spec)
+				return applyFilter(qb, sub, spec, d)
 			})
 		}
 	}
@@ -61,27 +77,48 @@ func applyFilter(qb bun.QueryBuilder, filter *store.FilterSpec, spec *TableSpec)
 
 var (
 	errNoSuchField = errors.New("no such field")
+	errEmptyFilter = errors.New("empty filter")
+
+	errInvalidOperator = errors.New("invalid operator")
 )
 
-func validateFilter(filter *store.FilterSpec, spec *TableSpec) error {
+func validateFilter(filter *store.FilterSpec, spec *TableSpec, d dialect.Name) error {
 	switch {
+	case filter == nil:
+		return errEmptyFilter
 	case filter.Where != nil:
 		field := filter.Where.Field
 		if _, ok := spec.ColumnNames[field]; !ok {
 			return fmt.Errorf("%w: %s", errNoSuchField, field)
 		}
+		if filter.Where.Op == "match" {
+			return validateMatch(filter.Where, spec, d)
+		}
+		if inspect.IsCollectionOperator(filter.Where.Op) {
+			_, _, err := collectionQuery(filter.Where, spec, d)
+			return err
+		}
+		value, err := filterValue(filter.Where.Value)
+		if err != nil {
+			return err
+		}
+		if value == nil && filter.Where.Op != "=" && filter.Where.Op != "!=" {
+			return fmt.Errorf("%w: %q with a nil value", errInvalidOperator, filter.Where.Op)
+		}
 	case filter.All != nil:
 		errs := make([]error, len(filter.All))
 		for i, sub := range filter.All {
-			errs[i] = validateFilter(sub, spec)
+			errs[i] = validateFilter(sub, spec, d)
 		}
 		return errors.Join(errs...)
 	case filter.Any != nil:
 		errs := make([]error, len(filter.Any))
 		for i, sub := range filter.Any {
-			errs[i] = validateFilter(sub, spec)
+			errs[i] = validateFilter(sub, spec, d)
 		}
 		return errors.Join(errs...)
+	default:
+		return errEmptyFilter
 	}
 	return nil
 }
@@ -25,6 +25,8 @@ import (
 	. "github.com/ArnaudCalmettes/store"
 	. "github.com/ArnaudCalmettes/store/test/helpers"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/dialect/sqlitedialect"
 )
 
@@ -49,7 +51,7 @@ func TestFilterBuilder(t *testing.T) {
 	t.Run("nominal", func(t *testing.T) {
 		builder, err := BuilderForFilter(
 			Where("ID", "!=", ""),
-			tableSpec,
+			tableSpec, dialect.SQLite,
 		)
 		Expect(t,
 			NoError(err),
@@ -67,19 +69,29 @@ func TestFilterBuilder(t *testing.T) {
 	t.Run("unknown field", func(t *testing.T) {
 		_, err := BuilderForFilter(
 			Where("BankAccount", ">", 100),
-			tableSpec,
+			tableSpec, dialect.SQLite,
 		)
 		Expect(t,
 			IsError(errNoSuchField, err),
 		)
 	})
+	t.Run("empty filter", func(t *testing.T) {
+		_, err := BuilderForFilter(&FilterSpec{}, tableSpec, dialect.SQLite)
+		Expect(t,
+			IsError(errEmptyFilter, err),
+		)
+		_, err = BuilderForFilter(nil, tableSpec, dialect.SQLite)
+		Expect(t,
+			IsError(errEmptyFilter, err),
+		)
+	})
 	t.Run("coumpound", func(t *testing.T) {
 		builder, err := BuilderForFilter(
 			Any(
 				All(Where("ID", "!=", ""), Where("Age", ">", 18)),
 				Where("Name", "!=", "foo"),
 			),
-			tableSpec,
+			tableSpec, dialect.SQLite,
 		)
 		Expect(t,
 			NoError(err),
@@ -96,3 +108,87 @@ func TestFilterBuilder(t *testing.T) {
 		)
 	})
 }
+
+func TestMatchFilter(t *testing.T) {
+	type Article struct {
+		bun.BaseModel `bun:"table:articles,alias:a"`
+
+		ID    string `bun:",pk"`
+		Title string
+		Body  string `store:"fulltext"`
+	}
+	var model []*Article
+
+	tableSpec, err := GetTableSpec[Article]()
+	Require(t,
+		NoError(err),
+	)
+
+	t.Run("sqlite", func(t *testing.T) {
+		db := bun.NewDB(nil, sqlitedialect.New())
+		builder, err := BuilderForFilter(Match("Body", "Hello, World!"), tableSpec, dialect.SQLite)
+		Require(t,
+			NoError(err),
+		)
+		expr, args, err := RelevanceOrder(ByRelevance("Body", "hello"), tableSpec, dialect.SQLite)
+		Require(t,
+			NoError(err),
+		)
+		query := db.NewSelect().Model(&model).Column("id").
+			ApplyQueryBuilder(builder).OrderExpr(expr, args...).String()
+		Expect(t,
+			Equal(
+				`SELECT "a"."id" FROM "articles" AS "a" WHERE `+
+					`(rowid IN (SELECT rowid FROM "articles_fts" WHERE "articles_fts" MATCH `+
+					`'{body} : "hello" AND {body} : "world"')) `+
+					`ORDER BY (SELECT bm25("articles_fts") FROM "articles_fts" WHERE `+
+					`"articles_fts" MATCH '{body} : "hello"' AND "articles_fts".rowid = "a".rowid) `+
+					`ASC NULLS LAST`,
+				query,
+			),
+		)
+	})
+	t.Run("postgres", func(t *testing.T) {
+		db := bun.NewDB(nil, pgdialect.New())
+		builder, err := BuilderForFilter(Match("Body", "Hello, World!"), tableSpec, dialect.PG)
+		Require(t,
+			NoError(err),
+		)
+		expr, args, err := RelevanceOrder(ByRelevance("Body", "hello").Desc(), tableSpec, dialect.PG)
+		Require(t,
+			NoError(err),
+		)
+		query := db.NewSelect().Model(&model).Column("id").
+			ApplyQueryBuilder(builder).OrderExpr(expr, args...).String()
+		Expect(t,
+			Equal(
+				`SELECT "a"."id" FROM "articles" AS "a" WHERE `+
+					`(to_tsvector('simple', "body") @@ plainto_tsquery('simple', 'hello world')) `+
+					`ORDER BY ts_rank(to_tsvector('simple', "body"), plainto_tsquery('simple', 'hello'), 2) ASC`,
+				query,
+			),
+		)
+	})
+	t.Run("errors", func(t *testing.T) {
+		_, err := BuilderForFilter(Match("Title", "hello"), tableSpec, dialect.SQLite)
+		Expect(t,
+			IsError(errNotFullText, err),
+		)
+		_, err = BuilderForFilter(Match("Body", "?!"), tableSpec, dialect.SQLite)
+		Expect(t,
+			IsError(errEmptyQuery, err),
+		)
+		_, err = BuilderForFilter(Where("Body", "match", 42), tableSpec, dialect.SQLite)
+		Expect(t,
+			IsError(errInvalidMatchOperand, err),
+		)
+		_, err = BuilderForFilter(Match("Body", "hello"), tableSpec, dialect.MySQL)
+		Expect(t,
+			IsError(errFullTextDialect, err),
+		)
+		_, _, err = RelevanceOrder(ByRelevance("Title", "hello"), tableSpec, dialect.PG)
+		Expect(t,
+			IsError(errNotFullText, err),
+		)
+	})
+}
@@ -25,45 +25,76 @@ import (
 	"strings"
 
 	"github.com/uptrace/bun"
+
+	"github.com/ArnaudCalmettes/store/internal/inspect"
 )
 
 type TableSpec struct {
 	TableName   string
-	KeyField    string
-	KeySQL      string
+	KeyFields   []string
+	KeyColumns  []string
 	ColumnNames map[string]string
+	Indexes     []string
+	// SoftDeleteColumn is the column tagged with bun's soft_delete option,
+	// if any.
+	SoftDeleteColumn string
+	// CreatedAtColumn, UpdatedAtColumn and VersionColumn are the columns
+	// tagged with store:"created_at", store:"updated_at" and store:"version",
+	// if any.
+	CreatedAtColumn string
+	UpdatedAtColumn string
+	VersionColumn   string
+	// FullTextColumns are the columns tagged with store:"fulltext", that can
+	// be searched with the "match" operator.
+	FullTextColumns []string
+	// Collections maps the columns of slice, array and map fields to the way
+	// they are stored.
+	Collections map[string]CollectionKind
 }
 
+// CollectionKind tells how bun stores a slice, array or map field.
+type CollectionKind int
+
+const (
+	// JSONArray is a slice or array stored as a JSON array.
+	JSONArray CollectionKind = iota + 1
+	// JSONObject is a map stored as a JSON object.
+	JSONObject
+	// NativeArray is a slice or array tagged with bun's array option, that
+	// PostgreSQL stores as an array.
+	NativeArray
+)
+
 var (
-	errMissingTableName = errors.New("missing table name")
-	errNoPK             = errors.New("primary key not configured")
-	errNoColumns        = errors.New("struct doesn't have any exported fields")
+	ErrMissingTableName = errors.New("missing table name")
+	ErrNoPK             = errors.New("primary key not configured")
+	ErrNoColumns        = errors.New("struct doesn't have any exported fields")
 )
 
-func (t *TableSpec) Validate() error {
-	errs := make([]error, 0, 5)
+// Problems lists everything that prevents t from being used by a store.
+func (t *TableSpec) Problems() []error {
+	errs := make([]error, 0, 3)
 	if t.TableName == "" {
-		errs = append(errs, errMissingTableName)
+		errs = append(errs, ErrMissingTableName)
 	}
-	if t.KeyField == "" || t.KeySQL == "" {
-		errs = append(errs, errNoPK)
+	if len(t.KeyFields) == 0 || len(t.KeyFields) != len(t.KeyColumns) {
+		errs = append(errs, ErrNoPK)
 	}
 	if len(t.ColumnNames) == 0 {
-		errs = append(errs, errNoColumns)
+		errs = append(errs, ErrNoColumns)
 	}
-	return errors.Join(errs...)
+	return errs
 }
 
 var (
 	baseModelType = reflect.TypeOf(bun.BaseModel{})
-	errNotAStruct = errors.New("not a struct")
+	ErrNotAStruct = errors.New("not a struct")
 )
 
 func GetTableSpec[T any]() (*TableSpec, error) {
-	var zeroStruct T
-	typ := reflect.TypeOf(zeroStruct)
+	typ := reflect.TypeFor[T]()
 	if typ.Kind() != reflect.Struct {
-		return nil, errNotAStruct
+		return nil, ErrNotAStruct
 	}
 	spec := &TableSpec{
 		ColumnNames: map[string]string{},
@@ -85,14 +116,61 @@ func GetTableSpec[T any]() (*TableSpec, error) {
 			column = toColumnName(name)
 		}
 		spec.ColumnNames[name] = column
+		if hasBunOption(tag, "soft_delete") {
+			spec.SoftDeleteColumn = column
+		}
+		if kind := collectionKind(field.Type, tag); kind != 0 {
+			if spec.Collections == nil {
+				spec.Collections = map[string]CollectionKind{}
+			}
+			spec.Collections[column] = kind
+		}
 		if isPK(tag) {
-			spec.KeyField = name
-			spec.KeySQL = column
+			spec.KeyFields = append(spec.KeyFields, name)
+			spec.KeyColumns = append(spec.KeyColumns, column)
+		}
+		storeTag := field.Tag.Get("store")
+		if inspect.HasStoreOption(storeTag, "index") {
+			spec.Indexes = append(spec.Indexes, column)
+		}
+		if inspect.HasStoreOption(storeTag, "fulltext") {
+			spec.FullTextColumns = append(spec.FullTextColumns, column)
+		}
+		switch {
+		case inspect.HasStoreOption(storeTag, "created_at"):
+			spec.CreatedAtColumn = column
+		case inspect.HasStoreOption(storeTag, "updated_at"):
+			spec.UpdatedAtColumn = column
+		case inspect.HasStoreOption(storeTag, "version"):
+			spec.VersionColumn = column
 		}
 	}
 	return spec, nil
 }
 
+func collectionKind(typ reflect.Type, tag string) CollectionKind {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array:
+		switch {
+		case typ.Elem().Kind() == reflect.Uint8:
+			// Bytes are stored as blobs.
+			return 0
+		case hasBunOption(tag, "array"):
+			return NativeArray
+		}
+		return JSONArray
+	case reflect.Map:
+		if hasBunOption(tag, "hstore") {
+			return 0
+		}
+		return JSONObject
+	}
+	return 0
+}
+
 func parseTableName(tag string) string {
 	options := strings.Split(tag, ",")
 	for _, option := range options {
@@ -114,3 +192,13 @@ func getColumnNameFromTag(tag string) string {
 func isPK(tag string) bool {
 	return strings.Contains(tag, ",pk")
 }
+
+func hasBunOption(tag string, option string) bool {
+	_, options, _ := strings.Cut(tag, ",")
+	for _, opt := range strings.Split(options, ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
@@ -20,7 +20,9 @@
 package libbun
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	. "github.com/ArnaudCalmettes/store/test/helpers"
 	"github.com/uptrace/bun"
@@ -36,8 +38,8 @@ func TestGetTableSpec(t *testing.T) {
 			bun.BaseModel `bun:"table:users"`
 
 			ID        string `bun:",pk"`
-			FirstName string
-			Ignored   int `bun:"-"`
+			FirstName string `store:"index"`
+			Ignored   int    `bun:"-"`
 			Embedded
 		}
 		spec, err := GetTableSpec[Model]()
@@ -45,15 +47,16 @@ func TestGetTableSpec(t *testing.T) {
 			NoError(err),
 			Equal(
 				&TableSpec{
-					TableName: "users",
-					KeyField:  "ID",
-					KeySQL:    "id",
+					TableName:  "users",
+					KeyFields:  []string{"ID"},
+					KeyColumns: []string{"id"},
 					ColumnNames: map[string]string{
 						"ID":          "id",
 						"FirstName":   "first_name",
 						"Addr":        "email_address",
 						"PhoneNumber": "phone_number",
 					},
+					Indexes: []string{"first_name"},
 				},
 				spec,
 			),
@@ -74,16 +77,77 @@ func TestGetTableSpec(t *testing.T) {
 			),
 		)
 	})
+	t.Run("composite key", func(t *testing.T) {
+		type Model struct {
+			bun.BaseModel `bun:"table:memberships"`
+
+			UserID  int64  `bun:",pk"`
+			GroupID string `bun:"group,pk"`
+			Role    string
+		}
+		spec, err := GetTableSpec[Model]()
+		Expect(t,
+			NoError(err),
+			Equal([]string{"UserID", "GroupID"}, spec.KeyFields),
+			Equal([]string{"user_id", "group"}, spec.KeyColumns),
+		)
+	})
+	t.Run("soft delete", func(t *testing.T) {
+		type Model struct {
+			bun.BaseModel `bun:"table:users"`
+
+			ID        string    `bun:",pk"`
+			DeletedAt time.Time `bun:"removed_at,soft_delete,nullzero"`
+		}
+		spec, err := GetTableSpec[Model]()
+		Expect(t,
+			NoError(err),
+			Equal("removed_at", spec.SoftDeleteColumn),
+		)
+	})
+	t.Run("automatic fields", func(t *testing.T) {
+		type Model struct {
+			bun.BaseModel `bun:"table:users"`
+
+			ID       string    `bun:",pk"`
+			Created  time.Time `store:"created_at"`
+			Modified time.Time `bun:"modified" store:"updated_at"`
+			Revision int       `store:"version"`
+		}
+		spec, err := GetTableSpec[Model]()
+		Expect(t,
+			NoError(err),
+			Equal("created", spec.CreatedAtColumn),
+			Equal("modified", spec.UpdatedAtColumn),
+			Equal("revision", spec.VersionColumn),
+		)
+	})
+	t.Run("full-text columns", func(t *testing.T) {
+		type Model struct {
+			bun.BaseModel `bun:"table:articles"`
+
+			ID    string `bun:",pk"`
+			Title string `store:"index,fulltext"`
+			Body  string `bun:"content" store:"fulltext"`
+		}
+		spec, err := GetTableSpec[Model]()
+		Expect(t,
+			NoError(err),
+			Equal([]string{"title"}, spec.Indexes),
+			Equal([]string{"title", "content"}, spec.FullTextColumns),
+			Equal("articles_fts", spec.FullTextTable()),
+		)
+	})
 	t.Run("not a struct", func(t *testing.T) {
 		spec, err := GetTableSpec[int]()
 		Expect(t,
 			IsNilPointer(spec),
-			IsError(errNotAStruct, err),
+			IsError(ErrNotAStruct, err),
 		)
 	})
 }
 
-func TestTableSpecValidate(t *testing.T) {
+func TestTableSpecProblems(t *testing.T) {
 	testCases := []struct {
 		Name   string
 		Input  TableSpec
@@ -92,16 +156,16 @@ func TestTableSpecValidate(t *testing.T) {
 		{
 			Name:   "empty",
 			Input:  TableSpec{},
-			Expect: []error{errMissingTableName, errNoPK, errNoColumns},
+			Expect: []error{ErrMissingTableName, ErrNoPK, ErrNoColumns},
 		},
 		{
 			Name: "no table name",
 			Input: TableSpec{
-				KeyField:    "ID",
-				KeySQL:      "id",
+				KeyFields:   []string{"ID"},
+				KeyColumns:  []string{"id"},
 				ColumnNames: map[string]string{"ID": "id"},
 			},
-			Expect: []error{errMissingTableName},
+			Expect: []error{ErrMissingTableName},
 		},
 		{
 			Name: "no pk",
@@ -109,27 +173,25 @@ func TestTableSpecValidate(t *testing.T) {
 				TableName:   "users",
 				ColumnNames: map[string]string{"ID": "id"},
 			},
-			Expect: []error{errNoPK},
+			Expect: []error{ErrNoPK},
 		},
 		{
 			Name: "no columns",
 			Input: TableSpec{
-				TableName: "users",
-				KeyField:  "ID",
-				KeySQL:    "id",
+				TableName:  "users",
+				KeyFields:  []string{"ID"},
+				KeyColumns: []string{"id"},
 			},
-			Expect: []error{errNoColumns},
+			Expect: []error{ErrNoColumns},
 		},
 	}
 	t.Parallel()
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
-			err := tc.Input.Validate()
-			if len(tc.Expect) == 0 {
-				Expect(t, NoError(err))
-			}
+			problems := tc.Input.Problems()
+			Expect(t, SliceHasLength(len(tc.Expect), problems))
 			for _, wantErr := range tc.Expect {
-				Expect(t, IsError(wantErr, err))
+				Expect(t, IsError(wantErr, errors.Join(problems...)))
 			}
 		})
 	}
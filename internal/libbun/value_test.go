@@ -0,0 +1,136 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package libbun
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/ArnaudCalmettes/store"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+type cents struct {
+	Amount int64
+}
+
+func (c cents) Value() (driver.Value, error) {
+	if c.Amount < 0 {
+		return nil, errors.New("negative amount")
+	}
+	return c.Amount, nil
+}
+
+type level uint8
+
+func TestFilterValue(t *testing.T) {
+	now := time.Now()
+	five := 5
+	testCases := []struct {
+		Name   string
+		Value  any
+		Expect any
+	}{
+		{"nil", nil, nil},
+		{"nil pointer", (*int)(nil), nil},
+		{"pointer", &five, int64(5)},
+		{"named type", level(3), uint64(3)},
+		{"string", "foo", "foo"},
+		{"bool", true, true},
+		{"float", float32(0.5), 0.5},
+		{"time", now, now},
+		{"bytes", []byte("foo"), []byte("foo")},
+		{"valuer", cents{1299}, int64(1299)},
+		{"valuer pointer", &cents{1299}, int64(1299)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			value, err := filterValue(tc.Value)
+			Expect(t,
+				NoError(err),
+				Equal(tc.Expect, value),
+			)
+		})
+	}
+	t.Run("errors", func(t *testing.T) {
+		_, err := filterValue(cents{-1})
+		Expect(t,
+			IsError(errUnsupportedValue, err),
+		)
+		_, err = filterValue(struct{}{})
+		Expect(t,
+			IsError(errUnsupportedValue, err),
+		)
+	})
+}
+
+func TestFilterBuilderValues(t *testing.T) {
+	db := bun.NewDB(nil, sqlitedialect.New())
+	type Product struct {
+		bun.BaseModel `bun:"table:products,alias:p"`
+
+		ID    string `bun:",pk"`
+		Level level
+		Price cents `bun:",type:bigint"`
+	}
+	var model []*Product
+	tableSpec, err := GetTableSpec[Product]()
+	Require(t,
+		NoError(err),
+	)
+
+	builder, err := BuilderForFilter(
+		All(Where("Level", ">=", level(2)), Where("Price", "<", cents{1000})),
+		tableSpec, dialect.SQLite,
+	)
+	Require(t,
+		NoError(err),
+	)
+	query := db.NewSelect().Model(&model).Column("id").ApplyQueryBuilder(builder).String()
+	Expect(t,
+		Equal(`SELECT "p"."id" FROM "products" AS "p" WHERE ("level" >= 2) AND ("price" < 1000)`, query),
+	)
+
+	builder, err = BuilderForFilter(
+		Any(Where("Level", "=", nil), Where("Level", "!=", (*level)(nil))),
+		tableSpec, dialect.SQLite,
+	)
+	Require(t,
+		NoError(err),
+	)
+	query = db.NewSelect().Model(&model).Column("id").ApplyQueryBuilder(builder).String()
+	Expect(t,
+		Equal(`SELECT "p"."id" FROM "products" AS "p" WHERE (("level" IS NULL)) OR (("level" IS NOT NULL))`, query),
+	)
+
+	_, err = BuilderForFilter(Where("Price", "<", cents{-1}), tableSpec, dialect.SQLite)
+	Expect(t,
+		IsError(errUnsupportedValue, err),
+	)
+	_, err = BuilderForFilter(Where("Level", "<", nil), tableSpec, dialect.SQLite)
+	Expect(t,
+		IsError(errInvalidOperator, err),
+	)
+}
@@ -36,6 +36,46 @@ type BaseKeyValueMap interface {
 	SetOne(ctx context.Context, key, value string) error
 	SetMany(ctx context.Context, items map[string]string) error
 	UpdateOne(ctx context.Context, key string, update func(string, *string) (*string, error)) error
+
+	// UpdateMany calls update with the current value of each key (nil if the
+	// key is missing), and stores the values it returns unless they are nil.
+	// Implementations that retry their transaction on conflicts may call
+	// update more than once for the same key, so it shouldn't have side
+	// effects that can't be repeated.
 	UpdateMany(ctx context.Context, keys []string, update func(string, *string) (*string, error)) error
 	Delete(ctx context.Context, keys ...string) error
 }
+
+// ConditionalDeleter is implemented by maps that can delete keys depending on
+// their current value.
+type ConditionalDeleter interface {
+	// DeleteIf deletes the keys whose current value satisfies cond, which is
+	// checked atomically with the deletion, and returns how many keys were
+	// deleted. Missing and empty keys are skipped.
+	DeleteIf(ctx context.Context, keys []string, cond func(string, string) bool) (int, error)
+}
+
+// BaseBinaryMap is the byte-oriented counterpart of BaseKeyValueMap.
+//
+// Implementations must not retain the slices they are given, which callers
+// are free to reuse once the call returns. Slices they return must not be
+// modified by callers.
+//
+// Update callbacks are given a nil slice for missing keys, and leave the
+// value unchanged when they return a nil slice.
+type BaseBinaryMap interface {
+	GetOne(ctx context.Context, key string) ([]byte, error)
+	GetMany(ctx context.Context, keys []string) (map[string][]byte, error)
+	GetAll(ctx context.Context) (map[string][]byte, error)
+	SetOne(ctx context.Context, key string, value []byte) error
+	SetMany(ctx context.Context, items map[string][]byte) error
+	UpdateOne(ctx context.Context, key string, update func(string, []byte) ([]byte, error)) error
+	UpdateMany(ctx context.Context, keys []string, update func(string, []byte) ([]byte, error)) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// BinaryConditionalDeleter is the byte-oriented counterpart of
+// ConditionalDeleter.
+type BinaryConditionalDeleter interface {
+	DeleteIf(ctx context.Context, keys []string, cond func(string, []byte) bool) (int, error)
+}
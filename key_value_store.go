@@ -24,6 +24,8 @@ import "context"
 type KeyValue[T any] interface {
 	BaseKeyValueStore[T]
 	Lister[T]
+	WhereDeleter
+	WhereUpdater[T]
 	ErrorMapSetter
 	Resetter
 }
@@ -35,6 +37,8 @@ type BaseKeyValueStore[T any] interface {
 	SetOne(ctx context.Context, key string, value *T) error
 	SetMany(ctx context.Context, items map[string]*T) error
 	UpdateOne(ctx context.Context, key string, update func(string, *T) (*T, error)) error
+
+	// UpdateMany behaves like BaseKeyValueMap.UpdateMany.
 	UpdateMany(ctx context.Context, keys []string, update func(string, *T) (*T, error)) error
 	Delete(ctx context.Context, keys ...string) error
 }
@@ -42,3 +46,11 @@ type BaseKeyValueStore[T any] interface {
 type Lister[T any] interface {
 	List(ctx context.Context, opts ...*Options) ([]*T, error)
 }
+
+type WhereDeleter interface {
+	DeleteWhere(ctx context.Context, filter *FilterSpec) (int, error)
+}
+
+type WhereUpdater[T any] interface {
+	UpdateWhere(ctx context.Context, filter *FilterSpec, update func(string, *T) (*T, error)) (int, error)
+}
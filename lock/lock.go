@@ -0,0 +1,43 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package lock
+
+import (
+	"context"
+	"time"
+)
+
+// Locker hands out time-bound, exclusive leases on named locks.
+type Locker interface {
+	Acquire(ctx context.Context, name string, ttl time.Duration) (*Lease, error)
+	Renew(ctx context.Context, lease *Lease, ttl time.Duration) error
+	Release(ctx context.Context, lease *Lease) error
+}
+
+// Lease is held by the owner of a lock until it expires or is released.
+//
+// Token is a fencing token: it strictly increases each time the lock is
+// acquired, so that resources guarded by the lock can reject writes coming
+// from the holder of a stale lease.
+type Lease struct {
+	Name  string
+	Owner string
+	Token int64
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package memory
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"sync"
+
+	//lint:ignore ST1001 common definitions
+	. "github.com/ArnaudCalmettes/store"
+)
+
+type BinaryMap interface {
+	BaseBinaryMap
+	Resetter
+	ErrorMapSetter
+}
+
+// NewBinaryMap returns an in-memory BinaryMap. Values are copied when they
+// are set, and shared (read-only) when they are read.
+func NewBinaryMap() BinaryMap {
+	b := &binaryMap{
+		items: make(map[string][]byte),
+	}
+	b.InitDefaultErrors()
+	return b
+}
+
+type binaryMap struct {
+	items map[string][]byte
+	mtx   sync.RWMutex
+	ErrorMap
+}
+
+func (b *binaryMap) SetErrorMap(errorMap ErrorMap) {
+	b.ErrorMap = errorMap
+	b.InitDefaultErrors()
+}
+
+func (b *binaryMap) SetOne(ctx context.Context, key string, value []byte) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if key == "" {
+		return b.ErrEmptyKey
+	}
+	b.items[key] = bytes.Clone(value)
+	return nil
+}
+
+func (b *binaryMap) SetMany(ctx context.Context, items map[string][]byte) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	batch := &BatchError{}
+	for key, value := range items {
+		if key == "" {
+			batch.Add(key, b.ErrEmptyKey)
+			continue
+		}
+		b.items[key] = bytes.Clone(value)
+	}
+	return batch.ErrOrNil()
+}
+
+func (b *binaryMap) GetOne(ctx context.Context, key string) ([]byte, error) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	if key == "" {
+		return nil, b.ErrEmptyKey
+	}
+	value, ok := b.items[key]
+	if !ok {
+		return nil, b.ErrNotFound
+	}
+	return value, nil
+}
+
+func (b *binaryMap) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	items := make(map[string][]byte, len(keys))
+	batch := &BatchError{}
+	for _, key := range keys {
+		if key == "" {
+			batch.Add(key, b.ErrEmptyKey)
+			continue
+		}
+		value, ok := b.items[key]
+		if !ok {
+			batch.Add(key, b.ErrNotFound)
+			continue
+		}
+		items[key] = value
+	}
+	return items, batch.ErrOrNil()
+}
+
+func (b *binaryMap) GetAll(ctx context.Context) (map[string][]byte, error) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	return maps.Clone(b.items), nil
+}
+
+func (b *binaryMap) UpdateOne(ctx context.Context, key string, update func(string, []byte) ([]byte, error)) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if key == "" {
+		return b.ErrEmptyKey
+	}
+	newValue, err := update(key, b.items[key])
+	if err != nil || newValue == nil {
+		return err
+	}
+	b.items[key] = bytes.Clone(newValue)
+	return nil
+}
+
+func (b *binaryMap) UpdateMany(ctx context.Context, keys []string, update func(string, []byte) ([]byte, error)) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	updatedValues := make(map[string][]byte, len(keys))
+	batch := &BatchError{}
+	for _, key := range keys {
+		if key == "" {
+			batch.Add(key, b.ErrEmptyKey)
+			continue
+		}
+		newValue, err := update(key, b.items[key])
+		if err != nil {
+			return err
+		}
+		if newValue != nil {
+			updatedValues[key] = bytes.Clone(newValue)
+		}
+	}
+	maps.Copy(b.items, updatedValues)
+	return batch.ErrOrNil()
+}
+
+func (b *binaryMap) Delete(ctx context.Context, keys ...string) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	for _, key := range keys {
+		delete(b.items, key)
+	}
+	return nil
+}
+
+func (b *binaryMap) DeleteIf(ctx context.Context, keys []string, cond func(string, []byte) bool) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	var count int
+	for _, key := range keys {
+		value, ok := b.items[key]
+		if ok && cond(key, value) {
+			delete(b.items, key)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (b *binaryMap) Reset(ctx context.Context) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.items = map[string][]byte{}
+	return nil
+}
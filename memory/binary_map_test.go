@@ -0,0 +1,86 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+func TestBinaryMapCopiesValues(t *testing.T) {
+	ctx := context.Background()
+	store := NewBinaryMap()
+	buf := []byte("one")
+	err := store.SetMany(ctx, map[string][]byte{"key": buf})
+	Require(t, NoError(err))
+	copy(buf, "two")
+
+	value, err := store.GetOne(ctx, "key")
+	Expect(t,
+		NoError(err),
+		Equal([]byte("one"), value),
+	)
+
+	err = store.UpdateOne(ctx, "key", func(_ string, value []byte) ([]byte, error) {
+		buf = append(buf[:0], value...)
+		buf[0] = 'O'
+		return buf, nil
+	})
+	Require(t, NoError(err))
+	buf[0] = 'X'
+
+	value, err = store.GetOne(ctx, "key")
+	Expect(t,
+		NoError(err),
+		Equal([]byte("One"), value),
+	)
+}
+
+func TestBinaryMapMissingKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewBinaryMap()
+	err := store.UpdateMany(ctx, []string{"missing"}, func(_ string, value []byte) ([]byte, error) {
+		Expect(t, IsZero(value))
+		return nil, nil
+	})
+	Require(t, NoError(err))
+
+	_, err = store.GetOne(ctx, "missing")
+	Expect(t, IsError(ErrNotFound, err))
+
+	_, err = store.GetOne(ctx, "")
+	Expect(t, IsError(ErrEmptyKey, err))
+}
+
+func TestBinaryMapCustomErrors(t *testing.T) {
+	errTest := errors.New("test")
+	store := NewBinaryMap()
+	store.SetErrorMap(ErrorMap{
+		ErrNotFound: errTest,
+	})
+	_, err := store.GetOne(context.Background(), "does not exist")
+	Require(t,
+		IsError(errTest, err),
+	)
+}
@@ -21,7 +21,10 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"maps"
+	"math"
+	"strconv"
 	"sync"
 
 	//lint:ignore ST1001 common definitions
@@ -66,9 +69,15 @@ func (k *keyValueMap) SetOne(ctx context.Context, key string, value string) erro
 func (k *keyValueMap) SetMany(ctx context.Context, items map[string]string) error {
 	k.mtx.Lock()
 	defer k.mtx.Unlock()
-	maps.Copy(k.items, items)
-	delete(k.items, "")
-	return nil
+	batch := &BatchError{}
+	for key, value := range items {
+		if key == "" {
+			batch.Add(key, k.ErrEmptyKey)
+			continue
+		}
+		k.items[key] = value
+	}
+	return batch.ErrOrNil()
 }
 
 func (k *keyValueMap) GetOne(ctx context.Context, key string) (string, error) {
@@ -85,14 +94,20 @@ func (k *keyValueMap) GetMany(ctx context.Context, keys []string) (map[string]st
 	k.mtx.RLock()
 	defer k.mtx.RUnlock()
 	items := make(map[string]string, len(keys))
+	batch := &BatchError{}
 	for _, key := range keys {
+		if key == "" {
+			batch.Add(key, k.ErrEmptyKey)
+			continue
+		}
 		value, ok := k.items[key]
 		if !ok {
+			batch.Add(key, k.ErrNotFound)
 			continue
 		}
 		items[key] = value
 	}
-	return items, nil
+	return items, batch.ErrOrNil()
 }
 
 func (k *keyValueMap) GetAll(ctx context.Context) (map[string]string, error) {
@@ -129,8 +144,10 @@ func (k *keyValueMap) UpdateMany(ctx context.Context, keys []string, update func
 	defer k.mtx.Unlock()
 
 	updatedValues := make(map[string]string, len(keys))
+	batch := &BatchError{}
 	for _, key := range keys {
 		if key == "" {
+			batch.Add(key, k.ErrEmptyKey)
 			continue
 		}
 		var valuePtr *string
@@ -147,7 +164,69 @@ func (k *keyValueMap) UpdateMany(ctx context.Context, keys []string, update func
 		}
 	}
 	maps.Copy(k.items, updatedValues)
-	return nil
+	return batch.ErrOrNil()
+}
+
+func (k *keyValueMap) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	if key == "" {
+		return 0, k.ErrEmptyKey
+	}
+	value, err := k.parseInt(key)
+	if err != nil {
+		return 0, err
+	}
+	value, err = k.add(value, delta)
+	if err != nil {
+		return 0, err
+	}
+	k.items[key] = strconv.FormatInt(value, 10)
+	return value, nil
+}
+
+func (k *keyValueMap) IncrMany(ctx context.Context, deltas map[string]int64) (map[string]int64, error) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	result := make(map[string]int64, len(deltas))
+	batch := &BatchError{}
+	for key, delta := range deltas {
+		if key == "" {
+			batch.Add(key, k.ErrEmptyKey)
+			continue
+		}
+		value, err := k.parseInt(key)
+		if err != nil {
+			return nil, err
+		}
+		if result[key], err = k.add(value, delta); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range result {
+		k.items[key] = strconv.FormatInt(value, 10)
+	}
+	return result, batch.ErrOrNil()
+}
+
+// add returns value + delta, or ErrOverflow if it doesn't fit in an int64.
+func (k *keyValueMap) add(value, delta int64) (int64, error) {
+	if delta > 0 && value > math.MaxInt64-delta || delta < 0 && value < math.MinInt64-delta {
+		return 0, k.ErrOverflow
+	}
+	return value + delta, nil
+}
+
+func (k *keyValueMap) parseInt(key string) (int64, error) {
+	data, ok := k.items[key]
+	if !ok {
+		return 0, nil
+	}
+	value, err := strconv.ParseInt(data, 10, 64)
+	if err != nil {
+		return 0, errors.Join(k.ErrNotAnInteger, err)
+	}
+	return value, nil
 }
 
 func (k *keyValueMap) Delete(ctx context.Context, keys ...string) error {
@@ -159,6 +238,20 @@ func (k *keyValueMap) Delete(ctx context.Context, keys ...string) error {
 	return nil
 }
 
+func (k *keyValueMap) DeleteIf(ctx context.Context, keys []string, cond func(string, string) bool) (int, error) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	var count int
+	for _, key := range keys {
+		value, ok := k.items[key]
+		if ok && cond(key, value) {
+			delete(k.items, key)
+			count++
+		}
+	}
+	return count, nil
+}
+
 func (k *keyValueMap) Reset(ctx context.Context) error {
 	k.mtx.Lock()
 	defer k.mtx.Unlock()
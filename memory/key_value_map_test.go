@@ -34,6 +34,35 @@ func TestMemoryKeyValueMap(t *testing.T) {
 	TestBaseKeyValueMap(t, newKeyValueMap)
 }
 
+func TestMemoryKeyValueMapCounter(t *testing.T) {
+	newCounter := func(*testing.T) Counter { return NewKeyValueMap().(Counter) }
+	TestCounter(t, newCounter)
+}
+
+func TestKeyValueMapIncrNotAnInteger(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+	store := NewKeyValueMap()
+	counter := store.(Counter)
+	err := store.SetOne(ctx, "text", "not a number")
+	Require(t,
+		NoError(err),
+	)
+
+	_, err = counter.Incr(ctx, "text", 1)
+	Expect(t,
+		IsError(ErrNotAnInteger, err),
+	)
+	_, err = counter.IncrMany(ctx, map[string]int64{"text": 1, "other": 1})
+	Expect(t,
+		IsError(ErrNotAnInteger, err),
+	)
+	_, err = store.GetOne(ctx, "other")
+	Expect(t,
+		IsErrorf(ErrNotFound, err, "IncrMany should not be partially applied"),
+	)
+}
+
 func TestKeyValueMapCustomErrors(t *testing.T) {
 	errTest := errors.New("test")
 	store := NewKeyValueMap()
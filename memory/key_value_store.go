@@ -23,8 +23,10 @@ import (
 	"context"
 	"errors"
 	"maps"
+	"reflect"
 	"slices"
 	"sync"
+	"time"
 
 	//lint:ignore ST1001 common definitions
 	. "github.com/ArnaudCalmettes/store"
@@ -35,22 +37,65 @@ import (
 type KeyValueStore[T any] interface {
 	BaseKeyValueStore[T]
 	Lister[T]
+	WhereDeleter
+	WhereUpdater[T]
 	Resetter
 	ErrorMapSetter
+	ClockSetter
 }
 
+// NewKeyValueStore is like TryNewKeyValueStore, but panics if T is not a
+// valid model.
 func NewKeyValueStore[T any]() KeyValueStore[T] {
+	k, err := TryNewKeyValueStore[T]()
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// TryNewKeyValueStore returns an in-memory KeyValueStore. If T has invalid
+// automatic fields (see inspect.AutoFields), it returns a *ModelError.
+func TryNewKeyValueStore[T any]() (KeyValueStore[T], error) {
+	auto, err := inspect.NewAutoFields[T]()
+	if err != nil {
+		return nil, &ModelError{Model: reflect.TypeFor[T]().String(), Problems: []error{err}}
+	}
 	k := &keyValueStore[T]{
 		items: make(map[string]T),
+		auto:  auto,
+		now:   time.Now,
 	}
 	k.InitDefaultErrors()
-	return k
+	return k, nil
 }
 
 type keyValueStore[T any] struct {
 	items map[string]T
 	mtx   sync.RWMutex
 	ErrorMap
+	auto *inspect.AutoFields[T]
+	now  func() time.Time
+}
+
+func (k *keyValueStore[T]) SetClock(now func() time.Time) {
+	k.now = now
+}
+
+// touch returns a copy of value with its automatic fields updated, prev being
+// the value it replaces, if any.
+func (k *keyValueStore[T]) touch(value *T, prev *T) T {
+	item := *value
+	k.auto.Touch(&item, prev, k.now())
+	return item
+}
+
+// previous returns the stored value for key, or nil.
+func (k *keyValueStore[T]) previous(key string) *T {
+	if value, ok := k.items[key]; ok {
+		return &value
+	}
+	return nil
 }
 
 func (k *keyValueStore[T]) SetErrorMap(errorMap ErrorMap) {
@@ -136,14 +181,20 @@ func (k *keyValueStore[T]) GetMany(ctx context.Context, keys []string) (map[stri
 	k.mtx.RLock()
 	defer k.mtx.RUnlock()
 	items := make(map[string]*T, len(keys))
+	batch := &BatchError{}
 	for _, key := range keys {
+		if key == "" {
+			batch.Add(key, k.ErrEmptyKey)
+			continue
+		}
 		value, ok := k.items[key]
 		if !ok {
+			batch.Add(key, k.ErrNotFound)
 			continue
 		}
 		items[key] = &value
 	}
-	return items, nil
+	return items, batch.ErrOrNil()
 }
 
 func (k *keyValueStore[T]) GetAll(ctx context.Context) (map[string]*T, error) {
@@ -163,20 +214,22 @@ func (k *keyValueStore[T]) SetOne(ctx context.Context, key string, value *T) err
 	if key == "" {
 		return k.ErrEmptyKey
 	}
-	k.items[key] = *value
+	k.items[key] = k.touch(value, k.previous(key))
 	return nil
 }
 
 func (k *keyValueStore[T]) SetMany(ctx context.Context, items map[string]*T) error {
 	k.mtx.Lock()
 	defer k.mtx.Unlock()
+	batch := &BatchError{}
 	for key, value := range items {
 		if key == "" {
+			batch.Add(key, k.ErrEmptyKey)
 			continue
 		}
-		k.items[key] = *value
+		k.items[key] = k.touch(value, k.previous(key))
 	}
-	return nil
+	return batch.ErrOrNil()
 }
 
 func (k *keyValueStore[T]) UpdateOne(ctx context.Context, key string, update func(string, *T) (*T, error)) error {
@@ -197,7 +250,7 @@ func (k *keyValueStore[T]) UpdateOne(ctx context.Context, key string, update fun
 	if newValue == nil {
 		return nil
 	}
-	k.items[key] = *newValue
+	k.items[key] = k.touch(newValue, valuePtr)
 	return nil
 }
 
@@ -206,8 +259,10 @@ func (k *keyValueStore[T]) UpdateMany(ctx context.Context, keys []string, update
 	defer k.mtx.Unlock()
 
 	updatedValues := make(map[string]T, len(keys))
+	batch := &BatchError{}
 	for _, key := range keys {
 		if key == "" {
+			batch.Add(key, k.ErrEmptyKey)
 			continue
 		}
 		var valuePtr *T
@@ -220,11 +275,11 @@ func (k *keyValueStore[T]) UpdateMany(ctx context.Context, keys []string, update
 			return err
 		}
 		if newValue != nil {
-			updatedValues[key] = *newValue
+			updatedValues[key] = k.touch(newValue, valuePtr)
 		}
 	}
 	maps.Copy(k.items, updatedValues)
-	return nil
+	return batch.ErrOrNil()
 }
 
 func (k *keyValueStore[T]) Delete(ctx context.Context, keys ...string) error {
@@ -236,6 +291,47 @@ func (k *keyValueStore[T]) Delete(ctx context.Context, keys ...string) error {
 	return nil
 }
 
+func (k *keyValueStore[T]) DeleteWhere(ctx context.Context, filter *FilterSpec) (int, error) {
+	predicate, err := inspect.NewPredicate[T](filter)
+	if err != nil {
+		return 0, errors.Join(k.ErrInvalidFilter, err)
+	}
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	var count int
+	for key, item := range k.items {
+		if predicate(&item) {
+			delete(k.items, key)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (k *keyValueStore[T]) UpdateWhere(ctx context.Context, filter *FilterSpec, update func(string, *T) (*T, error)) (int, error) {
+	predicate, err := inspect.NewPredicate[T](filter)
+	if err != nil {
+		return 0, errors.Join(k.ErrInvalidFilter, err)
+	}
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	updatedValues := make(map[string]T)
+	for key, value := range k.items {
+		if !predicate(&value) {
+			continue
+		}
+		newValue, err := update(key, &value)
+		if err != nil {
+			return 0, err
+		}
+		if newValue != nil {
+			updatedValues[key] = k.touch(newValue, &value)
+		}
+	}
+	maps.Copy(k.items, updatedValues)
+	return len(updatedValues), nil
+}
+
 func (k *keyValueStore[T]) Reset(ctx context.Context) error {
 	k.mtx.Lock()
 	defer k.mtx.Unlock()
@@ -36,6 +36,40 @@ func TestMemoryKeyValueStore(t *testing.T) {
 	TestBaseKeyValueStore(t, newStore)
 }
 
+func TestKeyValueStoreAutoFields(t *testing.T) {
+	TestAutoFields(t, Constructor(NewKeyValueStore[Document]))
+}
+
+func TestKeyValueStoreInvalidAutoFields(t *testing.T) {
+	type Model struct {
+		CreatedAt string `store:"created_at"`
+	}
+	_, err := TryNewKeyValueStore[Model]()
+	var modelErr *ModelError
+	Require(t, Equal(true, errors.As(err, &modelErr)))
+	Expect(t,
+		Equal("memory.Model", modelErr.Model),
+		SliceHasLength(1, modelErr.Problems),
+		ShouldPanic(func() {
+			NewKeyValueStore[Model]()
+		}),
+	)
+}
+
+func TestKeyValueStoreWhereDeleter(t *testing.T) {
+	newStore := func(*testing.T) TestWhereDeleterInterface[Person] {
+		return NewKeyValueStore[Person]()
+	}
+	TestWhereDeleter(t, newStore)
+}
+
+func TestKeyValueStoreWhereUpdater(t *testing.T) {
+	newStore := func(*testing.T) TestWhereUpdaterInterface[Person] {
+		return NewKeyValueStore[Person]()
+	}
+	TestWhereUpdater(t, newStore)
+}
+
 func TestKeyValueStoreCustomErrors(t *testing.T) {
 	errTest := errors.New("test")
 	store := NewKeyValueStore[Entry]()
@@ -76,3 +110,15 @@ func TestKeyValueStoreLister(t *testing.T) {
 	}
 	TestLister(t, newStore)
 }
+
+func TestKeyValueStoreFullText(t *testing.T) {
+	TestFullText(t, Constructor(NewKeyValueStore[Article]))
+}
+
+func TestKeyValueStoreKindFilters(t *testing.T) {
+	TestKindFilters(t, Constructor(NewKeyValueStore[Product]))
+}
+
+func TestKeyValueStoreCollectionFilters(t *testing.T) {
+	TestCollectionFilters(t, Constructor(NewKeyValueStore[Tagged]))
+}
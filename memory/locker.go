@@ -0,0 +1,110 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	//lint:ignore ST1001 common definitions
+	. "github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/lock"
+	"github.com/google/uuid"
+)
+
+type Locker interface {
+	lock.Locker
+	Resetter
+}
+
+func NewLocker() Locker {
+	return &locker{
+		leases: make(map[string]lease),
+		tokens: make(map[string]int64),
+	}
+}
+
+type lease struct {
+	owner     string
+	expiresAt time.Time
+}
+
+type locker struct {
+	leases map[string]lease
+	tokens map[string]int64
+	mtx    sync.Mutex
+}
+
+func (l *locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*lock.Lease, error) {
+	if name == "" {
+		return nil, lock.ErrEmptyName
+	}
+	if ttl <= 0 {
+		return nil, lock.ErrInvalidTTL
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	now := time.Now()
+	if current, ok := l.leases[name]; ok && now.Before(current.expiresAt) {
+		return nil, lock.ErrLocked
+	}
+	owner := uuid.NewString()
+	l.tokens[name]++
+	l.leases[name] = lease{owner: owner, expiresAt: now.Add(ttl)}
+	return &lock.Lease{Name: name, Owner: owner, Token: l.tokens[name]}, nil
+}
+
+func (l *locker) Renew(ctx context.Context, held *lock.Lease, ttl time.Duration) error {
+	if ttl <= 0 {
+		return lock.ErrInvalidTTL
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	now := time.Now()
+	if !l.isHeld(held, now) {
+		return lock.ErrNotHeld
+	}
+	l.leases[held.Name] = lease{owner: held.Owner, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (l *locker) Release(ctx context.Context, held *lock.Lease) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if !l.isHeld(held, time.Now()) {
+		return lock.ErrNotHeld
+	}
+	delete(l.leases, held.Name)
+	return nil
+}
+
+func (l *locker) isHeld(held *lock.Lease, now time.Time) bool {
+	current, ok := l.leases[held.Name]
+	return ok && current.owner == held.Owner && now.Before(current.expiresAt)
+}
+
+func (l *locker) Reset(ctx context.Context) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.leases = map[string]lease{}
+	l.tokens = map[string]int64{}
+	return nil
+}
@@ -48,6 +48,34 @@ func Where(fieldName string, op string, value any) *FilterSpec {
 	return &FilterSpec{Where: &WhereClause{fieldName, op, value}}
 }
 
+// Match selects the items whose field contains every word of query. Words
+// are sequences of letters and digits, and are compared case-insensitively.
+func Match(fieldName string, query string) *FilterSpec {
+	return Where(fieldName, "match", query)
+}
+
+// Contains selects the items whose slice field has value as an element.
+func Contains(fieldName string, value any) *FilterSpec {
+	return Where(fieldName, "contains", value)
+}
+
+// ContainsAny selects the items whose slice field has at least one of the
+// elements of values, which must be a slice.
+func ContainsAny(fieldName string, values any) *FilterSpec {
+	return Where(fieldName, "contains_any", values)
+}
+
+// ContainsAll selects the items whose slice field has all the elements of
+// values, which must be a slice.
+func ContainsAll(fieldName string, values any) *FilterSpec {
+	return Where(fieldName, "contains_all", values)
+}
+
+// HasKey selects the items whose map field has key as a key.
+func HasKey(fieldName string, key any) *FilterSpec {
+	return Where(fieldName, "has_key", key)
+}
+
 func All(filters ...*FilterSpec) *FilterSpec {
 	return &FilterSpec{All: filters}
 }
@@ -66,9 +94,18 @@ func By(field string) *OrderBySpec {
 	return &OrderBySpec{Field: field}
 }
 
+// ByRelevance orders items by relevance of their field to a full-text query
+// (see Match), most relevant first.
+func ByRelevance(field string, query string) *OrderBySpec {
+	return &OrderBySpec{Field: field, Query: query}
+}
+
 type OrderBySpec struct {
 	Field      string
 	Descending bool
+	// Query, if not empty, orders by relevance to Query instead of by the
+	// value of Field. Descending puts the least relevant items first.
+	Query string
 }
 
 func (o *OrderBySpec) Desc() *OrderBySpec {
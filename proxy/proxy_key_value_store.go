@@ -29,6 +29,8 @@ import (
 type KeyValueStore[T any] interface {
 	BaseKeyValueStore[T]
 	Lister[T]
+	WhereDeleter
+	WhereUpdater[T]
 	ErrorMapSetter
 	Resetter
 }
@@ -122,3 +124,11 @@ func (k *keyValueStore[T, P]) updateFunc(update func(string, *T) (*T, error)) fu
 func (k *keyValueStore[T, P]) Delete(ctx context.Context, keys ...string) error {
 	return k.inner.Delete(ctx, keys...)
 }
+
+func (k *keyValueStore[T, P]) DeleteWhere(ctx context.Context, filter *FilterSpec) (int, error) {
+	return k.inner.DeleteWhere(ctx, filter)
+}
+
+func (k *keyValueStore[T, P]) UpdateWhere(ctx context.Context, filter *FilterSpec, f func(string, *T) (*T, error)) (int, error) {
+	return k.inner.UpdateWhere(ctx, filter, k.updateFunc(f))
+}
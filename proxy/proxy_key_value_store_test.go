@@ -93,6 +93,54 @@ func TestProxyLister(t *testing.T) {
 	TestLister(t, newStore)
 }
 
+func TestProxyWhereDeleter(t *testing.T) {
+	type PersonProxy struct {
+		Person
+		Address string
+	}
+	fromProxy := func(p *PersonProxy) *Person {
+		return &p.Person
+	}
+	toProxy := func(p *Person) *PersonProxy {
+		return &PersonProxy{Person: *p}
+	}
+	newStore := func(*testing.T) TestWhereDeleterInterface[Person] {
+		return NewKeyValueStoreWithProxy[Person, PersonProxy](
+			memory.NewKeyValueStore[PersonProxy](),
+			toProxy,
+			fromProxy,
+		)
+	}
+	TestWhereDeleter(t, newStore)
+}
+
+func TestProxyWhereUpdater(t *testing.T) {
+	type PersonProxy struct {
+		Person
+		Address string
+	}
+	fromProxy := func(p *PersonProxy) *Person {
+		if p == nil {
+			return nil
+		}
+		return &p.Person
+	}
+	toProxy := func(p *Person) *PersonProxy {
+		if p == nil {
+			return nil
+		}
+		return &PersonProxy{Person: *p}
+	}
+	newStore := func(*testing.T) TestWhereUpdaterInterface[Person] {
+		return NewKeyValueStoreWithProxy[Person, PersonProxy](
+			memory.NewKeyValueStore[PersonProxy](),
+			toProxy,
+			fromProxy,
+		)
+	}
+	TestWhereUpdater(t, newStore)
+}
+
 func TestKeyValueStoreCustomErrors(t *testing.T) {
 	errTest := errors.New("test")
 	store := NewKeyValueStoreWithProxy[Entry, EntryProxy](
@@ -22,6 +22,8 @@ package redis
 import (
 	"context"
 	"errors"
+	"strconv"
+	"strings"
 
 	//lint:ignore ST1001 shared definitions
 	. "github.com/ArnaudCalmettes/store"
@@ -62,11 +64,22 @@ func (k *keyValueMap) SetOne(ctx context.Context, key string, value string) erro
 }
 
 func (k *keyValueMap) SetMany(ctx context.Context, items map[string]string) error {
-	delete(items, "")
-	if len(items) == 0 {
-		return nil
+	batch := &BatchError{}
+	values := make(map[string]string, len(items))
+	for key, value := range items {
+		if key == "" {
+			batch.Add(key, k.ErrEmptyKey)
+			continue
+		}
+		values[key] = value
+	}
+	if len(values) == 0 {
+		return batch.ErrOrNil()
 	}
-	return k.rdb.HSet(ctx, k.namespace, items).Err()
+	if err := k.rdb.HSet(ctx, k.namespace, values).Err(); err != nil {
+		return err
+	}
+	return batch.ErrOrNil()
 }
 
 func (k *keyValueMap) GetOne(ctx context.Context, key string) (string, error) {
@@ -82,15 +95,23 @@ func (k *keyValueMap) GetMany(ctx context.Context, keys []string) (map[string]st
 		return map[string]string{}, nil
 	}
 	values, err := k.rdb.HMGet(ctx, k.namespace, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
 	result := make(map[string]string, len(keys))
+	batch := &BatchError{}
 	for i, key := range keys {
 		value := values[i]
-		if value == nil {
-			continue
+		switch {
+		case key == "":
+			batch.Add(key, k.ErrEmptyKey)
+		case value == nil:
+			batch.Add(key, k.ErrNotFound)
+		default:
+			result[key] = value.(string)
 		}
-		result[key] = value.(string)
 	}
-	return result, err
+	return result, batch.ErrOrNil()
 }
 
 func (k *keyValueMap) GetAll(ctx context.Context) (map[string]string, error) {
@@ -117,15 +138,14 @@ func (k *keyValueMap) UpdateOne(ctx context.Context, key string, update func(str
 		if newValue == nil {
 			return err
 		}
-		return tx.HSet(ctx, k.namespace, key, *newValue).Err()
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return pipe.HSet(ctx, k.namespace, key, *newValue).Err()
+		})
+		return err
 	}
-	var err error
-	for i := 0; i < 10; i++ {
-		err = k.rdb.Watch(ctx, txFunc, k.namespace)
-		if err == redis.TxFailedErr {
-			continue
-		}
-		break
+	err := k.watch(ctx, txFunc)
+	if err == redis.TxFailedErr {
+		err = errors.Join(k.ErrConflict, err)
 	}
 	return err
 }
@@ -134,11 +154,13 @@ func (k *keyValueMap) UpdateMany(ctx context.Context, keys []string, update func
 	if len(keys) == 0 {
 		return nil
 	}
+	batch := &BatchError{}
 	txFunc := func(tx *redis.Tx) error {
 		values, err := tx.HMGet(ctx, k.namespace, keys...).Result()
 		updated := make(map[string]string, len(keys))
 		for i, value := range values {
 			if keys[i] == "" {
+				batch.Add(keys[i], k.ErrEmptyKey)
 				continue
 			}
 
@@ -158,8 +180,27 @@ func (k *keyValueMap) UpdateMany(ctx context.Context, keys []string, update func
 		if len(updated) == 0 {
 			return err
 		}
-		return tx.HSet(ctx, k.namespace, updated).Err()
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return pipe.HSet(ctx, k.namespace, updated).Err()
+		})
+		return err
 	}
+	err := k.watch(ctx, txFunc)
+	if err == redis.TxFailedErr {
+		for _, key := range keys {
+			if key != "" {
+				batch.Add(key, k.ErrConflict)
+			}
+		}
+		return batch
+	}
+	if err != nil {
+		return err
+	}
+	return batch.ErrOrNil()
+}
+
+func (k *keyValueMap) watch(ctx context.Context, txFunc func(*redis.Tx) error) error {
 	var err error
 	for i := 0; i < 10; i++ {
 		err = k.rdb.Watch(ctx, txFunc, k.namespace)
@@ -171,6 +212,102 @@ func (k *keyValueMap) UpdateMany(ctx context.Context, keys []string, update func
 	return err
 }
 
+func (k *keyValueMap) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	if key == "" {
+		return 0, k.ErrEmptyKey
+	}
+	// HINCRBY only fails on overflow with some servers, so the checks of
+	// incrManyScript are used for single keys too.
+	result, err := k.IncrMany(ctx, map[string]int64{key: delta})
+	if err != nil {
+		return 0, err
+	}
+	return result[key], nil
+}
+
+// incrManyScript checks every value before incrementing any of them so that
+// IncrMany is never partially applied. Lua numbers are doubles, so overflows
+// are detected by adding the absolute values in base 1e9 limbs, and the new
+// values are returned as strings rather than HINCRBY's replies.
+var incrManyScript = redis.NewScript(`
+local function split(s)
+	local n = #s
+	if n <= 9 then
+		return 0, tonumber(s)
+	end
+	return tonumber(string.sub(s, 1, n - 9)), tonumber(string.sub(s, n - 8))
+end
+local function overflows(a, b)
+	local nega, negb = string.sub(a, 1, 1) == "-", string.sub(b, 1, 1) == "-"
+	if nega ~= negb then
+		return false
+	end
+	local ha, la = split(nega and string.sub(a, 2) or a)
+	local hb, lb = split(negb and string.sub(b, 2) or b)
+	local hi, lo = ha + hb, la + lb
+	if lo >= 1e9 then
+		hi, lo = hi + 1, lo - 1e9
+	end
+	local limit = nega and 854775808 or 854775807
+	return hi > 9223372036 or (hi == 9223372036 and lo > limit)
+end
+for i = 1, #ARGV, 2 do
+	local value = redis.call("HGET", KEYS[1], ARGV[i])
+	if value and not string.match(value, "^-?%d+$") then
+		return redis.error_reply("ERR hash value is not an integer")
+	end
+	if overflows(value or "0", ARGV[i + 1]) then
+		return redis.error_reply("ERR increment or decrement would overflow")
+	end
+end
+local result = {}
+for i = 1, #ARGV, 2 do
+	redis.call("HINCRBY", KEYS[1], ARGV[i], ARGV[i + 1])
+	result[#result + 1] = redis.call("HGET", KEYS[1], ARGV[i])
+end
+return result
+`)
+
+func (k *keyValueMap) IncrMany(ctx context.Context, deltas map[string]int64) (map[string]int64, error) {
+	batch := &BatchError{}
+	keys := make([]string, 0, len(deltas))
+	args := make([]any, 0, 2*len(deltas))
+	for key, delta := range deltas {
+		if key == "" {
+			batch.Add(key, k.ErrEmptyKey)
+			continue
+		}
+		keys = append(keys, key)
+		args = append(args, key, delta)
+	}
+	if len(keys) == 0 {
+		return map[string]int64{}, batch.ErrOrNil()
+	}
+	values, err := incrManyScript.Run(ctx, k.rdb, []string{k.namespace}, args...).StringSlice()
+	if err != nil {
+		return nil, k.mapIncrError(err)
+	}
+	result := make(map[string]int64, len(keys))
+	for i, key := range keys {
+		if result[key], err = strconv.ParseInt(values[i], 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return result, batch.ErrOrNil()
+}
+
+func (k *keyValueMap) mapIncrError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case strings.Contains(err.Error(), "not an integer"):
+		return errors.Join(k.ErrNotAnInteger, err)
+	case strings.Contains(err.Error(), "overflow"):
+		return errors.Join(k.ErrOverflow, err)
+	}
+	return err
+}
+
 func (k *keyValueMap) Delete(ctx context.Context, keys ...string) error {
 	if len(keys) == 0 {
 		return nil
@@ -178,6 +315,40 @@ func (k *keyValueMap) Delete(ctx context.Context, keys ...string) error {
 	return k.rdb.HDel(ctx, k.namespace, keys...).Err()
 }
 
+func (k *keyValueMap) DeleteIf(ctx context.Context, keys []string, cond func(string, string) bool) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	var deleted []string
+	txFunc := func(tx *redis.Tx) error {
+		values, err := tx.HMGet(ctx, k.namespace, keys...).Result()
+		if err != nil {
+			return err
+		}
+		deleted = deleted[:0]
+		for i, value := range values {
+			if keys[i] != "" && value != nil && cond(keys[i], value.(string)) {
+				deleted = append(deleted, keys[i])
+			}
+		}
+		if len(deleted) == 0 {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return pipe.HDel(ctx, k.namespace, deleted...).Err()
+		})
+		return err
+	}
+	err := k.watch(ctx, txFunc)
+	if err == redis.TxFailedErr {
+		return 0, errors.Join(k.ErrConflict, err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(deleted), nil
+}
+
 func (k *keyValueMap) Reset(ctx context.Context) error {
 	return k.rdb.Del(ctx, k.namespace).Err()
 }
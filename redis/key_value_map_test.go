@@ -38,6 +38,71 @@ func TestRedisKeyValueMap(t *testing.T) {
 	TestBaseKeyValueMap(t, makeNewKeyValueMap(t))
 }
 
+func TestRedisKeyValueMapCounter(t *testing.T) {
+	newKeyValueMap := makeNewKeyValueMap(t)
+	newCounter := func(t *testing.T) Counter {
+		return newKeyValueMap(t).(Counter)
+	}
+	TestCounter(t, newCounter)
+}
+
+func TestKeyValueMapIncrNotAnInteger(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	store := NewKeyValueMap(rdb, "test_incr")
+	counter := store.(Counter)
+	err := store.SetOne(ctx, "text", "not a number")
+	Require(t,
+		NoError(err),
+	)
+
+	_, err = counter.Incr(ctx, "text", 1)
+	Expect(t,
+		IsError(ErrNotAnInteger, err),
+	)
+	_, err = counter.IncrMany(ctx, map[string]int64{"text": 1, "other": 1})
+	Expect(t,
+		IsError(ErrNotAnInteger, err),
+	)
+	_, err = store.GetOne(ctx, "other")
+	Expect(t,
+		IsErrorf(ErrNotFound, err, "IncrMany should not be partially applied"),
+	)
+}
+
+func TestKeyValueMapUpdateConflict(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	store := NewKeyValueMap(rdb, "test_update_conflict")
+	other := redis.NewClient(&redis.Options{Addr: s.Addr()})
+
+	// A concurrent write between the read and the write of the first attempt
+	// aborts it, and the update is retried on the new value.
+	var calls int
+	err := store.UpdateMany(ctx, []string{"key"}, func(_ string, value *string) (*string, error) {
+		calls++
+		if calls == 1 {
+			Require(t, NoError(other.HSet(ctx, "test_update_conflict", "key", "concurrent").Err()))
+		}
+		newValue := "updated"
+		if value != nil {
+			newValue += " " + *value
+		}
+		return &newValue, nil
+	})
+	Require(t, NoError(err))
+	value, err := store.GetOne(ctx, "key")
+	Expect(t,
+		NoError(err),
+		Equal(2, calls),
+		Equal("updated concurrent", value),
+	)
+}
+
 func TestKeyValueMapCustomErrors(t *testing.T) {
 	errTest := errors.New("test")
 	s := miniredis.RunT(t)
@@ -29,8 +29,11 @@ import (
 type KeyValueStore[T any] interface {
 	BaseKeyValueStore[T]
 	Lister[T]
+	WhereDeleter
+	WhereUpdater[T]
 	ErrorMapSetter
 	Resetter
+	ClockSetter
 }
 
 func NewKeyValueStore[T any](rdb redis.UniversalClient, namespace string, s Serializer[T]) KeyValueStore[T] {
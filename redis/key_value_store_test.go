@@ -41,6 +41,22 @@ func TestRedisKeyValueStore(t *testing.T) {
 	test.TestBaseKeyValueStore(t, newStore)
 }
 
+func TestRedisKeyValueStoreWhereDeleter(t *testing.T) {
+	newStoreConstructor := spawnNewKeyValueStore[test.Person](t)
+	newStore := func(t *testing.T) test.TestWhereDeleterInterface[test.Person] {
+		return newStoreConstructor(t)
+	}
+	test.TestWhereDeleter(t, newStore)
+}
+
+func TestRedisKeyValueStoreWhereUpdater(t *testing.T) {
+	newStoreConstructor := spawnNewKeyValueStore[test.Person](t)
+	newStore := func(t *testing.T) test.TestWhereUpdaterInterface[test.Person] {
+		return newStoreConstructor(t)
+	}
+	test.TestWhereUpdater(t, newStore)
+}
+
 func spawnNewKeyValueStore[T any](t *testing.T) func(*testing.T) KeyValueStore[T] {
 	t.Helper()
 	s := miniredis.RunT(t)
@@ -0,0 +1,170 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package redis
+
+import (
+	"context"
+	"time"
+
+	//lint:ignore ST1001 shared definitions
+	. "github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/lock"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+type Locker interface {
+	lock.Locker
+	Resetter
+}
+
+func NewLocker(rdb redis.UniversalClient, namespace string) Locker {
+	return &locker{
+		rdb:       rdb,
+		namespace: namespace,
+	}
+}
+
+type locker struct {
+	rdb       redis.UniversalClient
+	namespace string
+}
+
+// Owners are random and unique to each acquisition, so comparing the owner
+// stored under the lease key is enough to tell whether a lease is still held.
+var (
+	acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+	renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+)
+
+func (l *locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*lock.Lease, error) {
+	if name == "" {
+		return nil, lock.ErrEmptyName
+	}
+	if ttl <= 0 {
+		return nil, lock.ErrInvalidTTL
+	}
+	owner := uuid.NewString()
+	token, err := acquireScript.Run(ctx, l.rdb,
+		[]string{l.leaseKey(name), l.tokenKey(name)},
+		owner, ttl.Milliseconds(),
+	).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if token == 0 {
+		return nil, lock.ErrLocked
+	}
+	return &lock.Lease{Name: name, Owner: owner, Token: token}, nil
+}
+
+func (l *locker) Renew(ctx context.Context, lease *lock.Lease, ttl time.Duration) error {
+	if ttl <= 0 {
+		return lock.ErrInvalidTTL
+	}
+	ok, err := renewScript.Run(ctx, l.rdb,
+		[]string{l.leaseKey(lease.Name)},
+		lease.Owner, ttl.Milliseconds(),
+	).Bool()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return lock.ErrNotHeld
+	}
+	return nil
+}
+
+func (l *locker) Release(ctx context.Context, lease *lock.Lease) error {
+	ok, err := releaseScript.Run(ctx, l.rdb,
+		[]string{l.leaseKey(lease.Name)},
+		lease.Owner,
+	).Bool()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return lock.ErrNotHeld
+	}
+	return nil
+}
+
+func (l *locker) Reset(ctx context.Context) error {
+	// SCAN only covers the node it is sent to, so every master of a cluster
+	// is scanned separately.
+	if cluster, ok := l.rdb.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return l.reset(ctx, client)
+		})
+	}
+	return l.reset(ctx, l.rdb)
+}
+
+func (l *locker) reset(ctx context.Context, rdb redis.Cmdable) error {
+	var cursor uint64
+	for {
+		keys, next, err := rdb.Scan(ctx, cursor, l.namespace+":*", 100).Result()
+		if err != nil {
+			return err
+		}
+		// Keys are deleted one by one, since they may belong to different
+		// slots.
+		_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Del(ctx, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// The lease and token keys of a name share a hash tag, so that they belong to
+// the same slot of a cluster, as acquireScript requires.
+
+func (l *locker) leaseKey(name string) string {
+	return l.namespace + ":{" + name + "}:lease"
+}
+
+func (l *locker) tokenKey(name string) string {
+	return l.namespace + ":{" + name + "}:token"
+}
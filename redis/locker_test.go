@@ -0,0 +1,57 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/ArnaudCalmettes/store/lock"
+	. "github.com/ArnaudCalmettes/store/test"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRedisLocker(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	newLocker := func(t *testing.T) lock.Locker {
+		suffix := make([]byte, 4)
+		rand.Read(suffix)
+		locker := NewLocker(rdb, fmt.Sprintf("locker_%s", hex.EncodeToString(suffix)))
+		t.Cleanup(func() {
+			locker.Reset(context.Background())
+		})
+		return locker
+	}
+	TestLocker(t, newLocker, s.FastForward)
+}
+
+func TestRedisLockerKeysShareSlot(t *testing.T) {
+	l := NewLocker(nil, "ns").(*locker)
+	Expect(t,
+		Equal("ns:{name}:lease", l.leaseKey("name")),
+		Equal("ns:{name}:token", l.tokenKey("name")),
+	)
+}
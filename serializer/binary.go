@@ -0,0 +1,309 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"context"
+	"sync"
+
+	//lint:ignore ST1001 shared definitions
+	. "github.com/ArnaudCalmettes/store"
+)
+
+type BinaryMap interface {
+	BaseBinaryMap
+	ErrorMapSetter
+	Resetter
+}
+
+// Buffers larger than this aren't returned to the pool, so that a few large
+// values don't keep memory busy.
+const maxPooledBufferSize = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 512)
+		return &buf
+	},
+}
+
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(buf *[]byte) {
+	if cap(*buf) > maxPooledBufferSize {
+		return
+	}
+	*buf = (*buf)[:0]
+	bufferPool.Put(buf)
+}
+
+// ToBinarySerializer returns s if it implements BinarySerializer, and an
+// adapter otherwise.
+func ToBinarySerializer[T any](s Serializer[T]) BinarySerializer[T] {
+	if b, ok := s.(BinarySerializer[T]); ok {
+		return b
+	}
+	return binarySerializerAdapter[T]{s}
+}
+
+type binarySerializerAdapter[T any] struct {
+	inner Serializer[T]
+}
+
+func (b binarySerializerAdapter[T]) SerializeAppend(dst []byte, obj *T) ([]byte, error) {
+	data, err := b.inner.Serialize(obj)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, data...), nil
+}
+
+func (b binarySerializerAdapter[T]) DeserializeBytes(data []byte) (*T, error) {
+	return b.inner.Deserialize(string(data))
+}
+
+// ToSerializer returns b if it implements Serializer, and an adapter
+// otherwise.
+func ToSerializer[T any](b BinarySerializer[T]) Serializer[T] {
+	if s, ok := b.(Serializer[T]); ok {
+		return s
+	}
+	return serializerAdapter[T]{b}
+}
+
+type serializerAdapter[T any] struct {
+	inner BinarySerializer[T]
+}
+
+func (s serializerAdapter[T]) Serialize(obj *T) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	data, err := s.inner.SerializeAppend(*buf, obj)
+	*buf = data
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (s serializerAdapter[T]) Deserialize(data string) (*T, error) {
+	return s.inner.DeserializeBytes([]byte(data))
+}
+
+// ToBinaryMap adapts a Map to the BinaryMap interface. Values are copied
+// from one representation to the other.
+func ToBinaryMap(m Map) BinaryMap {
+	return binaryMapAdapter{m}
+}
+
+type binaryMapAdapter struct {
+	Map
+}
+
+func (b binaryMapAdapter) GetOne(ctx context.Context, key string) ([]byte, error) {
+	value, err := b.Map.GetOne(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+func (b binaryMapAdapter) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	items, err := b.Map.GetMany(ctx, keys)
+	return toBytesMap(items), err
+}
+
+func (b binaryMapAdapter) GetAll(ctx context.Context) (map[string][]byte, error) {
+	items, err := b.Map.GetAll(ctx)
+	return toBytesMap(items), err
+}
+
+func (b binaryMapAdapter) SetOne(ctx context.Context, key string, value []byte) error {
+	return b.Map.SetOne(ctx, key, string(value))
+}
+
+func (b binaryMapAdapter) SetMany(ctx context.Context, items map[string][]byte) error {
+	return b.Map.SetMany(ctx, toStringMap(items))
+}
+
+func (b binaryMapAdapter) UpdateOne(ctx context.Context, key string, update func(string, []byte) ([]byte, error)) error {
+	return b.Map.UpdateOne(ctx, key, toStringUpdate(update))
+}
+
+func (b binaryMapAdapter) UpdateMany(ctx context.Context, keys []string, update func(string, []byte) ([]byte, error)) error {
+	return b.Map.UpdateMany(ctx, keys, toStringUpdate(update))
+}
+
+func (b binaryMapAdapter) DeleteIf(ctx context.Context, keys []string, cond func(string, []byte) bool) (int, error) {
+	return deleteIf(ctx, b.Map, keys, func(key, value string) bool {
+		return cond(key, []byte(value))
+	})
+}
+
+// ToMap adapts a BinaryMap to the Map interface. Values are copied from one
+// representation to the other.
+func ToMap(b BinaryMap) Map {
+	return mapAdapter{b}
+}
+
+type mapAdapter struct {
+	BinaryMap
+}
+
+func (m mapAdapter) GetOne(ctx context.Context, key string) (string, error) {
+	value, err := m.BinaryMap.GetOne(ctx, key)
+	return string(value), err
+}
+
+func (m mapAdapter) GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	items, err := m.BinaryMap.GetMany(ctx, keys)
+	return toStringMap(items), err
+}
+
+func (m mapAdapter) GetAll(ctx context.Context) (map[string]string, error) {
+	items, err := m.BinaryMap.GetAll(ctx)
+	return toStringMap(items), err
+}
+
+func (m mapAdapter) SetOne(ctx context.Context, key string, value string) error {
+	return m.BinaryMap.SetOne(ctx, key, []byte(value))
+}
+
+func (m mapAdapter) SetMany(ctx context.Context, items map[string]string) error {
+	return m.BinaryMap.SetMany(ctx, toBytesMap(items))
+}
+
+func (m mapAdapter) UpdateOne(ctx context.Context, key string, update func(string, *string) (*string, error)) error {
+	return m.BinaryMap.UpdateOne(ctx, key, toBytesUpdate(update))
+}
+
+func (m mapAdapter) UpdateMany(ctx context.Context, keys []string, update func(string, *string) (*string, error)) error {
+	return m.BinaryMap.UpdateMany(ctx, keys, toBytesUpdate(update))
+}
+
+func (m mapAdapter) DeleteIf(ctx context.Context, keys []string, cond func(string, string) bool) (int, error) {
+	return deleteIfBinary(ctx, m.BinaryMap, keys, func(key string, value []byte) bool {
+		return cond(key, string(value))
+	})
+}
+
+// deleteIf deletes the keys of m whose value satisfies cond, atomically if m
+// is a ConditionalDeleter. Otherwise, cond is checked on values read before
+// the deletion, which may have changed in the meantime.
+func deleteIf(ctx context.Context, m BaseKeyValueMap, keys []string, cond func(string, string) bool) (int, error) {
+	if deleter, ok := m.(ConditionalDeleter); ok {
+		return deleter.DeleteIf(ctx, keys, cond)
+	}
+	items, err := m.GetMany(ctx, keys)
+	if _, ok := AsBatchError(err); !ok {
+		return 0, err
+	}
+	deleted := make([]string, 0, len(items))
+	for key, value := range items {
+		if cond(key, value) {
+			deleted = append(deleted, key)
+		}
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+	if err := m.Delete(ctx, deleted...); err != nil {
+		return 0, err
+	}
+	return len(deleted), nil
+}
+
+// deleteIfBinary is the byte-oriented counterpart of deleteIf.
+func deleteIfBinary(ctx context.Context, m BaseBinaryMap, keys []string, cond func(string, []byte) bool) (int, error) {
+	if deleter, ok := m.(BinaryConditionalDeleter); ok {
+		return deleter.DeleteIf(ctx, keys, cond)
+	}
+	items, err := m.GetMany(ctx, keys)
+	if _, ok := AsBatchError(err); !ok {
+		return 0, err
+	}
+	deleted := make([]string, 0, len(items))
+	for key, value := range items {
+		if cond(key, value) {
+			deleted = append(deleted, key)
+		}
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+	if err := m.Delete(ctx, deleted...); err != nil {
+		return 0, err
+	}
+	return len(deleted), nil
+}
+
+func toBytesMap(in map[string]string) map[string][]byte {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string][]byte, len(in))
+	for key, value := range in {
+		out[key] = []byte(value)
+	}
+	return out
+}
+
+func toStringMap(in map[string][]byte) map[string]string {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]string, len(in))
+	for key, value := range in {
+		out[key] = string(value)
+	}
+	return out
+}
+
+func toStringUpdate(update func(string, []byte) ([]byte, error)) func(string, *string) (*string, error) {
+	return func(key string, value *string) (*string, error) {
+		var data []byte
+		if value != nil {
+			data = []byte(*value)
+		}
+		newData, err := update(key, data)
+		if err != nil || newData == nil {
+			return nil, err
+		}
+		newValue := string(newData)
+		return &newValue, nil
+	}
+}
+
+func toBytesUpdate(update func(string, *string) (*string, error)) func(string, []byte) ([]byte, error) {
+	return func(key string, data []byte) ([]byte, error) {
+		var value *string
+		if data != nil {
+			v := string(data)
+			value = &v
+		}
+		newValue, err := update(key, value)
+		if err != nil || newValue == nil {
+			return nil, err
+		}
+		return []byte(*newValue), nil
+	}
+}
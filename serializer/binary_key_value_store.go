@@ -0,0 +1,175 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"context"
+	"errors"
+
+	//lint:ignore ST1001 shared definitions
+	. "github.com/ArnaudCalmettes/store"
+	"golang.org/x/exp/maps"
+)
+
+// NewBinaryKeyValueStore returns a KeyValueStore that serializes values into
+// pooled buffers, and deserializes them without converting them to strings.
+// It panics if T is not a valid model (see TryNewKeyValueStore).
+func NewBinaryKeyValueStore[T any](serializer BinarySerializer[T], storage BinaryMap) KeyValueStore[T] {
+	return must(TryNewBinaryKeyValueStore(serializer, storage))
+}
+
+// TryNewBinaryKeyValueStore is like NewBinaryKeyValueStore, but returns a
+// *ModelError if T is not a valid model.
+func TryNewBinaryKeyValueStore[T any](serializer BinarySerializer[T], storage BinaryMap) (KeyValueStore[T], error) {
+	k, err := newKeyValueStore(ToSerializer(serializer), ToMap(storage))
+	if err != nil {
+		return nil, err
+	}
+	return &binaryKeyValueStore[T]{
+		keyValueStore:    k,
+		binarySerializer: serializer,
+		binaryStorage:    storage,
+	}, nil
+}
+
+// binaryKeyValueStore implements the reads and writes of values natively, and
+// relies on adapters for the rest.
+type binaryKeyValueStore[T any] struct {
+	*keyValueStore[T]
+	binarySerializer BinarySerializer[T]
+	binaryStorage    BinaryMap
+}
+
+func (k *binaryKeyValueStore[T]) GetOne(ctx context.Context, key string) (*T, error) {
+	if key == "" {
+		return nil, k.ErrEmptyKey
+	}
+	data, err := k.binaryStorage.GetOne(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := k.binarySerializer.DeserializeBytes(data)
+	if err != nil {
+		err = errors.Join(k.ErrDeserialize, err)
+	}
+	return value, err
+}
+
+func (k *binaryKeyValueStore[T]) GetMany(ctx context.Context, keys []string) (map[string]*T, error) {
+	serializedItems, err := k.binaryStorage.GetMany(ctx, keys)
+	batch, ok := AsBatchError(err)
+	if !ok {
+		return nil, err
+	}
+	return k.deserializeBytesMap(ctx, serializedItems, batch)
+}
+
+func (k *binaryKeyValueStore[T]) GetAll(ctx context.Context) (map[string]*T, error) {
+	all, err := k.binaryStorage.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return k.deserializeBytesMap(ctx, all, &BatchError{})
+}
+
+func (k *binaryKeyValueStore[T]) SetOne(ctx context.Context, key string, value *T) error {
+	if k.auto != nil && value != nil {
+		prev, err := k.previousBytes(ctx, []string{key})
+		if err != nil {
+			return err
+		}
+		value = k.touch(value, prev[key])
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	data, err := k.binarySerializer.SerializeAppend(*buf, value)
+	*buf = data
+	if err != nil {
+		return errors.Join(k.ErrSerialize, err)
+	}
+	return k.binaryStorage.SetOne(ctx, key, data)
+}
+
+func (k *binaryKeyValueStore[T]) SetMany(ctx context.Context, items map[string]*T) error {
+	if k.auto != nil {
+		prev, err := k.previousBytes(ctx, maps.Keys(items))
+		if err != nil {
+			return err
+		}
+		items = k.touchMany(items, prev)
+	}
+	serializedItems := make(map[string][]byte, len(items))
+	buffers := make([]*[]byte, 0, len(items))
+	defer func() {
+		for _, buf := range buffers {
+			putBuffer(buf)
+		}
+	}()
+	batch := &BatchError{}
+	for key, value := range items {
+		buf := getBuffer()
+		buffers = append(buffers, buf)
+		data, err := k.binarySerializer.SerializeAppend(*buf, value)
+		*buf = data
+		if err != nil {
+			batch.Add(key, errors.Join(k.ErrSerialize, err))
+			continue
+		}
+		serializedItems[key] = data
+	}
+	err := k.binaryStorage.SetMany(ctx, serializedItems)
+	storageBatch, ok := AsBatchError(err)
+	if !ok {
+		return err
+	}
+	for key, err := range storageBatch.Errors {
+		batch.Add(key, err)
+	}
+	return batch.ErrOrNil()
+}
+
+// previousBytes is like keyValueStore.previous, without converting the values
+// to strings.
+func (k *binaryKeyValueStore[T]) previousBytes(ctx context.Context, keys []string) (map[string]*T, error) {
+	all, err := k.binaryStorage.GetMany(ctx, keys)
+	if _, ok := AsBatchError(err); !ok {
+		return nil, err
+	}
+	prev := make(map[string]*T, len(all))
+	for key, data := range all {
+		if value, err := k.binarySerializer.DeserializeBytes(data); err == nil {
+			prev[key] = value
+		}
+	}
+	return prev, nil
+}
+
+func (k *binaryKeyValueStore[T]) deserializeBytesMap(ctx context.Context, in map[string][]byte, batch *BatchError) (map[string]*T, error) {
+	out := make(map[string]*T, len(in))
+	for key, data := range in {
+		value, err := k.binarySerializer.DeserializeBytes(data)
+		if err != nil {
+			k.corrupt(ctx, key, string(data), errors.Join(k.ErrDeserialize, err), batch)
+			continue
+		}
+		out[key] = value
+	}
+	return out, batch.ErrOrNil()
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/memory"
+	. "github.com/ArnaudCalmettes/store/test"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+func TestBinarySerializers(t *testing.T) {
+	serializers := map[string]Serializer[Entry]{
+		"json":    NewJSON[Entry](),
+		"msgpack": NewMsgpack[Entry](),
+		"gob":     NewGob[Entry](),
+	}
+	input := Entry{Int: 42, String: "test"}
+	for name, s := range serializers {
+		t.Run(name, func(t *testing.T) {
+			b := ToBinarySerializer(s)
+			data, err := s.Serialize(&input)
+			Require(t, NoError(err))
+
+			prefix := []byte("prefix")
+			result, err := b.SerializeAppend(prefix, &input)
+			Expect(t,
+				NoError(err),
+				Equal("prefix"+data, string(result)),
+			)
+
+			value, err := b.DeserializeBytes(result[len(prefix):])
+			Expect(t,
+				NoError(err),
+				Equal(&input, value),
+			)
+
+			_, err = b.SerializeAppend(nil, nil)
+			Expect(t, IsError(ErrNilObject, err))
+			_, err = b.DeserializeBytes(nil)
+			Expect(t, IsError(ErrEmptyData, err))
+		})
+	}
+	t.Run("to serializer", func(t *testing.T) {
+		s := ToSerializer(binarySerializerAdapter[Entry]{NewGob[Entry]()})
+		data, err := s.Serialize(&input)
+		Require(t, NoError(err))
+		value, err := s.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(&input, value),
+		)
+	})
+}
+
+func TestBinaryMapAdapters(t *testing.T) {
+	t.Run("binary map", func(t *testing.T) {
+		newMap := func(*testing.T) BaseKeyValueMap {
+			return ToMap(memory.NewBinaryMap())
+		}
+		TestBaseKeyValueMap(t, newMap)
+	})
+	t.Run("map", func(t *testing.T) {
+		newMap := func(*testing.T) BaseKeyValueMap {
+			return ToMap(ToBinaryMap(memory.NewKeyValueMap()))
+		}
+		TestBaseKeyValueMap(t, newMap)
+	})
+}
+
+func TestBinaryKeyValueStore(t *testing.T) {
+	newStore := func(*testing.T) BaseKeyValueStore[Entry] {
+		return NewBinaryKeyValueStore(
+			ToBinarySerializer(NewMsgpack[Entry]()),
+			memory.NewBinaryMap(),
+		)
+	}
+	TestBaseKeyValueStore(t, newStore)
+}
+
+func TestBinaryKeyValueStoreLister(t *testing.T) {
+	newStore := func(*testing.T) TestListerInterface[Person] {
+		return NewBinaryKeyValueStore(
+			ToBinarySerializer(NewJSON[Person]()),
+			memory.NewBinaryMap(),
+		)
+	}
+	TestLister(t, newStore)
+}
+
+func benchmarkEntries(n int) map[string]*Entry {
+	entries := make(map[string]*Entry, n)
+	for i := 0; i < n; i++ {
+		entries[fmt.Sprint(i)] = &Entry{
+			Int:    i,
+			Float:  float64(i) / 3,
+			String: fmt.Sprintf("entry number %d", i),
+		}
+	}
+	return entries
+}
+
+func benchmarkStores() map[string]func() BaseKeyValueStore[Entry] {
+	return map[string]func() BaseKeyValueStore[Entry]{
+		"string/json": func() BaseKeyValueStore[Entry] {
+			return NewKeyValueStore(NewJSON[Entry](), memory.NewKeyValueMap())
+		},
+		"binary/json": func() BaseKeyValueStore[Entry] {
+			return NewBinaryKeyValueStore(ToBinarySerializer(NewJSON[Entry]()), memory.NewBinaryMap())
+		},
+		"string/msgpack": func() BaseKeyValueStore[Entry] {
+			return NewKeyValueStore(NewMsgpack[Entry](), memory.NewKeyValueMap())
+		},
+		"binary/msgpack": func() BaseKeyValueStore[Entry] {
+			return NewBinaryKeyValueStore(ToBinarySerializer(NewMsgpack[Entry]()), memory.NewBinaryMap())
+		},
+	}
+}
+
+func BenchmarkSetMany(b *testing.B) {
+	ctx := context.Background()
+	entries := benchmarkEntries(100)
+	for name, newStore := range benchmarkStores() {
+		b.Run(name, func(b *testing.B) {
+			s := newStore()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := s.SetMany(ctx, entries); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetAll(b *testing.B) {
+	ctx := context.Background()
+	entries := benchmarkEntries(100)
+	for name, newStore := range benchmarkStores() {
+		b.Run(name, func(b *testing.B) {
+			s := newStore()
+			if err := s.SetMany(ctx, entries); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := s.GetAll(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"slices"
+	"strings"
+
+	"github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/internal/zerocopy"
+)
+
+const checksumSize = 4
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksummed wraps a serializer to append a CRC32C checksum to the values
+// it produces, and to check it when reading them back. Values that don't
+// match their checksum (e.g. truncated values) fail with ErrCorrupt.
+//
+// Values written without a checksum can't be read: use MultiFormat to
+// enable it on an existing namespace.
+func Checksummed[T any](inner store.Serializer[T]) store.Serializer[T] {
+	return checksummed[T]{inner}
+}
+
+type checksummed[T any] struct {
+	inner store.Serializer[T]
+}
+
+func (c checksummed[T]) Serialize(obj *T) (string, error) {
+	data, err := c.inner.Serialize(obj)
+	if err != nil {
+		return "", err
+	}
+	var sum [checksumSize]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Checksum(zerocopy.StringToBytes(data), castagnoli))
+	var out strings.Builder
+	out.Grow(len(data) + checksumSize)
+	out.WriteString(data)
+	out.Write(sum[:])
+	return out.String(), nil
+}
+
+func (c checksummed[T]) Deserialize(data string) (*T, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyData
+	}
+	payload, ok := checksumPayload(data)
+	if !ok {
+		return nil, ErrCorrupt
+	}
+	return c.inner.Deserialize(payload)
+}
+
+// NeedsRewrite reports whether the serializer wrapped by c needs the payload
+// of data to be rewritten.
+func (c checksummed[T]) NeedsRewrite(data string) bool {
+	r, ok := c.inner.(Rewriter)
+	if !ok {
+		return false
+	}
+	payload, ok := checksumPayload(data)
+	return ok && r.NeedsRewrite(payload)
+}
+
+// checksumPayload returns data without its checksum, if it matches.
+func checksumPayload(data string) (string, bool) {
+	if len(data) <= checksumSize {
+		return "", false
+	}
+	payload, sum := data[:len(data)-checksumSize], data[len(data)-checksumSize:]
+	want := binary.BigEndian.Uint32(zerocopy.StringToBytes(sum))
+	return payload, crc32.Checksum(zerocopy.StringToBytes(payload), castagnoli) == want
+}
+
+// Verify deserializes every entry of storage, and returns the keys of the
+// entries that failed with ErrCorrupt, in order. Other deserialization errors
+// are returned as a BatchError.
+//
+// It reads storage directly rather than through a KeyValueStore, which may
+// skip or quarantine the entries it can't deserialize.
+func Verify[T any](ctx context.Context, storage store.BaseKeyValueMap, serializer store.Serializer[T]) ([]string, error) {
+	all, err := storage.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var corrupt []string
+	others := &store.BatchError{}
+	for key, data := range all {
+		_, err := serializer.Deserialize(data)
+		switch {
+		case errors.Is(err, ErrCorrupt):
+			corrupt = append(corrupt, key)
+		case err != nil:
+			others.Add(key, err)
+		}
+	}
+	slices.Sort(corrupt)
+	return corrupt, others.ErrOrNil()
+}
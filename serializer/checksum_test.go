@@ -0,0 +1,105 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/memory"
+	. "github.com/ArnaudCalmettes/store/test"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+func TestChecksummed(t *testing.T) {
+	s := Checksummed(NewJSON[Entry]())
+	input := Entry{Int: 42, String: "test"}
+	data, err := s.Serialize(&input)
+	Require(t, NoError(err))
+
+	t.Run("nominal", func(t *testing.T) {
+		result, err := s.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(&input, result),
+		)
+	})
+	t.Run("nil", func(t *testing.T) {
+		_, err := s.Serialize(nil)
+		Expect(t, IsError(ErrNilObject, err))
+	})
+	t.Run("empty", func(t *testing.T) {
+		_, err := s.Deserialize("")
+		Expect(t, IsError(ErrEmptyData, err))
+	})
+	t.Run("truncated", func(t *testing.T) {
+		for _, n := range []int{1, 3, 5, len(data) - 1} {
+			_, err := s.Deserialize(data[:n])
+			Expect(t, IsErrorf(ErrCorrupt, err, "truncated to %d bytes", n))
+		}
+	})
+	t.Run("modified", func(t *testing.T) {
+		raw := []byte(data)
+		raw[2] ^= 1
+		_, err := s.Deserialize(string(raw))
+		Expect(t, IsError(ErrCorrupt, err))
+	})
+}
+
+func TestVerify(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	m := memory.NewKeyValueMap()
+	serializer := Checksummed(NewJSON[Entry]())
+	s := NewKeyValueStore(serializer, m)
+	Require(t, NoError(s.SetMany(ctx, map[string]*Entry{
+		"one":   {Int: 1},
+		"two":   {Int: 2},
+		"three": {Int: 3},
+	})))
+
+	corrupt, err := Verify(ctx, m, serializer)
+	Expect(t,
+		NoError(err),
+		SliceHasLength(0, corrupt),
+	)
+
+	data, err := m.GetOne(ctx, "two")
+	Require(t, NoError(err))
+	Require(t, NoError(m.SetOne(ctx, "two", data[:len(data)/2])))
+	Require(t, NoError(m.SetOne(ctx, "one", `{"Int": 1}`)))
+
+	corrupt, err = Verify(ctx, m, serializer)
+	Expect(t,
+		NoError(err),
+		Equal([]string{"one", "two"}, corrupt),
+	)
+}
+
+func TestChecksummedKeyValueStore(t *testing.T) {
+	newStore := func(*testing.T) BaseKeyValueStore[Entry] {
+		return NewKeyValueStore(
+			Checksummed(NewJSON[Entry]()),
+			memory.NewKeyValueMap(),
+		)
+	}
+	TestBaseKeyValueStore(t, newStore)
+}
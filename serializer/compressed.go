@@ -0,0 +1,235 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/ArnaudCalmettes/store"
+)
+
+type Compression byte
+
+const (
+	Gzip Compression = iota + 1
+	Flate
+)
+
+const (
+	DefaultCompressionThreshold = 1024
+	DefaultMaxDecompressedSize  = 64 << 20
+)
+
+// CompressionOptions configure the Compressed serializer. The zero value uses
+// gzip with the default compression level and threshold.
+type CompressionOptions struct {
+	Algorithm Compression
+	Level     int
+
+	// Values shorter than Threshold bytes are stored uncompressed. A negative
+	// threshold compresses every value.
+	Threshold int
+
+	// Values that decompress to more than MaxDecompressedSize bytes fail to
+	// deserialize, so that small corrupt or malicious values can't exhaust
+	// memory. It defaults to DefaultMaxDecompressedSize.
+	MaxDecompressedSize int
+}
+
+var (
+	errUnknownCompression = errors.New("unknown compression algorithm")
+	errDecompressedSize   = errors.New("decompressed value is too large")
+)
+
+// Compressed wraps a serializer to compress the values it produces.
+//
+// Compressed values are prefixed with a header made of compressionMagic and a
+// byte identifying the algorithm. Values without this header are passed to
+// the inner serializer as-is, so that compression can be turned on for
+// existing data without migrating it, as long as no existing value starts
+// with compressionMagic, which JSON, msgpack and gob values never do. Values
+// written in other formats should be migrated with MultiFormat instead.
+func Compressed[T any](inner store.Serializer[T], opts *CompressionOptions) store.Serializer[T] {
+	c := &compressed[T]{
+		inner:     inner,
+		algorithm: Gzip,
+		level:     flate.DefaultCompression,
+		threshold: DefaultCompressionThreshold,
+		maxSize:   DefaultMaxDecompressedSize,
+	}
+	if opts != nil {
+		if opts.Algorithm != 0 {
+			c.algorithm = opts.Algorithm
+		}
+		if opts.Level != 0 {
+			c.level = opts.Level
+		}
+		if opts.Threshold != 0 {
+			c.threshold = max(opts.Threshold, 0)
+		}
+		if opts.MaxDecompressedSize > 0 {
+			c.maxSize = opts.MaxDecompressedSize
+		}
+	}
+	switch c.algorithm {
+	case Gzip:
+		_, c.err = gzip.NewWriterLevel(io.Discard, c.level)
+		c.writers.New = func() any {
+			w, _ := gzip.NewWriterLevel(nil, c.level)
+			return w
+		}
+	case Flate:
+		_, c.err = flate.NewWriter(io.Discard, c.level)
+		c.writers.New = func() any {
+			w, _ := flate.NewWriter(nil, c.level)
+			return w
+		}
+	default:
+		c.err = fmt.Errorf("%w: %d", errUnknownCompression, c.algorithm)
+	}
+	return c
+}
+
+// compressionMagic starts the header of compressed values. 0xc1 is never used
+// by msgpack, and can't start a UTF-8 string. Uncompressed values that happen
+// to start with it are escaped with compressionNone.
+const compressionMagic = "\xc1Z"
+
+const (
+	compressionNone  byte = 0
+	compressionGzip  byte = byte(Gzip)
+	compressionFlate byte = byte(Flate)
+)
+
+const compressionHeaderSize = len(compressionMagic) + 1
+
+type compressed[T any] struct {
+	inner     store.Serializer[T]
+	algorithm Compression
+	level     int
+	threshold int
+	maxSize   int
+	writers   sync.Pool
+	err       error
+}
+
+type compressWriter interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+func (c *compressed[T]) Serialize(obj *T) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	data, err := c.inner.Serialize(obj)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < c.threshold {
+		return escapeUncompressed(data), nil
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Grow(len(data)/2 + compressionHeaderSize)
+	buf.WriteString(compressionMagic)
+	buf.WriteByte(byte(c.algorithm))
+	w := c.writers.Get().(compressWriter)
+	defer c.writers.Put(w)
+	w.Reset(buf)
+	if _, err := io.WriteString(w, data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	if buf.Len() >= len(data) {
+		// Not worth it.
+		return escapeUncompressed(data), nil
+	}
+	return buf.String(), nil
+}
+
+func escapeUncompressed(data string) string {
+	if strings.HasPrefix(data, compressionMagic) {
+		return compressionMagic + string(compressionNone) + data
+	}
+	return data
+}
+
+func (c *compressed[T]) Deserialize(data string) (*T, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyData
+	}
+	raw, err := c.decompress(data)
+	if err != nil {
+		return nil, err
+	}
+	return c.inner.Deserialize(raw)
+}
+
+// NeedsRewrite reports whether the serializer wrapped by c needs the
+// decompressed data to be rewritten.
+func (c *compressed[T]) NeedsRewrite(data string) bool {
+	r, ok := c.inner.(Rewriter)
+	if !ok {
+		return false
+	}
+	raw, err := c.decompress(data)
+	return err == nil && r.NeedsRewrite(raw)
+}
+
+// decompress returns what the inner serializer produced for data.
+func (c *compressed[T]) decompress(data string) (string, error) {
+	if len(data) < compressionHeaderSize || !strings.HasPrefix(data, compressionMagic) {
+		return data, nil
+	}
+	payload := data[compressionHeaderSize:]
+	var r io.ReadCloser
+	switch data[len(compressionMagic)] {
+	case compressionNone:
+		return payload, nil
+	case compressionGzip:
+		var err error
+		if r, err = gzip.NewReader(strings.NewReader(payload)); err != nil {
+			return "", err
+		}
+	case compressionFlate:
+		r = flate.NewReader(strings.NewReader(payload))
+	default:
+		return "", errUnknownCompression
+	}
+	defer r.Close()
+	raw, err := io.ReadAll(io.LimitReader(r, int64(c.maxSize)+1))
+	if err != nil {
+		return "", err
+	}
+	if len(raw) > c.maxSize {
+		return "", errDecompressedSize
+	}
+	return string(raw), nil
+}
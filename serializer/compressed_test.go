@@ -0,0 +1,172 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/memory"
+	. "github.com/ArnaudCalmettes/store/test"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+// stringSerializer stores strings as they are.
+type stringSerializer struct{}
+
+func (stringSerializer) Serialize(obj *string) (string, error) {
+	if obj == nil {
+		return "", ErrNilObject
+	}
+	return *obj, nil
+}
+
+func (stringSerializer) Deserialize(data string) (*string, error) {
+	return &data, nil
+}
+
+func TestCompressed(t *testing.T) {
+	large := Entry{Int: 1, String: strings.Repeat("large", 1000)}
+	small := Entry{Int: 2, String: "small"}
+
+	for _, algorithm := range []Compression{Gzip, Flate} {
+		s := Compressed(NewJSON[Entry](), &CompressionOptions{
+			Algorithm: algorithm,
+			Threshold: 100,
+		})
+		t.Run("large", func(t *testing.T) {
+			data, err := s.Serialize(&large)
+			Require(t, NoError(err))
+			Expect(t,
+				Equal(compressionMagic+string(byte(algorithm)), data[:compressionHeaderSize]),
+				Equalf(true, len(data) < len(large.String), "value wasn't compressed"),
+			)
+
+			result, err := s.Deserialize(data)
+			Expect(t,
+				NoError(err),
+				Equal(&large, result),
+			)
+		})
+		t.Run("below threshold", func(t *testing.T) {
+			data, err := s.Serialize(&small)
+			Require(t, NoError(err))
+			raw, err := NewJSON[Entry]().Serialize(&small)
+			Require(t, NoError(err))
+			Expect(t, Equal(raw, data))
+
+			result, err := s.Deserialize(data)
+			Expect(t,
+				NoError(err),
+				Equal(&small, result),
+			)
+		})
+	}
+	t.Run("nil", func(t *testing.T) {
+		s := Compressed(NewJSON[Entry](), nil)
+		_, err := s.Serialize(nil)
+		Expect(t, IsError(ErrNilObject, err))
+	})
+	t.Run("empty", func(t *testing.T) {
+		s := Compressed(NewJSON[Entry](), nil)
+		_, err := s.Deserialize("")
+		Expect(t, IsError(ErrEmptyData, err))
+	})
+	t.Run("escape header", func(t *testing.T) {
+		s := Compressed[string](stringSerializer{}, nil)
+		input := compressionMagic + "value"
+		data, err := s.Serialize(&input)
+		Require(t, NoError(err))
+		Expect(t, Equal(compressionMagic+string(compressionNone)+input, data))
+
+		result, err := s.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(&input, result),
+		)
+	})
+	t.Run("too large", func(t *testing.T) {
+		s := Compressed(NewJSON[Entry](), &CompressionOptions{
+			Threshold:           -1,
+			MaxDecompressedSize: 1000,
+		})
+		data, err := s.Serialize(&large)
+		Require(t, NoError(err))
+		_, err = s.Deserialize(data)
+		Expect(t, IsError(errDecompressedSize, err))
+	})
+	t.Run("unknown algorithm", func(t *testing.T) {
+		s := Compressed(NewJSON[Entry](), &CompressionOptions{Algorithm: 42})
+		_, err := s.Serialize(&small)
+		Expect(t, IsError(errUnknownCompression, err))
+	})
+	t.Run("invalid level", func(t *testing.T) {
+		s := Compressed(NewJSON[Entry](), &CompressionOptions{Level: 42})
+		_, err := s.Serialize(&small)
+		Expect(t, IsNotZero(err))
+	})
+}
+
+func TestCompressedExistingData(t *testing.T) {
+	for name, s := range map[string]Serializer[Entry]{
+		"json":    NewJSON[Entry](),
+		"msgpack": NewMsgpack[Entry](),
+		"gob":     NewGob[Entry](),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := NewTestContext()
+			defer cancel()
+
+			m := memory.NewKeyValueMap()
+			entries := map[string]*Entry{
+				"small": {Int: 1, String: "small"},
+				"large": {Int: 2, String: strings.Repeat("large", 1000)},
+			}
+			err := NewKeyValueStore(s, m).SetMany(ctx, entries)
+			Require(t, NoError(err))
+
+			store := NewKeyValueStore(Compressed(s, nil), m)
+			result, err := store.GetMany(ctx, []string{"small", "large"})
+			Expect(t,
+				NoError(err),
+				Equal(entries, result),
+			)
+
+			err = store.SetOne(ctx, "large", entries["large"])
+			Require(t, NoError(err))
+			data, err := m.GetOne(ctx, "large")
+			Expect(t,
+				NoError(err),
+				Equal(compressionMagic+string(compressionGzip), data[:compressionHeaderSize]),
+			)
+		})
+	}
+}
+
+func TestCompressedKeyValueStore(t *testing.T) {
+	newStore := func(*testing.T) BaseKeyValueStore[Entry] {
+		return NewKeyValueStore(
+			Compressed(NewJSON[Entry](), &CompressionOptions{Threshold: -1}),
+			memory.NewKeyValueMap(),
+		)
+	}
+	TestBaseKeyValueStore(t, newStore)
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ArnaudCalmettes/store"
+)
+
+var (
+	errEmptyKeyring  = errors.New("keyring has no key")
+	errInvalidKeyID  = errors.New("key id must be between 1 and 255 bytes long")
+	errMalformedData = errors.New("malformed encrypted data")
+)
+
+// Keyring holds the AES keys used by the Encrypted serializer, indexed by id.
+// The current key is used to encrypt new values, and the other keys are kept
+// to decrypt the values they encrypted.
+type Keyring struct {
+	mu      sync.RWMutex
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{
+		aeads: make(map[string]cipher.AEAD),
+	}
+}
+
+// Add registers a 16, 24 or 32 bytes long AES key under the given id, and
+// makes it the current key. Keys should therefore be added from the oldest to
+// the newest, unless SetCurrent is called afterwards.
+func (k *Keyring) Add(id string, key []byte) error {
+	if len(id) == 0 || len(id) > 255 {
+		return errInvalidKeyID
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.aeads[id] = aead
+	k.current = id
+	return nil
+}
+
+// SetCurrent makes the key registered under the given id the current key.
+func (k *Keyring) SetCurrent(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.aeads[id] == nil {
+		return fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	k.current = id
+	return nil
+}
+
+func (k *Keyring) Current() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// Encrypted values are laid out as follows:
+//
+//	| id length (1 byte) | key id | nonce | ciphertext |
+//
+// The header (id length and key id) is authenticated along with the
+// ciphertext.
+func (k *Keyring) seal(plaintext []byte) (string, error) {
+	k.mu.RLock()
+	id, aead := k.current, k.aeads[k.current]
+	k.mu.RUnlock()
+	if aead == nil {
+		return "", errEmptyKeyring
+	}
+	headerLen := 1 + len(id)
+	nonceSize := aead.NonceSize()
+	out := make([]byte, headerLen+nonceSize, headerLen+nonceSize+len(plaintext)+aead.Overhead())
+	out[0] = byte(len(id))
+	copy(out[1:], id)
+	nonce := out[headerLen:]
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	out = aead.Seal(out, nonce, plaintext, out[:headerLen])
+	return string(out), nil
+}
+
+func (k *Keyring) open(data string) ([]byte, error) {
+	id, ok := encryptionKeyID(data)
+	if !ok {
+		return nil, errMalformedData
+	}
+	k.mu.RLock()
+	aead := k.aeads[id]
+	k.mu.RUnlock()
+	if aead == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	headerLen := 1 + len(id)
+	if len(data) < headerLen+aead.NonceSize()+aead.Overhead() {
+		return nil, errMalformedData
+	}
+	raw := []byte(data)
+	nonce := raw[headerLen : headerLen+aead.NonceSize()]
+	ciphertext := raw[headerLen+aead.NonceSize():]
+	return aead.Open(ciphertext[:0], nonce, ciphertext, raw[:headerLen])
+}
+
+func encryptionKeyID(data string) (string, bool) {
+	if len(data) == 0 || data[0] == 0 || len(data) < 1+int(data[0]) {
+		return "", false
+	}
+	return data[1 : 1+int(data[0])], true
+}
+
+// Encrypted wraps a serializer to encrypt the values it produces with
+// AES-GCM, using the current key of the keyring.
+func Encrypted[T any](inner store.Serializer[T], keyring *Keyring) store.Serializer[T] {
+	return &encrypted[T]{
+		inner:   inner,
+		keyring: keyring,
+	}
+}
+
+type encrypted[T any] struct {
+	inner   store.Serializer[T]
+	keyring *Keyring
+}
+
+func (e *encrypted[T]) Serialize(obj *T) (string, error) {
+	data, err := e.inner.Serialize(obj)
+	if err != nil {
+		return "", err
+	}
+	return e.keyring.seal([]byte(data))
+}
+
+func (e *encrypted[T]) Deserialize(data string) (*T, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyData
+	}
+	plaintext, err := e.keyring.open(data)
+	if err != nil {
+		return nil, err
+	}
+	return e.inner.Deserialize(string(plaintext))
+}
+
+// NeedsRewrite reports whether the serializer wrapped by e needs the
+// decrypted data to be rewritten.
+func (e *encrypted[T]) NeedsRewrite(data string) bool {
+	r, ok := e.inner.(Rewriter)
+	if !ok {
+		return false
+	}
+	plaintext, err := e.keyring.open(data)
+	return err == nil && r.NeedsRewrite(string(plaintext))
+}
+
+// Reencrypt encrypts the values of storage that were encrypted with an older
+// key of the keyring with its current key, in batches. It returns the number
+// of values that were re-encrypted.
+//
+// Values are decrypted and encrypted again without being deserialized, so it
+// works regardless of the serializer wrapped by Encrypted. Values that can't
+// be decrypted, e.g. because they were stored before encryption was enabled
+// or their key isn't in the keyring, are left as they are and reported in a
+// BatchError.
+func Reencrypt(ctx context.Context, storage store.BaseKeyValueMap, keyring *Keyring, opts *RewriteOptions) (int, error) {
+	current := keyring.Current()
+	if current == "" {
+		return 0, errEmptyKeyring
+	}
+	outdated := func(data string) bool {
+		id, ok := encryptionKeyID(data)
+		return ok && id != current
+	}
+	return rewriteMap(ctx, storage, outdated, func(data string) (string, error) {
+		plaintext, err := keyring.open(data)
+		if err != nil {
+			return "", err
+		}
+		return keyring.seal(plaintext)
+	}, opts)
+}
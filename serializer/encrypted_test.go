@@ -0,0 +1,209 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/memory"
+	. "github.com/ArnaudCalmettes/store/test"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+func newTestKeyring(t *testing.T, ids ...string) *Keyring {
+	t.Helper()
+	k := NewKeyring()
+	for _, id := range ids {
+		Require(t, NoError(k.Add(id, bytes.Repeat([]byte(id[:1]), 32))))
+	}
+	return k
+}
+
+func TestKeyring(t *testing.T) {
+	t.Run("invalid key", func(t *testing.T) {
+		k := NewKeyring()
+		Expect(t,
+			IsNotZero(k.Add("one", []byte("too short"))),
+			IsError(errInvalidKeyID, k.Add("", make([]byte, 32))),
+			IsError(errInvalidKeyID, k.Add(strings.Repeat("x", 256), make([]byte, 32))),
+			Equal("", k.Current()),
+		)
+	})
+	t.Run("current", func(t *testing.T) {
+		k := newTestKeyring(t, "one", "two")
+		Expect(t, Equal("two", k.Current()))
+	})
+	t.Run("set current", func(t *testing.T) {
+		k := newTestKeyring(t, "two", "one")
+		Expect(t,
+			NoError(k.SetCurrent("two")),
+			Equal("two", k.Current()),
+			IsError(ErrUnknownKey, k.SetCurrent("three")),
+			Equal("two", k.Current()),
+		)
+	})
+}
+
+func TestEncrypted(t *testing.T) {
+	input := Entry{Int: 42, String: "secret"}
+
+	t.Run("nominal", func(t *testing.T) {
+		s := Encrypted(NewJSON[Entry](), newTestKeyring(t, "one"))
+		data, err := s.Serialize(&input)
+		Require(t, NoError(err))
+		id, ok := encryptionKeyID(data)
+		Expect(t,
+			Equal(true, ok),
+			Equal("one", id),
+			Equalf(false, strings.Contains(data, "secret"), "value wasn't encrypted"),
+		)
+
+		result, err := s.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(&input, result),
+		)
+	})
+	t.Run("nil", func(t *testing.T) {
+		s := Encrypted(NewJSON[Entry](), newTestKeyring(t, "one"))
+		_, err := s.Serialize(nil)
+		Expect(t, IsError(ErrNilObject, err))
+	})
+	t.Run("empty", func(t *testing.T) {
+		s := Encrypted(NewJSON[Entry](), newTestKeyring(t, "one"))
+		_, err := s.Deserialize("")
+		Expect(t, IsError(ErrEmptyData, err))
+	})
+	t.Run("empty keyring", func(t *testing.T) {
+		s := Encrypted(NewJSON[Entry](), NewKeyring())
+		_, err := s.Serialize(&input)
+		Expect(t, IsError(errEmptyKeyring, err))
+	})
+	t.Run("rotation", func(t *testing.T) {
+		keyring := newTestKeyring(t, "one")
+		s := Encrypted(NewJSON[Entry](), keyring)
+		old, err := s.Serialize(&input)
+		Require(t, NoError(err))
+
+		Require(t, NoError(keyring.Add("two", bytes.Repeat([]byte{2}, 16))))
+		data, err := s.Serialize(&input)
+		Require(t, NoError(err))
+		id, _ := encryptionKeyID(data)
+		Expect(t, Equal("two", id))
+
+		for _, data := range []string{old, data} {
+			result, err := s.Deserialize(data)
+			Expect(t,
+				NoError(err),
+				Equal(&input, result),
+			)
+		}
+	})
+	t.Run("unknown key", func(t *testing.T) {
+		data, err := Encrypted(NewJSON[Entry](), newTestKeyring(t, "one")).Serialize(&input)
+		Require(t, NoError(err))
+
+		_, err = Encrypted(NewJSON[Entry](), newTestKeyring(t, "two")).Deserialize(data)
+		Expect(t, IsError(ErrUnknownKey, err))
+	})
+	t.Run("tampered", func(t *testing.T) {
+		s := Encrypted(NewJSON[Entry](), newTestKeyring(t, "one"))
+		data, err := s.Serialize(&input)
+		Require(t, NoError(err))
+
+		raw := []byte(data)
+		raw[len(raw)-1] ^= 1
+		_, err = s.Deserialize(string(raw))
+		Expect(t, IsNotZero(err))
+
+		_, err = s.Deserialize(data[:10])
+		Expect(t, IsError(errMalformedData, err))
+	})
+}
+
+func TestReencrypt(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	keyring := newTestKeyring(t, "one")
+	m := memory.NewKeyValueMap()
+	s := NewKeyValueStore(Encrypted(NewJSON[Entry](), keyring), m)
+	entries := map[string]*Entry{
+		"one":   {Int: 1},
+		"two":   {Int: 2},
+		"three": {Int: 3},
+	}
+	Require(t, NoError(s.SetMany(ctx, entries)))
+
+	Require(t, NoError(keyring.Add("two", bytes.Repeat([]byte{2}, 32))))
+	Require(t, NoError(s.SetOne(ctx, "three", entries["three"])))
+
+	// Values that can't be decrypted don't prevent the others from being
+	// re-encrypted.
+	legacy, err := NewJSON[Entry]().Serialize(&Entry{String: strings.Repeat("legacy", 100)})
+	Require(t, NoError(err))
+	unknown, err := Encrypted(NewJSON[Entry](), newTestKeyring(t, "zero")).Serialize(&Entry{Int: 5})
+	Require(t, NoError(err))
+	Require(t, NoError(m.SetMany(ctx, map[string]string{
+		"legacy":  legacy,
+		"unknown": unknown,
+	})))
+
+	count, err := Reencrypt(ctx, m, keyring, &RewriteOptions{BatchSize: 1})
+	Expect(t,
+		IsBatchError(map[string]error{
+			"legacy":  ErrUnknownKey,
+			"unknown": ErrUnknownKey,
+		}, err),
+		Equal(2, count),
+	)
+	Require(t, NoError(m.Delete(ctx, "legacy", "unknown")))
+
+	all, err := m.GetAll(ctx)
+	Require(t, NoError(err))
+	for key, data := range all {
+		id, _ := encryptionKeyID(data)
+		Expect(t, Equalf("two", id, "value %q wasn't re-encrypted", key))
+	}
+	result, err := s.GetAll(ctx)
+	Expect(t,
+		NoError(err),
+		Equal(entries, result),
+	)
+
+	count, err = Reencrypt(ctx, m, keyring, nil)
+	Expect(t,
+		NoError(err),
+		Equal(0, count),
+	)
+}
+
+func TestEncryptedKeyValueStore(t *testing.T) {
+	newStore := func(t *testing.T) BaseKeyValueStore[Entry] {
+		return NewKeyValueStore(
+			Encrypted(NewJSON[Entry](), newTestKeyring(t, "one")),
+			memory.NewKeyValueMap(),
+		)
+	}
+	TestBaseKeyValueStore(t, newStore)
+}
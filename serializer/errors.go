@@ -22,6 +22,9 @@ package serializer
 import "errors"
 
 var (
-	ErrNilObject = errors.New("cannot serialze nil object")
-	ErrEmptyData = errors.New("cannot deserialize empty data")
+	ErrNilObject      = errors.New("cannot serialze nil object")
+	ErrEmptyData      = errors.New("cannot deserialize empty data")
+	ErrUnknownKey     = errors.New("unknown encryption key")
+	ErrUnknownVersion = errors.New("unknown version")
+	ErrCorrupt        = errors.New("corrupt data")
 )
@@ -0,0 +1,384 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/ArnaudCalmettes/store"
+)
+
+// NewGob returns a serializer using encoding/gob.
+//
+// Encoders and decoders are primed with the type descriptors of T once, and
+// reused afterwards, so that serialized values only hold the value itself.
+// Values that need more type descriptors (e.g. interface fields holding user
+// types) are serialized as self-contained gob streams instead.
+//
+// Without type descriptors, gob can't match the fields of a value by name if
+// T changes, so values carry a fingerprint of the layout of T, and fail to
+// deserialize when it doesn't match. To keep reading values written before a
+// change of T, pass zero values of types declared like T used to be as
+// previous: such values are then decoded as gob streams would be, and
+// reported as needing a rewrite (see Rewriter).
+//
+// Compact values are cut out of gob streams, so they rely on the wire format
+// of encoding/gob staying the same across Go versions, as its documentation
+// promises. The golden files in testdata hold values written by this
+// serializer, and make sure they can still be read.
+func NewGob[T any](previous ...any) store.Serializer[T] {
+	g := &gobSerializer[T]{
+		fingerprint: gobFingerprint(reflect.TypeFor[T]()),
+		layouts:     make(map[uint32]*gobLayout, 1+len(previous)),
+	}
+	var zero T
+	current := newGobLayout[T](&zero)
+	g.primeErr = current.primeErr
+	g.layouts[g.fingerprint] = current
+	for _, layout := range previous {
+		fingerprint := gobFingerprint(reflect.TypeOf(layout))
+		if _, ok := g.layouts[fingerprint]; !ok {
+			g.layouts[fingerprint] = newGobLayout[T](reflect.New(reflect.TypeOf(layout)).Interface())
+		}
+	}
+	g.encoders.New = func() any {
+		buf := &bytes.Buffer{}
+		enc := gob.NewEncoder(buf)
+		var zero T
+		_ = enc.Encode(&zero)
+		return &gobEncoder{buf: buf, enc: enc}
+	}
+	return g
+}
+
+const (
+	gobCompact byte = iota
+	gobStream
+)
+
+// gobHeaderSize is the size of the format byte and the fingerprint that
+// precede compact values.
+const gobHeaderSize = 5
+
+var (
+	errGobMalformed = errors.New("malformed gob data")
+	errGobFormat    = errors.New("unknown gob data format")
+	errGobLayout    = errors.New("gob data written with an unknown layout")
+)
+
+type gobSerializer[T any] struct {
+	fingerprint uint32
+	layouts     map[uint32]*gobLayout
+	primeErr    error
+	encoders    sync.Pool
+}
+
+// gobLayout decodes the compact values written with a given layout into T.
+type gobLayout struct {
+	prelude  []byte
+	typeID   int64
+	primeErr error
+	decoders sync.Pool
+}
+
+// newGobLayout returns the layout of zero, which points to a zero value of the
+// type values were written with.
+func newGobLayout[T any](zero any) *gobLayout {
+	l := &gobLayout{}
+	l.prelude, l.typeID, l.primeErr = primeGob(zero)
+	if l.primeErr == nil {
+		// Make sure that T can be decoded from this layout, now rather than
+		// on every value.
+		var value T
+		l.primeErr = gob.NewDecoder(bytes.NewReader(l.prelude)).Decode(&value)
+	}
+	l.decoders.New = func() any {
+		buf := bytes.NewBuffer(bytes.Clone(l.prelude))
+		dec := gob.NewDecoder(buf)
+		var zero T
+		_ = dec.Decode(&zero)
+		return &gobDecoder{buf: buf, dec: dec}
+	}
+	return l
+}
+
+type gobEncoder struct {
+	buf *bytes.Buffer
+	enc *gob.Encoder
+}
+
+type gobDecoder struct {
+	buf *bytes.Buffer
+	dec *gob.Decoder
+}
+
+func (g *gobSerializer[T]) Serialize(obj *T) (string, error) {
+	if obj == nil {
+		return "", ErrNilObject
+	}
+	if g.primeErr != nil {
+		return g.serializeStream(obj)
+	}
+	e := g.encoders.Get().(*gobEncoder)
+	e.buf.Reset()
+	if err := e.enc.Encode(obj); err != nil {
+		return "", err
+	}
+	payload, ok := gobValuePayload(e.buf.Bytes())
+	if !ok {
+		// The encoder has sent new type descriptors, which makes its state
+		// diverge from the decoders': it can't be reused.
+		return g.serializeStream(obj)
+	}
+	data := make([]byte, gobHeaderSize, gobHeaderSize+len(payload))
+	data[0] = gobCompact
+	binary.BigEndian.PutUint32(data[1:], g.fingerprint)
+	data = append(data, payload...)
+	g.encoders.Put(e)
+	return string(data), nil
+}
+
+func (g *gobSerializer[T]) serializeStream(obj *T) (string, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(gobStream)
+	if err := gob.NewEncoder(buf).Encode(obj); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (g *gobSerializer[T]) Deserialize(data string) (*T, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyData
+	}
+	var obj T
+	switch data[0] {
+	case gobStream:
+		err := gob.NewDecoder(bytes.NewBufferString(data[1:])).Decode(&obj)
+		return &obj, err
+	case gobCompact:
+		if len(data) < gobHeaderSize {
+			return nil, errGobMalformed
+		}
+	default:
+		return nil, errGobFormat
+	}
+	layout, ok := g.layouts[gobDataFingerprint(data)]
+	if !ok {
+		return nil, errGobLayout
+	}
+	if layout.primeErr != nil {
+		return nil, layout.primeErr
+	}
+
+	// Rebuild the message the primed decoder expects, using the id that the
+	// type of the layout was given in this process.
+	body := appendGobInt(nil, layout.typeID)
+	body = append(body, data[gobHeaderSize:]...)
+	d := layout.decoders.Get().(*gobDecoder)
+	d.buf.Reset()
+	d.buf.Write(appendGobUint(nil, uint64(len(body))))
+	d.buf.Write(body)
+	if err := d.dec.Decode(&obj); err != nil {
+		return &obj, err
+	}
+	layout.decoders.Put(d)
+	return &obj, nil
+}
+
+// NeedsRewrite reports whether data was written with a previous layout of T.
+func (g *gobSerializer[T]) NeedsRewrite(data string) bool {
+	return len(data) >= gobHeaderSize && data[0] == gobCompact &&
+		gobDataFingerprint(data) != g.fingerprint
+}
+
+func gobDataFingerprint(data string) uint32 {
+	return uint32(data[1])<<24 | uint32(data[2])<<16 | uint32(data[3])<<8 | uint32(data[4])
+}
+
+// primeGob encodes zero and returns the resulting stream, holding the type
+// descriptors of its type, along with the id under which it was sent.
+func primeGob(zero any) ([]byte, int64, error) {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(zero); err != nil {
+		return nil, 0, err
+	}
+	var typeID int64
+	for b := buf.Bytes(); len(b) > 0; {
+		msg, rest, ok := nextGobMessage(b)
+		if !ok {
+			return nil, 0, errGobMalformed
+		}
+		id, _, ok := readGobInt(msg)
+		if !ok {
+			return nil, 0, errGobMalformed
+		}
+		typeID, b = id, rest
+	}
+	return buf.Bytes(), typeID, nil
+}
+
+// gobValuePayload returns the content of the value message in stream, without
+// its type id. It fails if stream holds anything but a single value message.
+func gobValuePayload(stream []byte) ([]byte, bool) {
+	msg, rest, ok := nextGobMessage(stream)
+	if !ok || len(rest) != 0 {
+		return nil, false
+	}
+	id, n, ok := readGobInt(msg)
+	if !ok || id < 0 {
+		return nil, false
+	}
+	return msg[n:], true
+}
+
+func nextGobMessage(b []byte) (msg, rest []byte, ok bool) {
+	count, n, ok := readGobUint(b)
+	if !ok || uint64(len(b)-n) < count {
+		return nil, nil, false
+	}
+	return b[n : n+int(count)], b[n+int(count):], true
+}
+
+// gobFingerprint hashes the layout of t as gob sees it: the names and order
+// of the exported fields of structs, and the kinds of values, regardless of
+// their size. Gob type ids aren't used, as they differ between processes.
+func gobFingerprint(t reflect.Type) uint32 {
+	h := fnv.New32a()
+	writeGobLayout(h, t, map[reflect.Type]bool{})
+	return h.Sum32()
+}
+
+var (
+	gobEncoderType      = reflect.TypeFor[gob.GobEncoder]()
+	binaryMarshalerType = reflect.TypeFor[encoding.BinaryMarshaler]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+func writeGobLayout(w io.Writer, t reflect.Type, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for _, m := range []reflect.Type{gobEncoderType, binaryMarshalerType, textMarshalerType} {
+		if t.Implements(m) || reflect.PointerTo(t).Implements(m) {
+			fmt.Fprintf(w, "%s(%s)", m.Name(), t)
+			return
+		}
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		io.WriteString(w, "int")
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		io.WriteString(w, "uint")
+	case reflect.Float32, reflect.Float64:
+		io.WriteString(w, "float")
+	case reflect.Complex64, reflect.Complex128:
+		io.WriteString(w, "complex")
+	case reflect.Array:
+		fmt.Fprintf(w, "[%d]", t.Len())
+		writeGobLayout(w, t.Elem(), seen)
+	case reflect.Slice:
+		io.WriteString(w, "[]")
+		writeGobLayout(w, t.Elem(), seen)
+	case reflect.Map:
+		io.WriteString(w, "map[")
+		writeGobLayout(w, t.Key(), seen)
+		io.WriteString(w, "]")
+		writeGobLayout(w, t.Elem(), seen)
+	case reflect.Struct:
+		if seen[t] {
+			fmt.Fprintf(w, "%s", t)
+			return
+		}
+		seen[t] = true
+		io.WriteString(w, "struct{")
+		for i := range t.NumField() {
+			field := t.Field(i)
+			kind := field.Type.Kind()
+			if !field.IsExported() || kind == reflect.Chan || kind == reflect.Func {
+				continue
+			}
+			fmt.Fprintf(w, "%s ", field.Name)
+			writeGobLayout(w, field.Type, seen)
+			io.WriteString(w, ";")
+		}
+		io.WriteString(w, "}")
+	default:
+		io.WriteString(w, t.Kind().String())
+	}
+}
+
+// The following helpers implement gob's variable-length integer encoding.
+
+func readGobUint(b []byte) (uint64, int, bool) {
+	if len(b) == 0 {
+		return 0, 0, false
+	}
+	if b[0] <= 0x7f {
+		return uint64(b[0]), 1, true
+	}
+	n := -int(int8(b[0]))
+	if n > 8 || len(b) < n+1 {
+		return 0, 0, false
+	}
+	var x uint64
+	for _, c := range b[1 : n+1] {
+		x = x<<8 | uint64(c)
+	}
+	return x, n + 1, true
+}
+
+func readGobInt(b []byte) (int64, int, bool) {
+	u, n, ok := readGobUint(b)
+	if u&1 != 0 {
+		return ^int64(u >> 1), n, ok
+	}
+	return int64(u >> 1), n, ok
+}
+
+func appendGobUint(b []byte, x uint64) []byte {
+	if x <= 0x7f {
+		return append(b, byte(x))
+	}
+	var tmp [8]byte
+	n := 8
+	for ; x > 0; x >>= 8 {
+		n--
+		tmp[n] = byte(x)
+	}
+	b = append(b, byte(-(8 - n)))
+	return append(b, tmp[n:]...)
+}
+
+func appendGobInt(b []byte, i int64) []byte {
+	if i < 0 {
+		return appendGobUint(b, uint64(^i)<<1|1)
+	}
+	return appendGobUint(b, uint64(i)<<1)
+}
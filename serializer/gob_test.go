@@ -0,0 +1,311 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"bytes"
+	"encoding/gob"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/memory"
+	. "github.com/ArnaudCalmettes/store/test"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+type gobWithInterface struct {
+	Name  string
+	Value any
+}
+
+type gobPayload struct {
+	Data string
+}
+
+func init() {
+	gob.Register(gobPayload{})
+}
+
+func TestSerializeGob(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		s := NewGob[any]()
+		data, err := s.Serialize(nil)
+		Expect(t,
+			IsError(ErrNilObject, err),
+			IsZero(data),
+		)
+	})
+	t.Run("int", func(t *testing.T) {
+		s := NewGob[int]()
+		input := 42
+		data, err := s.Serialize(&input)
+		Expect(t,
+			NoError(err),
+			IsNotZero(data),
+		)
+
+		result, err := s.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(&input, result),
+		)
+	})
+	t.Run("no type descriptors", func(t *testing.T) {
+		s := NewGob[Person]()
+		input := Person{ID: "1", Name: "Alice", Age: 30}
+		data, err := s.Serialize(&input)
+		Require(t, NoError(err))
+
+		var stream bytes.Buffer
+		err = gob.NewEncoder(&stream).Encode(&input)
+		Require(t, NoError(err))
+
+		Expect(t,
+			Equal(gobCompact, data[0]),
+			Equalf(true, len(data) < stream.Len(),
+				"serialized value (%d bytes) should be smaller than a gob stream (%d bytes)",
+				len(data), stream.Len(),
+			),
+		)
+	})
+	t.Run("interface field", func(t *testing.T) {
+		s := NewGob[gobWithInterface]()
+		input := gobWithInterface{Name: "one", Value: gobPayload{Data: "test"}}
+		data, err := s.Serialize(&input)
+		Expect(t,
+			NoError(err),
+			Equal(gobStream, data[0]),
+		)
+
+		result, err := s.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(&input, result),
+		)
+
+		// The encoder that sent extra type descriptors must not be reused.
+		other := gobWithInterface{Name: "two"}
+		data, err = s.Serialize(&other)
+		Expect(t,
+			NoError(err),
+			Equal(gobCompact, data[0]),
+		)
+		result, err = s.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(&other, result),
+		)
+	})
+}
+
+func TestDeserializeGob(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		s := NewGob[any]()
+		result, err := s.Deserialize("")
+		Expect(t,
+			IsError(ErrEmptyData, err),
+			IsNilPointer(result),
+		)
+	})
+	t.Run("int", func(t *testing.T) {
+		s := NewGob[int]()
+		input := 42
+		var stream bytes.Buffer
+		stream.WriteByte(gobStream)
+		err := gob.NewEncoder(&stream).Encode(input)
+		Require(t, NoError(err))
+
+		result, err := s.Deserialize(stream.String())
+		Expect(t,
+			NoError(err),
+			Equal(&input, result),
+		)
+	})
+	t.Run("many", func(t *testing.T) {
+		s := NewGob[Person]()
+		inputs := []Person{
+			{ID: "1", Name: "Alice", Age: 30},
+			{ID: "2", Name: "Bob", Age: 25, Referent: PointerTo("Alice")},
+			{ID: "3"},
+		}
+		data := make([]string, len(inputs))
+		for i := range inputs {
+			var err error
+			data[i], err = s.Serialize(&inputs[i])
+			Require(t, NoError(err))
+		}
+
+		// Decode with another serializer, in reverse order.
+		other := NewGob[Person]()
+		for i := len(data) - 1; i >= 0; i-- {
+			result, err := other.Deserialize(data[i])
+			Expect(t,
+				NoError(err),
+				Equal(&inputs[i], result),
+			)
+		}
+	})
+	t.Run("corrupt", func(t *testing.T) {
+		s := NewGob[Person]()
+		input := Person{ID: "1", Name: "Alice"}
+		data, err := s.Serialize(&input)
+		Require(t, NoError(err))
+
+		_, err = s.Deserialize(data[:len(data)-2])
+		Expect(t, IsNotZero(err))
+
+		// The decoder is still usable after an error.
+		result, err := s.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(&input, result),
+		)
+	})
+	t.Run("unknown format", func(t *testing.T) {
+		s := NewGob[int]()
+		_, err := s.Deserialize("\xff")
+		Expect(t, IsError(errGobFormat, err))
+	})
+}
+
+// gobPersonV1 and gobPersonV2 are two versions of the same type.
+type gobPersonV1 struct {
+	Name string
+	Age  int
+}
+
+type gobPersonV2 struct {
+	Email string
+	Age   int64
+	Name  string
+}
+
+func TestGobLayouts(t *testing.T) {
+	input := gobPersonV1{Name: "Alice", Age: 30}
+	data, err := NewGob[gobPersonV1]().Serialize(&input)
+	Require(t,
+		NoError(err),
+		Equal(gobCompact, data[0]),
+	)
+
+	t.Run("unknown layout", func(t *testing.T) {
+		_, err := NewGob[gobPersonV2]().Deserialize(data)
+		Expect(t,
+			IsError(errGobLayout, err),
+		)
+	})
+	t.Run("previous layout", func(t *testing.T) {
+		s := NewGob[gobPersonV2](gobPersonV1{})
+		result, err := s.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(&gobPersonV2{Name: "Alice", Age: 30}, result),
+			Equalf(true, s.(Rewriter).NeedsRewrite(data),
+				"values written with a previous layout should be rewritten",
+			),
+		)
+
+		data, err := s.Serialize(result)
+		Require(t, NoError(err))
+		Expect(t,
+			Equal(false, s.(Rewriter).NeedsRewrite(data)),
+		)
+	})
+	t.Run("same layout", func(t *testing.T) {
+		type person struct {
+			Name string
+			Age  int32
+		}
+		result, err := NewGob[person]().Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(&person{Name: "Alice", Age: 30}, result),
+		)
+	})
+}
+
+var update = flag.Bool("update", false, "update golden files")
+
+// TestGobGolden checks that values written by previous versions of the
+// serializer, or with previous versions of Go, can still be read.
+func TestGobGolden(t *testing.T) {
+	input := gobPersonV1{Name: "Alice", Age: 30}
+	s := NewGob[gobPersonV1]()
+	compact, err := s.Serialize(&input)
+	Require(t, NoError(err))
+	stream, err := s.(*gobSerializer[gobPersonV1]).serializeStream(&input)
+	Require(t, NoError(err))
+
+	// Streams hold the type ids gob assigned in this process, which depend on
+	// the types encoded before, so only their decoding is checked.
+	testCases := []struct {
+		Name   string
+		Data   string
+		Stable bool
+	}{
+		{Name: "compact", Data: compact, Stable: true},
+		{Name: "stream", Data: stream},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			golden := filepath.Join("testdata", "gob_"+tc.Name+".golden")
+			if *update {
+				Require(t,
+					NoError(os.WriteFile(golden, []byte(tc.Data), 0o644)),
+				)
+			}
+			want, err := os.ReadFile(golden)
+			Require(t, NoError(err))
+			result, err := s.Deserialize(string(want))
+			Expect(t,
+				NoError(err),
+				Equal(&input, result),
+			)
+			if tc.Stable {
+				Expect(t,
+					Equalf(string(want), tc.Data, "gob output has changed"),
+				)
+			}
+		})
+	}
+}
+
+func TestGobKeyValueStore(t *testing.T) {
+	newStore := func(*testing.T) BaseKeyValueStore[Entry] {
+		return NewKeyValueStore(
+			NewGob[Entry](),
+			memory.NewKeyValueMap(),
+		)
+	}
+	TestBaseKeyValueStore(t, newStore)
+}
+
+func TestGobKeyValueStoreLister(t *testing.T) {
+	newStore := func(*testing.T) TestListerInterface[Person] {
+		return NewKeyValueStore(
+			NewGob[Person](),
+			memory.NewKeyValueMap(),
+		)
+	}
+	TestLister(t, newStore)
+}
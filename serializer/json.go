@@ -20,10 +20,11 @@
 package serializer
 
 import (
+	"bytes"
 	"encoding/json"
+	"sync"
 
 	"github.com/ArnaudCalmettes/store"
-	"github.com/ArnaudCalmettes/store/internal/zerocopy"
 )
 
 func NewJSON[T any]() store.Serializer[T] {
@@ -37,7 +38,7 @@ func (j jsonSerializer[T]) Serialize(obj *T) (string, error) {
 		return "", ErrNilObject
 	}
 	data, err := json.Marshal(obj)
-	return zerocopy.BytesToString(data), err
+	return string(data), err
 }
 
 func (j jsonSerializer[T]) Deserialize(data string) (*T, error) {
@@ -45,6 +46,55 @@ func (j jsonSerializer[T]) Deserialize(data string) (*T, error) {
 		return nil, ErrEmptyData
 	}
 	var obj T
-	err := json.Unmarshal(zerocopy.StringToBytes(data), &obj)
+	err := json.Unmarshal([]byte(data), &obj)
+	return &obj, err
+}
+
+// appendWriter appends what is written to it to buf.
+type appendWriter struct {
+	buf []byte
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+type jsonEncoder struct {
+	w   appendWriter
+	enc *json.Encoder
+}
+
+var jsonEncoders = sync.Pool{
+	New: func() any {
+		e := &jsonEncoder{}
+		e.enc = json.NewEncoder(&e.w)
+		return e
+	},
+}
+
+func (j jsonSerializer[T]) SerializeAppend(dst []byte, obj *T) ([]byte, error) {
+	if obj == nil {
+		return dst, ErrNilObject
+	}
+	e := jsonEncoders.Get().(*jsonEncoder)
+	defer func() {
+		e.w.buf = nil
+		jsonEncoders.Put(e)
+	}()
+	e.w.buf = dst
+	if err := e.enc.Encode(obj); err != nil {
+		return dst, err
+	}
+	// Unlike json.Marshal, json.Encoder terminates values with a newline.
+	return bytes.TrimSuffix(e.w.buf, []byte{'\n'}), nil
+}
+
+func (j jsonSerializer[T]) DeserializeBytes(data []byte) (*T, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyData
+	}
+	var obj T
+	err := json.Unmarshal(data, &obj)
 	return &obj, err
 }
@@ -22,30 +22,119 @@ package serializer
 import (
 	"context"
 	"errors"
+	"reflect"
 	"slices"
+	"time"
 
 	//lint:ignore ST1001 shared definitions
 	. "github.com/ArnaudCalmettes/store"
 	"github.com/ArnaudCalmettes/store/internal/inspect"
 	"github.com/ArnaudCalmettes/store/internal/options"
+	"golang.org/x/exp/maps"
 )
 
 type KeyValueStore[T any] interface {
 	BaseKeyValueStore[T]
 	Lister[T]
+	WhereDeleter
+	WhereUpdater[T]
 	Resetter
 	ErrorMapSetter
+	ClockSetter
 }
 
+// NewKeyValueStore is like TryNewKeyValueStore, but panics if T is not a
+// valid model.
 func NewKeyValueStore[T any](serializer Serializer[T], storage Map) KeyValueStore[T] {
+	return must(TryNewKeyValueStore(serializer, storage))
+}
+
+// TryNewKeyValueStore returns a KeyValueStore that serializes its values into
+// storage. If T has invalid automatic fields (see inspect.AutoFields), it
+// returns a *ModelError.
+//
+// Automatic fields are only best-effort in SetOne and SetMany, which read the
+// values they replace before overwriting them, without any locking: a value
+// written concurrently in between doesn't count towards the version, and its
+// creation time may be lost. UpdateOne and UpdateMany stamp them atomically.
+func TryNewKeyValueStore[T any](serializer Serializer[T], storage Map) (KeyValueStore[T], error) {
+	k, err := newKeyValueStore(serializer, storage)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func newKeyValueStore[T any](serializer Serializer[T], storage Map) (*keyValueStore[T], error) {
+	auto, err := inspect.NewAutoFields[T]()
+	if err != nil {
+		return nil, &ModelError{Model: reflect.TypeFor[T]().String(), Problems: []error{err}}
+	}
 	k := &keyValueStore[T]{
 		storage:    storage,
 		Serializer: serializer,
+		auto:       auto,
+		now:        time.Now,
 	}
 	k.InitDefaultErrors()
+	return k, nil
+}
+
+func must[T any](k KeyValueStore[T], err error) KeyValueStore[T] {
+	if err != nil {
+		panic(err)
+	}
 	return k
 }
 
+// Rewriter is implemented by serializers that can tell when serialized data
+// should be rewritten, for instance because it uses an outdated format.
+// Updates rewrite such data even if the update callback leaves it unchanged.
+type Rewriter interface {
+	NeedsRewrite(data string) bool
+}
+
+type TolerantOptions struct {
+	// OnCorrupt, if not nil, is called with the key of every entry that
+	// couldn't be deserialized. If the entry couldn't be quarantined either,
+	// err also holds the reason why.
+	OnCorrupt func(key string, err error)
+
+	// Quarantine, if not nil, is where corrupt entries are moved, for later
+	// inspection. Entries that are overwritten while being moved stay in
+	// place, and their corrupt version is only copied to quarantine. Entries
+	// that fail to be moved stay in place too, without failing the read.
+	Quarantine BaseKeyValueMap
+}
+
+// NewTolerantKeyValueStore returns a KeyValueStore that skips the entries it
+// can't deserialize when reading many entries at once (GetMany, GetAll, List,
+// ...), instead of failing with ErrDeserialize. GetOne still fails on corrupt
+// entries.
+//
+// Stores returned by NewKeyValueStore also return the entries they could
+// deserialize, along with a BatchError holding the keys of corrupt entries,
+// but List fails.
+//
+// It panics if T is not a valid model (see TryNewKeyValueStore).
+func NewTolerantKeyValueStore[T any](serializer Serializer[T], storage Map, opts *TolerantOptions) KeyValueStore[T] {
+	return must(TryNewTolerantKeyValueStore(serializer, storage, opts))
+}
+
+// TryNewTolerantKeyValueStore is like NewTolerantKeyValueStore, but returns a
+// *ModelError if T is not a valid model.
+func TryNewTolerantKeyValueStore[T any](serializer Serializer[T], storage Map, opts *TolerantOptions) (KeyValueStore[T], error) {
+	if opts == nil {
+		opts = &TolerantOptions{}
+	}
+	k, err := newKeyValueStore(serializer, storage)
+	if err != nil {
+		return nil, err
+	}
+	k.tolerant = opts
+	return k, nil
+}
+
 type Map interface {
 	BaseKeyValueMap
 	ErrorMapSetter
@@ -56,6 +145,13 @@ type keyValueStore[T any] struct {
 	storage Map
 	Serializer[T]
 	ErrorMap
+	tolerant *TolerantOptions
+	auto     *inspect.AutoFields[T]
+	now      func() time.Time
+}
+
+func (k *keyValueStore[T]) SetClock(now func() time.Time) {
+	k.now = now
 }
 
 func (k *keyValueStore[T]) List(ctx context.Context, opts ...*Options) ([]*T, error) {
@@ -146,10 +242,11 @@ func (k *keyValueStore[T]) GetOne(ctx context.Context, key string) (*T, error) {
 
 func (k *keyValueStore[T]) GetMany(ctx context.Context, keys []string) (map[string]*T, error) {
 	serializedItems, err := k.storage.GetMany(ctx, keys)
-	if err != nil {
+	batch, ok := AsBatchError(err)
+	if !ok {
 		return nil, err
 	}
-	return k.deserializeMap(serializedItems)
+	return k.deserializeMap(ctx, serializedItems, batch)
 }
 
 func (k *keyValueStore[T]) GetAll(ctx context.Context) (map[string]*T, error) {
@@ -157,10 +254,17 @@ func (k *keyValueStore[T]) GetAll(ctx context.Context) (map[string]*T, error) {
 	if err != nil {
 		return nil, err
 	}
-	return k.deserializeMap(all)
+	return k.deserializeMap(ctx, all, &BatchError{})
 }
 
 func (k *keyValueStore[T]) SetOne(ctx context.Context, key string, value *T) error {
+	if k.auto != nil && value != nil {
+		prev, err := k.previous(ctx, []string{key})
+		if err != nil {
+			return err
+		}
+		value = k.touch(value, prev[key])
+	}
 	data, err := k.Serialize(value)
 	if err != nil {
 		return errors.Join(k.ErrSerialize, err)
@@ -169,11 +273,62 @@ func (k *keyValueStore[T]) SetOne(ctx context.Context, key string, value *T) err
 }
 
 func (k *keyValueStore[T]) SetMany(ctx context.Context, items map[string]*T) error {
-	serializedItems, err := k.serializeMap(items)
-	if err != nil {
+	if k.auto != nil {
+		prev, err := k.previous(ctx, maps.Keys(items))
+		if err != nil {
+			return err
+		}
+		items = k.touchMany(items, prev)
+	}
+	serializedItems, batch := k.serializeMap(items)
+	err := k.storage.SetMany(ctx, serializedItems)
+	storageBatch, ok := AsBatchError(err)
+	if !ok {
 		return err
 	}
-	return k.storage.SetMany(ctx, serializedItems)
+	for key, err := range storageBatch.Errors {
+		batch.Add(key, err)
+	}
+	return batch.ErrOrNil()
+}
+
+// previous returns the current values of keys, which the automatic fields of
+// the values replacing them depend on. Missing and corrupt values are left
+// out, so that setting a value stays a blind write that overwrites them. The
+// values aren't locked, so they may change before they are overwritten.
+func (k *keyValueStore[T]) previous(ctx context.Context, keys []string) (map[string]*T, error) {
+	all, err := k.storage.GetMany(ctx, keys)
+	if _, ok := AsBatchError(err); !ok {
+		return nil, err
+	}
+	prev := make(map[string]*T, len(all))
+	for key, data := range all {
+		if value, err := k.Deserialize(data); err == nil {
+			prev[key] = value
+		}
+	}
+	return prev, nil
+}
+
+// touch returns a copy of value with its automatic fields updated, prev being
+// the value it replaces, if any.
+func (k *keyValueStore[T]) touch(value, prev *T) *T {
+	item := *value
+	k.auto.Touch(&item, prev, k.now())
+	return &item
+}
+
+// touchMany is like touch for many values. Nil values are kept as they are,
+// and fail to serialize.
+func (k *keyValueStore[T]) touchMany(items, prev map[string]*T) map[string]*T {
+	touched := make(map[string]*T, len(items))
+	for key, value := range items {
+		if value != nil {
+			value = k.touch(value, prev[key])
+		}
+		touched[key] = value
+	}
+	return touched
 }
 
 func (k *keyValueStore[T]) UpdateOne(ctx context.Context, key string, update func(string, *T) (*T, error)) error {
@@ -188,49 +343,180 @@ func (k *keyValueStore[T]) Delete(ctx context.Context, keys ...string) error {
 	return k.storage.Delete(ctx, keys...)
 }
 
+func (k *keyValueStore[T]) DeleteWhere(ctx context.Context, filter *FilterSpec) (int, error) {
+	predicate, err := inspect.NewPredicate[T](filter)
+	if err != nil {
+		return 0, errors.Join(k.ErrInvalidFilter, err)
+	}
+	// Entries that can't be deserialized are skipped, and reported once the
+	// others have been handled.
+	all, err := k.GetAll(ctx)
+	corrupt, ok := AsBatchError(err)
+	if !ok {
+		return 0, err
+	}
+	keys := make([]string, 0, len(all))
+	for key, item := range all {
+		if predicate(item) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return 0, corrupt.ErrOrNil()
+	}
+	// Entries may have changed since they were read, so the filter is checked
+	// again on their current value.
+	count, err := deleteIf(ctx, k.storage, keys, func(_ string, data string) bool {
+		item, err := k.Deserialize(data)
+		return err == nil && predicate(item)
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, corrupt.ErrOrNil()
+}
+
+func (k *keyValueStore[T]) UpdateWhere(ctx context.Context, filter *FilterSpec, update func(string, *T) (*T, error)) (int, error) {
+	predicate, err := inspect.NewPredicate[T](filter)
+	if err != nil {
+		return 0, errors.Join(k.ErrInvalidFilter, err)
+	}
+	// Only the entries that match the filter when they are read go through
+	// UpdateMany, where the filter is checked again on their current value,
+	// so that entries that stop matching in the meantime are left unchanged.
+	// Entries that can't be deserialized are skipped, and reported once the
+	// others have been handled.
+	all, err := k.GetAll(ctx)
+	corrupt, ok := AsBatchError(err)
+	if !ok {
+		return 0, err
+	}
+	keys := make([]string, 0, len(all))
+	for key, item := range all {
+		if predicate(item) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return 0, corrupt.ErrOrNil()
+	}
+	// Not a counter, since the callback may be called more than once for the
+	// same key (see BaseKeyValueMap.UpdateMany).
+	updated := make(map[string]bool, len(keys))
+	err = k.UpdateMany(ctx, keys, func(key string, item *T) (*T, error) {
+		if item == nil || !predicate(item) {
+			updated[key] = false
+			return nil, nil
+		}
+		newItem, err := update(key, item)
+		updated[key] = newItem != nil
+		return newItem, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, ok := range updated {
+		if ok {
+			count++
+		}
+	}
+	return count, corrupt.ErrOrNil()
+}
+
 func (k *keyValueStore[T]) Reset(ctx context.Context) error {
 	return k.storage.Reset(ctx)
 }
 
-func (k *keyValueStore[T]) serializeMap(in map[string]*T) (map[string]string, error) {
+func (k *keyValueStore[T]) serializeMap(in map[string]*T) (map[string]string, *BatchError) {
 	out := make(map[string]string, len(in))
+	batch := &BatchError{}
 	for key, value := range in {
 		data, err := k.Serialize(value)
 		if err != nil {
-			return nil, errors.Join(k.ErrSerialize, err)
+			batch.Add(key, errors.Join(k.ErrSerialize, err))
+			continue
 		}
 		out[key] = data
 	}
-	return out, nil
+	return out, batch
 }
 
-func (k *keyValueStore[T]) deserializeMap(in map[string]string) (map[string]*T, error) {
+func (k *keyValueStore[T]) deserializeMap(ctx context.Context, in map[string]string, batch *BatchError) (map[string]*T, error) {
 	out := make(map[string]*T, len(in))
 	for key, data := range in {
 		value, err := k.Deserialize(data)
 		if err != nil {
-			return nil, errors.Join(k.ErrDeserialize, err)
+			k.corrupt(ctx, key, data, errors.Join(k.ErrDeserialize, err), batch)
+			continue
 		}
 		out[key] = value
 	}
-	return out, nil
+	return out, batch.ErrOrNil()
+}
+
+// corrupt handles an entry that couldn't be deserialized, either by adding it
+// to the batch, or by reporting and quarantining it in tolerant mode.
+func (k *keyValueStore[T]) corrupt(ctx context.Context, key, data string, err error, batch *BatchError) {
+	if k.tolerant == nil {
+		batch.Add(key, err)
+		return
+	}
+	if k.tolerant.Quarantine != nil {
+		if qerr := k.quarantine(ctx, key, data); qerr != nil {
+			err = errors.Join(err, qerr)
+		}
+	}
+	if k.tolerant.OnCorrupt != nil {
+		k.tolerant.OnCorrupt(key, err)
+	}
+}
+
+// quarantine copies a corrupt entry to quarantine, then removes it from the
+// storage unless it has changed since it was read.
+func (k *keyValueStore[T]) quarantine(ctx context.Context, key, data string) error {
+	if err := k.tolerant.Quarantine.SetOne(ctx, key, data); err != nil {
+		return err
+	}
+	_, err := deleteIf(ctx, k.storage, []string{key}, func(_ string, current string) bool {
+		return current == data
+	})
+	return err
 }
 
 func (k *keyValueStore[T]) updateCallback(in func(string, *T) (*T, error)) func(string, *string) (*string, error) {
 	return func(id string, data *string) (*string, error) {
 		var value *T
+		var rewritten *string
 		var err error
 		if data != nil {
 			value, err = k.Deserialize(*data)
 			if err != nil {
 				return nil, errors.Join(k.ErrDeserialize, err)
 			}
+			// Serialize before calling the callback, which may modify value.
+			if r, ok := k.Serializer.(Rewriter); ok && r.NeedsRewrite(*data) {
+				newData, err := k.Serialize(value)
+				if err != nil {
+					return nil, errors.Join(k.ErrSerialize, err)
+				}
+				rewritten = &newData
+			}
 		}
 		newValue, err := in(id, value)
-		if err != nil || newValue == nil {
+		if err != nil {
 			return nil, err
 		}
-		newData, _ := k.Serialize(newValue)
-		return &newData, err
+		if newValue == nil {
+			return rewritten, nil
+		}
+		if k.auto != nil {
+			newValue = k.touch(newValue, value)
+		}
+		newData, err := k.Serialize(newValue)
+		if err != nil {
+			return nil, errors.Join(k.ErrSerialize, err)
+		}
+		return &newData, nil
 	}
 }
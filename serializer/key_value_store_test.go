@@ -23,6 +23,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	. "github.com/ArnaudCalmettes/store"
 	"github.com/ArnaudCalmettes/store/memory"
@@ -30,6 +31,11 @@ import (
 	. "github.com/ArnaudCalmettes/store/test/helpers"
 )
 
+// newJSONStore returns a store of JSON values backed by a new memory map.
+func newJSONStore[T any]() KeyValueStore[T] {
+	return NewKeyValueStore(NewJSON[T](), memory.NewKeyValueMap())
+}
+
 func TestSerializerKeyValueStore(t *testing.T) {
 	newStore := func(*testing.T) BaseKeyValueStore[Entry] {
 		return NewKeyValueStore(
@@ -50,6 +56,108 @@ func TestSerializerKeyValueStoreLister(t *testing.T) {
 	TestLister(t, newStore)
 }
 
+func TestSerializerKeyValueStoreWhereDeleter(t *testing.T) {
+	newStore := func(*testing.T) TestWhereDeleterInterface[Person] {
+		return NewKeyValueStore(
+			NewJSON[Person](),
+			memory.NewKeyValueMap(),
+		)
+	}
+	TestWhereDeleter(t, newStore)
+}
+
+func TestSerializerKeyValueStoreWhereUpdater(t *testing.T) {
+	newStore := func(*testing.T) TestWhereUpdaterInterface[Person] {
+		return NewKeyValueStore(
+			NewJSON[Person](),
+			memory.NewKeyValueMap(),
+		)
+	}
+	TestWhereUpdater(t, newStore)
+}
+
+func TestSerializerKeyValueStoreAutoFields(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		TestAutoFields(t, Constructor(newJSONStore[Document]))
+	})
+	t.Run("binary", func(t *testing.T) {
+		newStore := func(*testing.T) TestAutoFieldsInterface[Document] {
+			return NewBinaryKeyValueStore(ToBinarySerializer(NewMsgpack[Document]()), memory.NewBinaryMap())
+		}
+		TestAutoFields(t, newStore)
+	})
+}
+
+func TestSerializerKeyValueStoreAutoFieldsBlindSet(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := func(title string) *Document {
+		return &Document{Title: title, CreatedAt: now, UpdatedAt: now, Version: 1}
+	}
+	check := func(t *testing.T, store KeyValueStore[Document]) {
+		t.Helper()
+		store.SetClock(func() time.Time { return now })
+		Require(t, NoError(store.SetOne(ctx, "one", &Document{Title: "one"})))
+		Require(t, NoError(store.SetMany(ctx, map[string]*Document{
+			"two":   {Title: "two"},
+			"three": {Title: "three"},
+		})))
+		all, err := store.GetAll(ctx)
+		Expect(t,
+			NoError(err),
+			Equal(map[string]*Document{
+				"one":   want("one"),
+				"two":   want("two"),
+				"three": want("three"),
+			}, all),
+		)
+	}
+
+	// Corrupt values are overwritten as if they were missing, without going
+	// through updates.
+	t.Run("json", func(t *testing.T) {
+		storage := memory.NewKeyValueMap()
+		Require(t, NoError(storage.SetMany(ctx, map[string]string{"one": "{", "two": "{"})))
+		check(t, NewKeyValueStore(NewJSON[Document](), noUpdateMap{storage}))
+	})
+	t.Run("binary", func(t *testing.T) {
+		storage := memory.NewBinaryMap()
+		Require(t, NoError(storage.SetMany(ctx, map[string][]byte{"one": {0xc1}, "two": {0xc1}})))
+		check(t, NewBinaryKeyValueStore(ToBinarySerializer(NewMsgpack[Document]()), storage))
+	})
+}
+
+func TestTryNewKeyValueStore(t *testing.T) {
+	type Model struct {
+		Version string `store:"version"`
+	}
+	_, err := TryNewKeyValueStore(NewJSON[Model](), memory.NewKeyValueMap())
+	var modelErr *ModelError
+	Require(t, Equal(true, errors.As(err, &modelErr)))
+	Expect(t,
+		Equal("serializer.Model", modelErr.Model),
+		SliceHasLength(1, modelErr.Problems),
+		ShouldPanic(func() {
+			NewKeyValueStore(NewJSON[Model](), memory.NewKeyValueMap())
+		}),
+	)
+	_, err = TryNewBinaryKeyValueStore(ToBinarySerializer(NewJSON[Model]()), memory.NewBinaryMap())
+	Expect(t, Equal(true, errors.As(err, &modelErr)))
+}
+
+func TestSerializerKeyValueStoreFullText(t *testing.T) {
+	TestFullText(t, Constructor(newJSONStore[Article]))
+}
+
+func TestSerializerKeyValueStoreKindFilters(t *testing.T) {
+	TestKindFilters(t, Constructor(newJSONStore[Product]))
+}
+
+func TestSerializerKeyValueStoreCollectionFilters(t *testing.T) {
+	TestCollectionFilters(t, Constructor(newJSONStore[Tagged]))
+}
+
 func TestKeyValueStoreCustomErrors(t *testing.T) {
 	errTest := errors.New("test")
 	store := NewKeyValueStore(NewJSON[Entry](), memory.NewKeyValueMap())
@@ -68,11 +176,14 @@ func TestSerializationErrors(t *testing.T) {
 	mem := memory.NewKeyValueMap()
 	store := NewKeyValueStore(NewJSON[Entry](), mem)
 	mem.SetOne(ctx, "malformed", "}")
+	mem.SetOne(ctx, "valid", `{"String": "valid"}`)
 
 	t.Run("GetAll", func(t *testing.T) {
-		_, err := store.GetAll(ctx)
+		all, err := store.GetAll(ctx)
 		Expect(t,
 			IsError(ErrDeserialize, err),
+			IsBatchError(map[string]error{"malformed": ErrDeserialize}, err),
+			Equal(map[string]*Entry{"valid": {String: "valid"}}, all),
 		)
 	})
 	t.Run("GetOne", func(t *testing.T) {
@@ -82,9 +193,14 @@ func TestSerializationErrors(t *testing.T) {
 		)
 	})
 	t.Run("GetMany", func(t *testing.T) {
-		_, err := store.GetMany(ctx, []string{"malformed"})
+		items, err := store.GetMany(ctx, []string{"malformed", "valid", "missing"})
 		Expect(t,
 			IsError(ErrDeserialize, err),
+			IsBatchError(map[string]error{
+				"malformed": ErrDeserialize,
+				"missing":   ErrNotFound,
+			}, err),
+			Equal(map[string]*Entry{"valid": {String: "valid"}}, items),
 		)
 	})
 	t.Run("SetOne", func(t *testing.T) {
@@ -94,9 +210,18 @@ func TestSerializationErrors(t *testing.T) {
 		)
 	})
 	t.Run("SetMany", func(t *testing.T) {
-		err := store.SetMany(ctx, map[string]*Entry{"item": nil})
+		err := store.SetMany(ctx, map[string]*Entry{
+			"item":  nil,
+			"other": {String: "other"},
+		})
 		Expect(t,
 			IsError(ErrSerialize, err),
+			IsBatchError(map[string]error{"item": ErrSerialize}, err),
+		)
+		other, err := store.GetOne(ctx, "other")
+		Expect(t,
+			NoError(err),
+			Equal(&Entry{String: "other"}, other),
 		)
 	})
 	t.Run("UpdateOne", func(t *testing.T) {
@@ -119,6 +244,326 @@ func TestSerializationErrors(t *testing.T) {
 			IsError(ErrDeserialize, err),
 		)
 	})
+	t.Run("UpdateWhere", func(t *testing.T) {
+		count, err := store.UpdateWhere(ctx, Where("String", "=", "other"),
+			func(_ string, e *Entry) (*Entry, error) {
+				e.Int = 1
+				return e, nil
+			},
+		)
+		Expect(t,
+			IsBatchError(map[string]error{"malformed": ErrDeserialize}, err),
+			Equal(1, count),
+		)
+		other, err := store.GetOne(ctx, "other")
+		Expect(t,
+			NoError(err),
+			Equal(&Entry{String: "other", Int: 1}, other),
+		)
+	})
+	t.Run("DeleteWhere", func(t *testing.T) {
+		count, err := store.DeleteWhere(ctx, Where("String", "=", "valid"))
+		Expect(t,
+			IsBatchError(map[string]error{"malformed": ErrDeserialize}, err),
+			Equal(1, count),
+		)
+		_, err = store.GetOne(ctx, "valid")
+		Expect(t,
+			IsError(ErrNotFound, err),
+		)
+	})
+}
+
+func TestTolerantKeyValueStore(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+	mem := memory.NewKeyValueMap()
+	quarantine := memory.NewKeyValueMap()
+	corrupt := map[string]error{}
+	store := NewTolerantKeyValueStore(NewJSON[Entry](), mem, &TolerantOptions{
+		OnCorrupt: func(key string, err error) {
+			corrupt[key] = err
+		},
+	})
+	mem.SetOne(ctx, "malformed", "}")
+	mem.SetOne(ctx, "valid", `{"String": "valid"}`)
+
+	t.Run("GetAll", func(t *testing.T) {
+		clear(corrupt)
+		all, err := store.GetAll(ctx)
+		Expect(t,
+			NoError(err),
+			Equal(map[string]*Entry{"valid": {String: "valid"}}, all),
+			IsError(ErrDeserialize, corrupt["malformed"]),
+		)
+	})
+	t.Run("GetMany", func(t *testing.T) {
+		clear(corrupt)
+		items, err := store.GetMany(ctx, []string{"malformed", "valid", "missing"})
+		Expect(t,
+			IsBatchError(map[string]error{"missing": ErrNotFound}, err),
+			Equal(map[string]*Entry{"valid": {String: "valid"}}, items),
+			IsError(ErrDeserialize, corrupt["malformed"]),
+		)
+	})
+	t.Run("GetOne", func(t *testing.T) {
+		_, err := store.GetOne(ctx, "malformed")
+		Expect(t,
+			IsError(ErrDeserialize, err),
+		)
+	})
+	t.Run("List", func(t *testing.T) {
+		items, err := store.List(ctx)
+		Expect(t,
+			NoError(err),
+			Equal([]*Entry{{String: "valid"}}, items),
+		)
+	})
+	t.Run("Quarantine", func(t *testing.T) {
+		store := NewTolerantKeyValueStore(NewJSON[Entry](), mem, &TolerantOptions{
+			Quarantine: quarantine,
+		})
+		_, err := store.GetAll(ctx)
+		Require(t, NoError(err))
+
+		all, err := mem.GetAll(ctx)
+		Expect(t,
+			NoError(err),
+			Equal(map[string]string{"valid": `{"String": "valid"}`}, all),
+		)
+		all, err = quarantine.GetAll(ctx)
+		Expect(t,
+			NoError(err),
+			Equal(map[string]string{"malformed": "}"}, all),
+		)
+	})
+	t.Run("Quarantine fixed entry", func(t *testing.T) {
+		mem := memory.NewKeyValueMap()
+		quarantine := memory.NewKeyValueMap()
+		mem.SetOne(ctx, "malformed", "}")
+		store := NewTolerantKeyValueStore(NewJSON[Entry](), &racyMap{
+			Map: mem,
+			afterGetAll: func() {
+				mem.SetOne(ctx, "malformed", `{"String": "fixed"}`)
+			},
+		}, &TolerantOptions{
+			Quarantine: quarantine,
+		})
+		_, err := store.GetAll(ctx)
+		Require(t, NoError(err))
+
+		value, err := store.GetOne(ctx, "malformed")
+		Expect(t,
+			NoErrorf(err, "entries fixed in the meantime shouldn't be deleted"),
+			Equal(&Entry{String: "fixed"}, value),
+		)
+		all, err := quarantine.GetAll(ctx)
+		Expect(t,
+			NoError(err),
+			Equal(map[string]string{"malformed": "}"}, all),
+		)
+	})
+	t.Run("Quarantine error", func(t *testing.T) {
+		errTest := errors.New("test")
+		reported := map[string]error{}
+		store := NewTolerantKeyValueStore(NewJSON[Entry](), mem, &TolerantOptions{
+			Quarantine: failingMap{BaseKeyValueMap: quarantine, err: errTest},
+			OnCorrupt: func(key string, err error) {
+				reported[key] = err
+			},
+		})
+		mem.SetOne(ctx, "malformed", "}")
+		all, err := store.GetAll(ctx)
+		Expect(t,
+			NoErrorf(err, "quarantine failures shouldn't fail reads"),
+			Equal(map[string]*Entry{"valid": {String: "valid"}}, all),
+			IsError(errTest, reported["malformed"]),
+			IsError(ErrDeserialize, reported["malformed"]),
+		)
+		list, err := store.List(ctx)
+		Expect(t,
+			NoError(err),
+			SliceHasLength(1, list),
+		)
+		_, err = mem.GetOne(ctx, "malformed")
+		Expect(t,
+			NoErrorf(err, "entries that failed to be quarantined should be kept"),
+		)
+	})
+}
+
+// failingMap fails to set values with err.
+type failingMap struct {
+	BaseKeyValueMap
+	err error
+}
+
+func (f failingMap) SetOne(context.Context, string, string) error {
+	return f.err
+}
+
+// noUpdateMap fails every update with errNoUpdate.
+type noUpdateMap struct {
+	Map
+}
+
+var errNoUpdate = errors.New("no update")
+
+func (noUpdateMap) UpdateOne(context.Context, string, func(string, *string) (*string, error)) error {
+	return errNoUpdate
+}
+
+func (noUpdateMap) UpdateMany(context.Context, []string, func(string, *string) (*string, error)) error {
+	return errNoUpdate
+}
+
+// racyMap runs afterGetAll after the first call to GetAll, to simulate
+// concurrent writes that happen between a read and a write.
+type racyMap struct {
+	Map
+	afterGetAll func()
+}
+
+func (r *racyMap) GetAll(ctx context.Context) (map[string]string, error) {
+	all, err := r.Map.GetAll(ctx)
+	if r.afterGetAll != nil {
+		r.afterGetAll()
+		r.afterGetAll = nil
+	}
+	return all, err
+}
+
+func (r *racyMap) DeleteIf(ctx context.Context, keys []string, cond func(string, string) bool) (int, error) {
+	return r.Map.(ConditionalDeleter).DeleteIf(ctx, keys, cond)
+}
+
+// plainMap hides the optional interfaces implemented by the map it wraps.
+type plainMap struct {
+	Map
+}
+
+func TestKeyValueStoreDeleteWhereRecheck(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+	mem := memory.NewKeyValueMap()
+	store := NewKeyValueStore(NewJSON[Entry](), &racyMap{
+		Map: mem,
+		afterGetAll: func() {
+			mem.SetOne(ctx, "changed", `{"Int": 2}`)
+		},
+	})
+	err := store.SetMany(ctx, map[string]*Entry{
+		"changed":   {Int: 1},
+		"unchanged": {Int: 1},
+	})
+	Require(t, NoError(err))
+
+	count, err := store.DeleteWhere(ctx, Where("Int", "=", 1))
+	Expect(t,
+		NoError(err),
+		Equal(1, count),
+	)
+	value, err := store.GetOne(ctx, "changed")
+	Expect(t,
+		NoErrorf(err, "entries that stop matching the filter shouldn't be deleted"),
+		Equal(&Entry{Int: 2}, value),
+	)
+}
+
+func TestKeyValueStoreDeleteWhereWithoutConditionalDeleter(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+	store := NewKeyValueStore(NewJSON[Entry](), plainMap{memory.NewKeyValueMap()})
+	err := store.SetMany(ctx, map[string]*Entry{
+		"one":   {Int: 1},
+		"two":   {Int: 2},
+		"three": {Int: 1},
+	})
+	Require(t, NoError(err))
+
+	count, err := store.DeleteWhere(ctx, Where("Int", "=", 1))
+	Expect(t,
+		NoError(err),
+		Equal(2, count),
+	)
+	all, err := store.GetAll(ctx)
+	Expect(t,
+		NoError(err),
+		Equal(map[string]*Entry{"two": {Int: 2}}, all),
+	)
+}
+
+func TestKeyValueStoreUpdateWhereRecheck(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+	mem := memory.NewKeyValueMap()
+	store := NewKeyValueStore(NewJSON[Entry](), &racyMap{
+		Map: mem,
+		afterGetAll: func() {
+			mem.SetMany(ctx, map[string]string{
+				"starts": `{"Int": 1}`,
+				"stops":  `{"Int": 2}`,
+			})
+		},
+	})
+	err := store.SetMany(ctx, map[string]*Entry{
+		"starts":    {Int: 2},
+		"stops":     {Int: 1},
+		"unchanged": {Int: 1},
+	})
+	Require(t, NoError(err))
+
+	var seen []string
+	count, err := store.UpdateWhere(ctx, Where("Int", "=", 1), func(key string, entry *Entry) (*Entry, error) {
+		seen = append(seen, key)
+		entry.String = "updated"
+		return entry, nil
+	})
+	Expect(t,
+		NoError(err),
+		Equal(1, count),
+		Equalf([]string{"unchanged"}, seen, "only entries that still match should be updated"),
+	)
+	all, err := store.GetAll(ctx)
+	Expect(t,
+		NoError(err),
+		Equal(map[string]*Entry{
+			"starts":    {Int: 1},
+			"stops":     {Int: 2},
+			"unchanged": {Int: 1, String: "updated"},
+		}, all),
+	)
+}
+
+// watchedMap records the keys given to UpdateMany.
+type watchedMap struct {
+	Map
+	updated []string
+}
+
+func (w *watchedMap) UpdateMany(ctx context.Context, keys []string, update func(string, *string) (*string, error)) error {
+	w.updated = append(w.updated, keys...)
+	return w.Map.UpdateMany(ctx, keys, update)
+}
+
+func TestKeyValueStoreUpdateWhereOnlyMatching(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+	storage := &watchedMap{Map: memory.NewKeyValueMap()}
+	store := NewKeyValueStore(NewJSON[Entry](), storage)
+	err := store.SetMany(ctx, map[string]*Entry{
+		"one": {Int: 1},
+		"two": {Int: 2},
+	})
+	Require(t, NoError(err))
+
+	_, err = store.UpdateWhere(ctx, Where("Int", "=", 1), func(_ string, entry *Entry) (*Entry, error) {
+		return entry, nil
+	})
+	Expect(t,
+		NoError(err),
+		Equalf([]string{"one"}, storage.updated, "entries that don't match shouldn't be updated"),
+	)
 }
 
 func TestKeyValueStoreReset(t *testing.T) {
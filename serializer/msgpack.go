@@ -20,8 +20,10 @@
 package serializer
 
 import (
+	"bytes"
+	"sync"
+
 	"github.com/ArnaudCalmettes/store"
-	"github.com/ArnaudCalmettes/store/internal/zerocopy"
 	"github.com/vmihailenco/msgpack"
 )
 
@@ -36,7 +38,7 @@ func (j msgpackSerializer[T]) Serialize(obj *T) (string, error) {
 		return "", ErrNilObject
 	}
 	data, err := msgpack.Marshal(obj)
-	return zerocopy.BytesToString(data), err
+	return string(data), err
 }
 
 func (j msgpackSerializer[T]) Deserialize(data string) (*T, error) {
@@ -44,6 +46,66 @@ func (j msgpackSerializer[T]) Deserialize(data string) (*T, error) {
 		return nil, ErrEmptyData
 	}
 	var obj T
-	err := msgpack.Unmarshal(zerocopy.StringToBytes(data), &obj)
+	err := msgpack.Unmarshal([]byte(data), &obj)
+	return &obj, err
+}
+
+type msgpackEncoder struct {
+	buf bytes.Buffer
+	enc *msgpack.Encoder
+}
+
+type msgpackDecoder struct {
+	r   bytes.Reader
+	dec *msgpack.Decoder
+}
+
+var (
+	msgpackEncoders = sync.Pool{
+		New: func() any {
+			e := &msgpackEncoder{}
+			e.enc = msgpack.NewEncoder(&e.buf)
+			return e
+		},
+	}
+	msgpackDecoders = sync.Pool{
+		New: func() any {
+			d := &msgpackDecoder{}
+			d.dec = msgpack.NewDecoder(&d.r)
+			return d
+		},
+	}
+)
+
+func (j msgpackSerializer[T]) SerializeAppend(dst []byte, obj *T) ([]byte, error) {
+	if obj == nil {
+		return dst, ErrNilObject
+	}
+	e := msgpackEncoders.Get().(*msgpackEncoder)
+	defer func() {
+		if e.buf.Cap() <= maxPooledBufferSize {
+			msgpackEncoders.Put(e)
+		}
+	}()
+	e.buf.Reset()
+	if err := e.enc.Encode(obj); err != nil {
+		return dst, err
+	}
+	return append(dst, e.buf.Bytes()...), nil
+}
+
+func (j msgpackSerializer[T]) DeserializeBytes(data []byte) (*T, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyData
+	}
+	d := msgpackDecoders.Get().(*msgpackDecoder)
+	defer func() {
+		d.r.Reset(nil)
+		msgpackDecoders.Put(d)
+	}()
+	d.r.Reset(data)
+	_ = d.dec.Reset(&d.r)
+	var obj T
+	err := d.dec.Decode(&obj)
 	return &obj, err
 }
@@ -0,0 +1,257 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ArnaudCalmettes/store"
+)
+
+var (
+	errUnknownFormat = errors.New("cannot detect the format of data")
+	errNotRewriter   = errors.New("serializer doesn't implement Rewriter")
+)
+
+// Format describes a codec used by MultiFormat.
+type Format[T any] struct {
+	Serializer store.Serializer[T]
+
+	// Tag, if not zero, is prepended to the values written in this format,
+	// and identifies them when reading. It should be a byte that untagged
+	// values can't start with, e.g. a control character for JSON.
+	Tag byte
+
+	// Sniff, if not nil, reports whether untagged data looks like it was
+	// written in this format.
+	Sniff func(data string) bool
+}
+
+// MultiFormat returns a serializer that writes values in the primary format,
+// and reads values written in any of the given formats.
+//
+// Values are matched against the tags of the formats first, then against
+// their sniffers, in order. Values that match neither are read with the
+// primary format if it is untagged.
+//
+// It implements Rewriter, so that values written in a secondary format are
+// rewritten when they are updated. Rewrite does so for a whole namespace.
+func MultiFormat[T any](primary Format[T], secondary ...Format[T]) store.Serializer[T] {
+	return &multiFormat[T]{
+		formats: append([]Format[T]{primary}, secondary...),
+	}
+}
+
+type multiFormat[T any] struct {
+	formats []Format[T]
+}
+
+func (m *multiFormat[T]) Serialize(obj *T) (string, error) {
+	primary := m.formats[0]
+	data, err := primary.Serializer.Serialize(obj)
+	if err != nil || primary.Tag == 0 {
+		return data, err
+	}
+	return string(primary.Tag) + data, nil
+}
+
+func (m *multiFormat[T]) Deserialize(data string) (*T, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyData
+	}
+	format, data, ok := m.detect(data)
+	if !ok {
+		return nil, errUnknownFormat
+	}
+	return m.formats[format].Serializer.Deserialize(data)
+}
+
+func (m *multiFormat[T]) NeedsRewrite(data string) bool {
+	format, _, ok := m.detect(data)
+	return ok && format != 0
+}
+
+// detect returns the index of the format data was written in, and the data
+// stripped of its tag.
+func (m *multiFormat[T]) detect(data string) (int, string, bool) {
+	if len(data) == 0 {
+		return 0, data, false
+	}
+	for i, format := range m.formats {
+		if format.Tag != 0 && format.Tag == data[0] {
+			return i, data[1:], true
+		}
+	}
+	for i, format := range m.formats {
+		if format.Tag == 0 && format.Sniff != nil && format.Sniff(data) {
+			return i, data, true
+		}
+	}
+	if m.formats[0].Tag == 0 {
+		return 0, data, true
+	}
+	return 0, data, false
+}
+
+// SniffJSON reports whether data looks like a JSON document.
+func SniffJSON(data string) bool {
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; c {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case '{', '[', '"', 't', 'f', 'n', '-':
+			return true
+		default:
+			return c >= '0' && c <= '9'
+		}
+	}
+	return false
+}
+
+type RewriteOptions struct {
+	// BatchSize is the number of values rewritten in each call to
+	// UpdateMany. It defaults to 100.
+	BatchSize int
+
+	// Pause between two batches, to limit the load on the storage.
+	Pause time.Duration
+}
+
+const defaultRewriteBatchSize = 100
+
+// Rewrite re-encodes the values of storage for which the serializer reports
+// that they need to be rewritten (see Rewriter), in batches. It returns the
+// number of values that were rewritten. Values that can't be rewritten are
+// left unchanged and reported in a BatchError. It fails if the serializer
+// doesn't implement Rewriter: Compressed, Encrypted and Checksummed do, by
+// asking the serializer they wrap.
+//
+// Outdated values are found with a single GetAll, as maps can't be scanned
+// incrementally, and rewritten by batches of UpdateMany calls. Rewrite stops
+// when ctx is done. See RewriteInBackground to run it in its own goroutine.
+func Rewrite[T any](ctx context.Context, storage store.BaseKeyValueMap, serializer store.Serializer[T], opts *RewriteOptions) (int, error) {
+	r, ok := serializer.(Rewriter)
+	if !ok {
+		return 0, fmt.Errorf("%w: %T", errNotRewriter, serializer)
+	}
+	return rewriteMap(ctx, storage, r.NeedsRewrite, func(data string) (string, error) {
+		value, err := serializer.Deserialize(data)
+		if err != nil {
+			return "", err
+		}
+		return serializer.Serialize(value)
+	}, opts)
+}
+
+// RewriteResult is the outcome of a call to Rewrite.
+type RewriteResult struct {
+	Count int
+	Err   error
+}
+
+// RewriteInBackground calls Rewrite in a new goroutine, and returns a channel
+// that receives its result once it's done. Cancelling ctx stops it.
+func RewriteInBackground[T any](ctx context.Context, storage store.BaseKeyValueMap, serializer store.Serializer[T], opts *RewriteOptions) <-chan RewriteResult {
+	done := make(chan RewriteResult, 1)
+	go func() {
+		defer close(done)
+		count, err := Rewrite(ctx, storage, serializer, opts)
+		done <- RewriteResult{Count: count, Err: err}
+	}()
+	return done
+}
+
+// rewriteMap applies rewrite to the values of storage for which outdated
+// returns true, in batches, and returns the number of rewritten values. Values
+// that rewrite fails on are left unchanged, and reported in a BatchError.
+func rewriteMap(
+	ctx context.Context,
+	storage store.BaseKeyValueMap,
+	outdated func(string) bool,
+	rewrite func(string) (string, error),
+	opts *RewriteOptions,
+) (int, error) {
+	batchSize := defaultRewriteBatchSize
+	var pause time.Duration
+	if opts != nil {
+		if opts.BatchSize > 0 {
+			batchSize = opts.BatchSize
+		}
+		pause = opts.Pause
+	}
+
+	// FIXME: A better implementation would use some form of incremental scan.
+	// TODO: Rework when a scanning interface is implemented.
+	all, err := storage.GetAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	keys := make([]string, 0, len(all))
+	for key, data := range all {
+		if outdated(data) {
+			keys = append(keys, key)
+		}
+	}
+
+	var count int
+	failed := &store.BatchError{}
+	for start := 0; start < len(keys); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		if start > 0 && pause > 0 {
+			select {
+			case <-ctx.Done():
+				return count, ctx.Err()
+			case <-time.After(pause):
+			}
+		}
+		batch := keys[start:min(start+batchSize, len(keys))]
+		updated := make(map[string]bool, len(batch))
+		err := storage.UpdateMany(ctx, batch, func(key string, data *string) (*string, error) {
+			if data == nil || !outdated(*data) {
+				updated[key] = false
+				delete(failed.Errors, key)
+				return nil, nil
+			}
+			result, err := rewrite(*data)
+			if err != nil {
+				updated[key] = false
+				failed.Add(key, err)
+				return nil, nil
+			}
+			delete(failed.Errors, key)
+			updated[key] = true
+			return &result, nil
+		})
+		if err != nil {
+			return count, err
+		}
+		for _, ok := range updated {
+			if ok {
+				count++
+			}
+		}
+	}
+	return count, failed.ErrOrNil()
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/memory"
+	. "github.com/ArnaudCalmettes/store/test"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+func newJSONToMsgpack[T any]() Serializer[T] {
+	return MultiFormat(
+		Format[T]{Serializer: NewMsgpack[T]()},
+		Format[T]{Serializer: NewJSON[T](), Sniff: SniffJSON},
+	)
+}
+
+func TestMultiFormat(t *testing.T) {
+	input := Entry{Int: 42, String: "test"}
+	jsonData, err := NewJSON[Entry]().Serialize(&input)
+	Require(t, NoError(err))
+	msgpackData, err := NewMsgpack[Entry]().Serialize(&input)
+	Require(t, NoError(err))
+
+	t.Run("sniffing", func(t *testing.T) {
+		s := newJSONToMsgpack[Entry]()
+		data, err := s.Serialize(&input)
+		Expect(t,
+			NoError(err),
+			Equal(msgpackData, data),
+		)
+		for _, data := range []string{jsonData, msgpackData} {
+			result, err := s.Deserialize(data)
+			Expect(t,
+				NoError(err),
+				Equal(&input, result),
+			)
+		}
+		r := s.(Rewriter)
+		Expect(t,
+			Equal(true, r.NeedsRewrite(jsonData)),
+			Equal(false, r.NeedsRewrite(msgpackData)),
+		)
+	})
+	t.Run("tags", func(t *testing.T) {
+		s := MultiFormat(
+			Format[Entry]{Serializer: NewMsgpack[Entry](), Tag: 1},
+			Format[Entry]{Serializer: NewGob[Entry](), Tag: 2},
+			Format[Entry]{Serializer: NewJSON[Entry]()},
+		)
+		data, err := s.Serialize(&input)
+		Expect(t,
+			NoError(err),
+			Equal("\x01"+msgpackData, data),
+		)
+
+		gobData, err := NewGob[Entry]().Serialize(&input)
+		Require(t, NoError(err))
+		for _, data := range []string{data, "\x02" + gobData} {
+			result, err := s.Deserialize(data)
+			Expect(t,
+				NoError(err),
+				Equal(&input, result),
+			)
+		}
+
+		// JSON isn't tagged and has no sniffer.
+		_, err = s.Deserialize(jsonData)
+		Expect(t, IsError(errUnknownFormat, err))
+	})
+	t.Run("empty", func(t *testing.T) {
+		_, err := newJSONToMsgpack[Entry]().Deserialize("")
+		Expect(t, IsError(ErrEmptyData, err))
+	})
+}
+
+func TestSniffJSON(t *testing.T) {
+	for data, want := range map[string]bool{
+		`{"a": 1}`:  true,
+		" \n[1, 2]": true,
+		`"string"`:  true,
+		"-12":       true,
+		"42":        true,
+		"null":      true,
+		"":          false,
+		"  ":        false,
+		"\x81\xa1":  false,
+	} {
+		Expect(t, Equalf(want, SniffJSON(data), "SniffJSON(%q)", data))
+	}
+}
+
+func TestMultiFormatRewrite(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	m := memory.NewKeyValueMap()
+	entries := make(map[string]*Entry)
+	for i := 0; i < 5; i++ {
+		entries[fmt.Sprint(i)] = &Entry{Int: i}
+	}
+	Require(t, NoError(NewKeyValueStore(NewJSON[Entry](), m).SetMany(ctx, entries)))
+
+	s := newJSONToMsgpack[Entry]()
+	store := NewKeyValueStore(s, m)
+
+	// Updates rewrite values lazily.
+	err := store.UpdateOne(ctx, "0", func(string, *Entry) (*Entry, error) {
+		return nil, nil
+	})
+	Require(t, NoError(err))
+
+	count, err := Rewrite(ctx, m, s, &RewriteOptions{BatchSize: 2})
+	Expect(t,
+		NoError(err),
+		Equal(4, count),
+	)
+
+	all, err := m.GetAll(ctx)
+	Require(t, NoError(err))
+	for key, data := range all {
+		Expect(t, Equalf(false, SniffJSON(data), "value %q wasn't rewritten", key))
+	}
+	result, err := store.GetAll(ctx)
+	Expect(t,
+		NoError(err),
+		Equal(entries, result),
+	)
+
+	count, err = Rewrite(ctx, m, s, nil)
+	Expect(t,
+		NoError(err),
+		Equal(0, count),
+	)
+}
+
+// cancelingMap cancels a context once it has gone through UpdateMany.
+type cancelingMap struct {
+	BaseKeyValueMap
+	cancel context.CancelFunc
+}
+
+func (c cancelingMap) UpdateMany(ctx context.Context, keys []string, update func(string, *string) (*string, error)) error {
+	defer c.cancel()
+	return c.BaseKeyValueMap.UpdateMany(ctx, keys, update)
+}
+
+func TestRewriteCanceled(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	m := memory.NewKeyValueMap()
+	entries := make(map[string]*Entry)
+	for i := 0; i < 4; i++ {
+		entries[fmt.Sprint(i)] = &Entry{Int: i}
+	}
+	Require(t, NoError(NewKeyValueStore(NewJSON[Entry](), m).SetMany(ctx, entries)))
+
+	rewriteCtx, cancelRewrite := context.WithCancel(ctx)
+	defer cancelRewrite()
+	count, err := Rewrite(rewriteCtx, cancelingMap{m, cancelRewrite}, newJSONToMsgpack[Entry](),
+		&RewriteOptions{BatchSize: 2},
+	)
+	Expect(t,
+		IsError(context.Canceled, err),
+		Equalf(2, count, "no batch should start once the context is canceled"),
+	)
+}
+
+func TestRewriteWrapped(t *testing.T) {
+	keyring := newTestKeyring(t, "one")
+	wrappers := map[string]func(Serializer[Entry]) Serializer[Entry]{
+		"compressed": func(s Serializer[Entry]) Serializer[Entry] {
+			return Compressed(s, &CompressionOptions{Threshold: -1})
+		},
+		"encrypted": func(s Serializer[Entry]) Serializer[Entry] {
+			return Encrypted(s, keyring)
+		},
+		"checksummed": Checksummed[Entry],
+	}
+	for name, wrap := range wrappers {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := NewTestContext()
+			defer cancel()
+
+			m := memory.NewKeyValueMap()
+			entries := map[string]*Entry{"one": {Int: 1}, "two": {Int: 2}}
+			Require(t, NoError(NewKeyValueStore(wrap(NewJSON[Entry]()), m).SetMany(ctx, entries)))
+
+			s := wrap(newJSONToMsgpack[Entry]())
+			result := <-RewriteInBackground(ctx, m, s, nil)
+			Expect(t,
+				NoError(result.Err),
+				Equal(2, result.Count),
+			)
+			count, err := Rewrite(ctx, m, s, nil)
+			Expect(t,
+				NoError(err),
+				Equal(0, count),
+			)
+			all, err := NewKeyValueStore(s, m).GetAll(ctx)
+			Expect(t,
+				NoError(err),
+				Equal(entries, all),
+			)
+		})
+	}
+	t.Run("not a rewriter", func(t *testing.T) {
+		ctx, cancel := NewTestContext()
+		defer cancel()
+		_, err := Rewrite(ctx, memory.NewKeyValueMap(), NewJSON[Entry](), nil)
+		Expect(t, IsError(errNotRewriter, err))
+	})
+}
+
+func TestMultiFormatKeyValueStore(t *testing.T) {
+	newStore := func(*testing.T) BaseKeyValueStore[Entry] {
+		return NewKeyValueStore(
+			newJSONToMsgpack[Entry](),
+			memory.NewKeyValueMap(),
+		)
+	}
+	TestBaseKeyValueStore(t, newStore)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"github.com/ArnaudCalmettes/store"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtoMessage is satisfied by pointers to generated protobuf messages.
+type ProtoMessage[T any] interface {
+	*T
+	proto.Message
+}
+
+type ProtoOptions struct {
+	// JSON stores values with protojson, which is human-readable but not
+	// deterministic, instead of the binary wire format.
+	JSON bool
+}
+
+// NewProto returns a serializer for protobuf messages, e.g.
+// NewProto[pb.Person](nil) serializes *pb.Person values.
+//
+// Values are marshalled deterministically in the binary wire format. In this
+// format, messages whose fields are all unset are empty, so they are stored
+// as protoEmpty instead, which can't start a valid message.
+func NewProto[T any, PT ProtoMessage[T]](opts *ProtoOptions) store.Serializer[T] {
+	if opts != nil && opts.JSON {
+		return protoJSONSerializer[T, PT]{}
+	}
+	return protoSerializer[T, PT]{}
+}
+
+// protoEmpty stands for empty messages. Field number 0 is reserved, so no
+// message starts with a null byte.
+const protoEmpty = "\x00"
+
+type protoSerializer[T any, PT ProtoMessage[T]] struct{}
+
+func (p protoSerializer[T, PT]) Serialize(obj *T) (string, error) {
+	if obj == nil {
+		return "", ErrNilObject
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(PT(obj))
+	if err == nil && len(data) == 0 {
+		return protoEmpty, nil
+	}
+	return string(data), err
+}
+
+func (p protoSerializer[T, PT]) Deserialize(data string) (*T, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyData
+	}
+	var obj T
+	if data == protoEmpty {
+		return &obj, nil
+	}
+	err := proto.Unmarshal([]byte(data), PT(&obj))
+	return &obj, err
+}
+
+type protoJSONSerializer[T any, PT ProtoMessage[T]] struct{}
+
+func (p protoJSONSerializer[T, PT]) Serialize(obj *T) (string, error) {
+	if obj == nil {
+		return "", ErrNilObject
+	}
+	data, err := protojson.Marshal(PT(obj))
+	return string(data), err
+}
+
+func (p protoJSONSerializer[T, PT]) Deserialize(data string) (*T, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyData
+	}
+	var obj T
+	err := protojson.Unmarshal([]byte(data), PT(&obj))
+	return &obj, err
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ArnaudCalmettes/store/memory"
+	"github.com/ArnaudCalmettes/store/serializer/internal/testpb"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func newTestProtoPerson() *testpb.Person {
+	p := &testpb.Person{
+		Id:     "1",
+		Name:   "Alice",
+		Age:    30,
+		Labels: make(map[string]string),
+	}
+	for i := 0; i < 20; i++ {
+		p.Labels[fmt.Sprint("label", i)] = fmt.Sprint(i)
+	}
+	return p
+}
+
+func TestProto(t *testing.T) {
+	input := newTestProtoPerson()
+
+	t.Run("binary", func(t *testing.T) {
+		s := NewProto[testpb.Person](nil)
+		data, err := s.Serialize(input)
+		Require(t, NoError(err))
+
+		want, err := proto.MarshalOptions{Deterministic: true}.Marshal(input)
+		Expect(t,
+			NoError(err),
+			Equal(string(want), data),
+		)
+		for i := 0; i < 10; i++ {
+			again, err := s.Serialize(input)
+			Expect(t,
+				NoError(err),
+				Equalf(data, again, "serialization isn't deterministic"),
+			)
+		}
+
+		result, err := s.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(input, result, protocmp.Transform()),
+		)
+	})
+	t.Run("empty message", func(t *testing.T) {
+		s := NewProto[testpb.Person](nil)
+		data, err := s.Serialize(&testpb.Person{})
+		Require(t, NoError(err))
+		Expect(t, Equal(protoEmpty, data))
+		result, err := s.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(&testpb.Person{}, result, protocmp.Transform()),
+		)
+
+		// Wrappers reject empty data, which protoEmpty avoids.
+		c := Checksummed(s)
+		data, err = c.Serialize(&testpb.Person{})
+		Require(t, NoError(err))
+		result, err = c.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(&testpb.Person{}, result, protocmp.Transform()),
+		)
+
+		_, err = s.Deserialize("")
+		Expect(t, IsError(ErrEmptyData, err))
+	})
+	t.Run("json", func(t *testing.T) {
+		s := NewProto[testpb.Person](&ProtoOptions{JSON: true})
+		data, err := s.Serialize(input)
+		Expect(t,
+			NoError(err),
+			Equalf(true, strings.Contains(data, `"Alice"`), "%s isn't human-readable", data),
+		)
+
+		result, err := s.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(input, result, protocmp.Transform()),
+		)
+
+		_, err = s.Deserialize("")
+		Expect(t, IsError(ErrEmptyData, err))
+	})
+	t.Run("nil", func(t *testing.T) {
+		for _, opts := range []*ProtoOptions{nil, {JSON: true}} {
+			_, err := NewProto[testpb.Person](opts).Serialize(nil)
+			Expect(t, IsError(ErrNilObject, err))
+		}
+	})
+}
+
+func TestProtoKeyValueStore(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	store := NewKeyValueStore(NewProto[testpb.Person](nil), memory.NewKeyValueMap())
+	items := map[string]*testpb.Person{
+		"1": newTestProtoPerson(),
+		"2": {Id: "2", Name: "Bob"},
+	}
+	Require(t, NoError(store.SetMany(ctx, items)))
+
+	all, err := store.GetAll(ctx)
+	Expect(t,
+		NoError(err),
+		Equal(items, all, protocmp.Transform()),
+	)
+
+	err = store.UpdateOne(ctx, "2", func(_ string, p *testpb.Person) (*testpb.Person, error) {
+		p.Age = 42
+		return p, nil
+	})
+	Require(t, NoError(err))
+	bob, err := store.GetOne(ctx, "2")
+	Expect(t,
+		NoError(err),
+		Equal(&testpb.Person{Id: "2", Name: "Bob", Age: 42}, bob, protocmp.Transform()),
+	)
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ArnaudCalmettes/store"
+)
+
+// Upgrade transforms the JSON representation of a value from one version to
+// the next.
+type Upgrade func(json.RawMessage) (json.RawMessage, error)
+
+type VersionedOptions struct {
+	// Upgrades[i] upgrades values from version i+1 to version i+2. Values
+	// are serialized with the latest version, that is len(Upgrades)+1.
+	Upgrades []Upgrade
+
+	// WriteBack makes updates rewrite values serialized with an older
+	// version, even if the update callback leaves them unchanged.
+	WriteBack bool
+}
+
+// NewVersioned returns a JSON serializer that wraps values in an envelope
+// holding their version: {"v": N, "data": ...}. Values serialized with an
+// older version are upgraded when read.
+//
+// Values stored without an envelope (e.g. by NewJSON) are read as version 1.
+func NewVersioned[T any](opts *VersionedOptions) store.Serializer[T] {
+	v := &versioned[T]{}
+	if opts != nil {
+		v.upgrades = opts.Upgrades
+		v.writeBack = opts.WriteBack
+	}
+	return v
+}
+
+type versioned[T any] struct {
+	upgrades  []Upgrade
+	writeBack bool
+}
+
+type versionedEnvelope struct {
+	Version *int            `json:"v"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func (v *versioned[T]) current() int {
+	return len(v.upgrades) + 1
+}
+
+func (v *versioned[T]) Serialize(obj *T) (string, error) {
+	if obj == nil {
+		return "", ErrNilObject
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	version := v.current()
+	envelope, err := json.Marshal(versionedEnvelope{Version: &version, Data: data})
+	return string(envelope), err
+}
+
+func (v *versioned[T]) Deserialize(data string) (*T, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyData
+	}
+	version, raw, err := v.open(data)
+	if err != nil {
+		return nil, err
+	}
+	for ; version < v.current(); version++ {
+		raw, err = v.upgrades[version-1](raw)
+		if err != nil {
+			return nil, fmt.Errorf("upgrading from version %d: %w", version, err)
+		}
+	}
+	var obj T
+	err = json.Unmarshal(raw, &obj)
+	return &obj, err
+}
+
+// open returns the version of data and the value it holds. Upgrades may
+// modify the returned message in place, so it doesn't share memory with data.
+func (v *versioned[T]) open(data string) (int, json.RawMessage, error) {
+	var envelope versionedEnvelope
+	raw := []byte(data)
+	var typeErr *json.UnmarshalTypeError
+	err := json.Unmarshal(raw, &envelope)
+	if errors.As(err, &typeErr) || (err == nil && (envelope.Version == nil || envelope.Data == nil)) {
+		// Valid JSON that isn't an envelope.
+		return 1, raw, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	version := *envelope.Version
+	if version < 1 || version > v.current() {
+		return 0, nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return version, envelope.Data, nil
+}
+
+func (v *versioned[T]) NeedsRewrite(data string) bool {
+	if !v.writeBack {
+		return false
+	}
+	version, _, err := v.open(data)
+	return err == nil && version < v.current()
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package serializer
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/memory"
+	. "github.com/ArnaudCalmettes/store/test"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+type personV1 struct {
+	Name string
+}
+
+type personV2 struct {
+	FirstName string
+	LastName  string
+}
+
+type personV3 struct {
+	FirstName string
+	LastName  string
+	Age       int
+}
+
+func upgradePersonV1(raw json.RawMessage) (json.RawMessage, error) {
+	var v1 personV1
+	if err := json.Unmarshal(raw, &v1); err != nil {
+		return nil, err
+	}
+	first, last, _ := strings.Cut(v1.Name, " ")
+	return json.Marshal(personV2{FirstName: first, LastName: last})
+}
+
+func upgradePersonV2(raw json.RawMessage) (json.RawMessage, error) {
+	var v2 personV2
+	if err := json.Unmarshal(raw, &v2); err != nil {
+		return nil, err
+	}
+	return json.Marshal(personV3{FirstName: v2.FirstName, LastName: v2.LastName, Age: -1})
+}
+
+func TestVersioned(t *testing.T) {
+	v1 := NewVersioned[personV1](nil)
+	v2 := NewVersioned[personV2](&VersionedOptions{
+		Upgrades: []Upgrade{upgradePersonV1},
+	})
+	v3 := NewVersioned[personV3](&VersionedOptions{
+		Upgrades: []Upgrade{upgradePersonV1, upgradePersonV2},
+	})
+	want := &personV3{FirstName: "John", LastName: "Doe", Age: -1}
+
+	t.Run("nil", func(t *testing.T) {
+		_, err := v1.Serialize(nil)
+		Expect(t, IsError(ErrNilObject, err))
+	})
+	t.Run("empty", func(t *testing.T) {
+		_, err := v1.Deserialize("")
+		Expect(t, IsError(ErrEmptyData, err))
+	})
+	t.Run("envelope", func(t *testing.T) {
+		data, err := v2.Serialize(&personV2{FirstName: "John"})
+		Expect(t,
+			NoError(err),
+			Equal(`{"v":2,"data":{"FirstName":"John","LastName":""}}`, data),
+		)
+	})
+	t.Run("upgrade", func(t *testing.T) {
+		data, err := v1.Serialize(&personV1{Name: "John Doe"})
+		Require(t, NoError(err))
+		result, err := v3.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(want, result),
+		)
+
+		data, err = v2.Serialize(&personV2{FirstName: "John", LastName: "Doe"})
+		Require(t, NoError(err))
+		result, err = v3.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(want, result),
+		)
+	})
+	t.Run("unversioned", func(t *testing.T) {
+		data, err := NewJSON[personV1]().Serialize(&personV1{Name: "John Doe"})
+		Require(t, NoError(err))
+		result, err := v3.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(want, result),
+		)
+	})
+	t.Run("malformed", func(t *testing.T) {
+		s := NewVersioned[personV2](&VersionedOptions{
+			Upgrades: []Upgrade{
+				func(json.RawMessage) (json.RawMessage, error) {
+					t.Error("malformed data shouldn't be upgraded")
+					return nil, nil
+				},
+			},
+		})
+		_, err := s.Deserialize(`{"v": 1, "data": {`)
+		var syntaxErr *json.SyntaxError
+		Expect(t,
+			Equalf(true, errors.As(err, &syntaxErr), "expected a syntax error, got %v", err),
+		)
+	})
+	t.Run("upgrades don't modify data", func(t *testing.T) {
+		s := NewVersioned[personV2](&VersionedOptions{
+			Upgrades: []Upgrade{
+				func(raw json.RawMessage) (json.RawMessage, error) {
+					clear(raw)
+					return upgradePersonV1([]byte(`{"Name": "John Doe"}`))
+				},
+			},
+		})
+		data, err := v1.Serialize(&personV1{Name: "John Doe"})
+		Require(t, NoError(err))
+		before := strings.Clone(data)
+		_, err = s.Deserialize(data)
+		Expect(t,
+			NoError(err),
+			Equal(before, data),
+		)
+	})
+	t.Run("unknown version", func(t *testing.T) {
+		data, err := v3.Serialize(want)
+		Require(t, NoError(err))
+		_, err = v2.Deserialize(data)
+		Expect(t, IsError(ErrUnknownVersion, err))
+	})
+	t.Run("upgrade error", func(t *testing.T) {
+		errTest := errors.New("test")
+		s := NewVersioned[personV2](&VersionedOptions{
+			Upgrades: []Upgrade{
+				func(json.RawMessage) (json.RawMessage, error) { return nil, errTest },
+			},
+		})
+		data, err := v1.Serialize(&personV1{Name: "John Doe"})
+		Require(t, NoError(err))
+		_, err = s.Deserialize(data)
+		Expect(t, IsError(errTest, err))
+	})
+}
+
+func TestVersionedWriteBack(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	for _, writeBack := range []bool{false, true} {
+		m := memory.NewKeyValueMap()
+		old := NewKeyValueStore(NewVersioned[personV1](nil), m)
+		Require(t, NoError(old.SetOne(ctx, "john", &personV1{Name: "John Doe"})))
+		before, err := m.GetOne(ctx, "john")
+		Require(t, NoError(err))
+
+		s := NewKeyValueStore(NewVersioned[personV2](&VersionedOptions{
+			Upgrades:  []Upgrade{upgradePersonV1},
+			WriteBack: writeBack,
+		}), m)
+		err = s.UpdateOne(ctx, "john", func(_ string, p *personV2) (*personV2, error) {
+			p.LastName = "modified"
+			return nil, nil
+		})
+		Require(t, NoError(err))
+
+		after, err := m.GetOne(ctx, "john")
+		Require(t, NoError(err))
+		if writeBack {
+			Expect(t, Equal(`{"v":2,"data":{"FirstName":"John","LastName":"Doe"}}`, after))
+		} else {
+			Expect(t, Equal(before, after))
+		}
+	}
+}
+
+func TestVersionedKeyValueStore(t *testing.T) {
+	newStore := func(*testing.T) BaseKeyValueStore[Entry] {
+		return NewKeyValueStore(
+			NewVersioned[Entry](&VersionedOptions{WriteBack: true}),
+			memory.NewKeyValueMap(),
+		)
+	}
+	TestBaseKeyValueStore(t, newStore)
+}
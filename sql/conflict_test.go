@@ -0,0 +1,61 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package sql
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"unsafe"
+
+	. "github.com/ArnaudCalmettes/store"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+	"github.com/lib/pq"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sql state " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+// newPGDriverError returns a pgdriver.Error with the given SQLSTATE code.
+// pgdriver only builds them from server responses, so the map of their
+// fields is set through reflection.
+func newPGDriverError(code string) pgdriver.Error {
+	var err pgdriver.Error
+	fields := reflect.ValueOf(&err).Elem().Field(0)
+	reflect.NewAt(fields.Type(), unsafe.Pointer(fields.UnsafeAddr())).Elem().
+		Set(reflect.ValueOf(map[byte]string{'C': code}))
+	return err
+}
+
+func TestIsConflict(t *testing.T) {
+	Expect(t,
+		Equal(true, isConflict(sqlStateError("40001"))),
+		Equal(true, isConflict(fmt.Errorf("wrapped: %w", sqlStateError("40P01")))),
+		Equal(true, isConflict(&pq.Error{Code: "40001"})),
+		Equal(true, isConflict(newPGDriverError("40001"))),
+		Equal(true, isConflict(fmt.Errorf("wrapped: %w", newPGDriverError("40P01")))),
+		Equal(false, isConflict(newPGDriverError("23505"))),
+		Equal(false, isConflict(sqlStateError("23505"))),
+		Equal(false, isConflict(ErrNotFound)),
+	)
+}
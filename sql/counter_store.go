@@ -0,0 +1,139 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"slices"
+
+	"github.com/uptrace/bun"
+	"golang.org/x/exp/maps"
+
+	//lint:ignore ST1001 common definitions
+	. "github.com/ArnaudCalmettes/store"
+)
+
+type CounterStore interface {
+	Counter
+	ErrorMapSetter
+	Resetter
+}
+
+func NewCounterStore(db *bun.DB) CounterStore {
+	c := &counterStore{
+		db: db,
+		txOptions: &sql.TxOptions{
+			Isolation: sql.LevelSerializable,
+		},
+	}
+	c.InitDefaultErrors()
+	return c
+}
+
+type counterRow struct {
+	bun.BaseModel `bun:"table:counters"`
+
+	Key   string `bun:",pk"`
+	Value int64  `bun:",notnull"`
+}
+
+type counterStore struct {
+	db        *bun.DB
+	txOptions *sql.TxOptions
+	ErrorMap
+}
+
+func (c *counterStore) SetErrorMap(errorMap ErrorMap) {
+	c.ErrorMap = errorMap
+	c.InitDefaultErrors()
+}
+
+func (c *counterStore) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	if key == "" {
+		return 0, c.ErrEmptyKey
+	}
+	result, err := c.IncrMany(ctx, map[string]int64{key: delta})
+	if err != nil {
+		return 0, err
+	}
+	return result[key], nil
+}
+
+func (c *counterStore) IncrMany(ctx context.Context, deltas map[string]int64) (map[string]int64, error) {
+	batch := &BatchError{}
+	keys := slices.DeleteFunc(maps.Keys(deltas), func(e string) bool { return e == "" })
+	if len(keys) < len(deltas) {
+		batch.Add("", c.ErrEmptyKey)
+	}
+	if len(keys) == 0 {
+		return map[string]int64{}, batch.ErrOrNil()
+	}
+	// Sorting keys ensures concurrent transactions lock rows in the same order.
+	slices.Sort(keys)
+	var rows []counterRow
+	err := c.db.RunInTx(ctx, c.txOptions, func(ctx context.Context, tx bun.Tx) error {
+		for _, key := range keys {
+			_, err := tx.NewInsert().Model(&counterRow{Key: key}).Ignore().Exec(ctx)
+			if err != nil {
+				return err
+			}
+			delta := deltas[key]
+			query := tx.NewUpdate().Model((*counterRow)(nil)).
+				Set("? = ? + ?", bun.Ident("value"), bun.Ident("value"), delta).
+				Where("? = ?", bun.Ident("key"), key)
+			// Databases don't agree on integer overflows (SQLite silently
+			// switches to floats), so they are ruled out before adding.
+			switch {
+			case delta > 0:
+				query = query.Where("? <= ?", bun.Ident("value"), int64(math.MaxInt64)-delta)
+			case delta < 0:
+				query = query.Where("? >= ?", bun.Ident("value"), int64(math.MinInt64)-delta)
+			}
+			res, err := query.Exec(ctx)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return c.ErrOverflow
+			}
+		}
+		return tx.NewSelect().Model(&rows).
+			Where("? IN (?)", bun.Ident("key"), bun.In(keys)).
+			Scan(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		result[row.Key] = row.Value
+	}
+	return result, batch.ErrOrNil()
+}
+
+func (c *counterStore) Reset(ctx context.Context) error {
+	return c.db.ResetModel(ctx, (*counterRow)(nil))
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package sql
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	. "github.com/ArnaudCalmettes/store/test"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+	"github.com/rubenv/pgtest"
+)
+
+func TestSQLiteCounterStore(t *testing.T) {
+	newCounter := func(t *testing.T) Counter {
+		store := NewCounterStore(newSQLite(t))
+		err := store.Reset(context.Background())
+		Require(t,
+			NoError(err),
+		)
+		return store
+	}
+	TestCounter(t, newCounter)
+}
+
+func TestPGCounterStore(t *testing.T) {
+	pg, err := pgtest.Start()
+	Require(t,
+		NoError(err),
+	)
+	t.Cleanup(func() { pg.Stop() })
+
+	newCounter := func(t *testing.T) Counter {
+		store := NewCounterStore(newPostgres(t, pg))
+		err := store.Reset(context.Background())
+		Require(t,
+			NoError(err),
+		)
+		return store
+	}
+	TestCounter(t, newCounter)
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package sql
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// KeyCodec maps the string keys of the KeyValueStore API to and from the
+// values of the primary key columns of a table, in order.
+type KeyCodec interface {
+	Encode(values []any) (string, error)
+	Decode(key string) ([]any, error)
+}
+
+var (
+	ErrUnsupportedKeyType = errors.New("unsupported primary key type")
+	errKeyArity           = errors.New("wrong number of key components")
+)
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// NewKeyCodec returns the default codec for primary keys made of columns of
+// the given types. Strings are used as-is, integers are written in base 10,
+// and types that implement encoding.TextMarshaler and TextUnmarshaler (e.g.
+// uuid.UUID) are written in their text form.
+//
+// Composite keys are written as the tuple of their components, separated
+// with ':'. Occurrences of ':' and '\' in components are escaped with '\'.
+func NewKeyCodec(types ...reflect.Type) (KeyCodec, error) {
+	codec := make(tupleCodec, len(types))
+	for i, typ := range types {
+		var err error
+		if codec[i], err = newComponentCodec(typ); err != nil {
+			return nil, err
+		}
+	}
+	return codec, nil
+}
+
+type componentCodec struct {
+	encode func(reflect.Value) (string, error)
+	decode func(string) (reflect.Value, error)
+}
+
+func newComponentCodec(typ reflect.Type) (componentCodec, error) {
+	switch {
+	case typ.Implements(textMarshalerType) && reflect.PointerTo(typ).Implements(textUnmarshalerType):
+		return componentCodec{
+			encode: func(v reflect.Value) (string, error) {
+				text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+				return string(text), err
+			},
+			decode: func(s string) (reflect.Value, error) {
+				v := reflect.New(typ)
+				err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+				return v.Elem(), err
+			},
+		}, nil
+	case typ.Kind() == reflect.String:
+		return componentCodec{
+			encode: func(v reflect.Value) (string, error) {
+				return v.String(), nil
+			},
+			decode: func(s string) (reflect.Value, error) {
+				return reflect.ValueOf(s).Convert(typ), nil
+			},
+		}, nil
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Int64:
+		return componentCodec{
+			encode: func(v reflect.Value) (string, error) {
+				return strconv.FormatInt(v.Int(), 10), nil
+			},
+			decode: func(s string) (reflect.Value, error) {
+				i, err := strconv.ParseInt(s, 10, typ.Bits())
+				v := reflect.New(typ).Elem()
+				v.SetInt(i)
+				return v, err
+			},
+		}, nil
+	case typ.Kind() >= reflect.Uint && typ.Kind() <= reflect.Uint64:
+		return componentCodec{
+			encode: func(v reflect.Value) (string, error) {
+				return strconv.FormatUint(v.Uint(), 10), nil
+			},
+			decode: func(s string) (reflect.Value, error) {
+				u, err := strconv.ParseUint(s, 10, typ.Bits())
+				v := reflect.New(typ).Elem()
+				v.SetUint(u)
+				return v, err
+			},
+		}, nil
+	}
+	return componentCodec{}, fmt.Errorf("%w: %s", ErrUnsupportedKeyType, typ)
+}
+
+type tupleCodec []componentCodec
+
+func (t tupleCodec) Encode(values []any) (string, error) {
+	if len(values) != len(t) {
+		return "", fmt.Errorf("%w: got %d, want %d", errKeyArity, len(values), len(t))
+	}
+	if len(t) == 1 {
+		return t[0].encode(reflect.ValueOf(values[0]))
+	}
+	var b strings.Builder
+	for i, value := range values {
+		s, err := t[i].encode(reflect.ValueOf(value))
+		if err != nil {
+			return "", err
+		}
+		if i > 0 {
+			b.WriteByte(':')
+		}
+		for j := 0; j < len(s); j++ {
+			if s[j] == ':' || s[j] == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(s[j])
+		}
+	}
+	return b.String(), nil
+}
+
+func (t tupleCodec) Decode(key string) ([]any, error) {
+	components := []string{key}
+	if len(t) > 1 {
+		components = splitTuple(key)
+	}
+	if len(components) != len(t) {
+		return nil, fmt.Errorf("%w: got %d, want %d", errKeyArity, len(components), len(t))
+	}
+	values := make([]any, len(t))
+	for i, component := range components {
+		v, err := t[i].decode(component)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v.Interface()
+	}
+	return values, nil
+}
+
+func splitTuple(key string) []string {
+	var components []string
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; {
+		case c == '\\' && i+1 < len(key):
+			i++
+			b.WriteByte(key[i])
+		case c == ':':
+			components = append(components, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return append(components, b.String())
+}
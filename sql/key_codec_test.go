@@ -0,0 +1,81 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package sql
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+	"github.com/google/uuid"
+)
+
+func TestKeyCodec(t *testing.T) {
+	id := uuid.MustParse("5b0b4c8e-4a0e-4f5c-9a3e-2f8d1c7b6a59")
+	testCases := []struct {
+		Name   string
+		Types  []reflect.Type
+		Values []any
+		Key    string
+	}{
+		{"string", []reflect.Type{reflect.TypeFor[string]()}, []any{"a:b"}, "a:b"},
+		{"int64", []reflect.Type{reflect.TypeFor[int64]()}, []any{int64(-42)}, "-42"},
+		{"uint16", []reflect.Type{reflect.TypeFor[uint16]()}, []any{uint16(42)}, "42"},
+		{"uuid", []reflect.Type{reflect.TypeFor[uuid.UUID]()}, []any{id}, id.String()},
+		{
+			"tuple",
+			[]reflect.Type{reflect.TypeFor[int64](), reflect.TypeFor[string]()},
+			[]any{int64(1), `a:b\c`},
+			`1:a\:b\\c`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			codec, err := NewKeyCodec(tc.Types...)
+			Require(t, NoError(err))
+			key, err := codec.Encode(tc.Values)
+			Expect(t,
+				NoError(err),
+				Equal(tc.Key, key),
+			)
+			values, err := codec.Decode(tc.Key)
+			Expect(t,
+				NoError(err),
+				Equal(tc.Values, values),
+			)
+		})
+	}
+	t.Run("unsupported type", func(t *testing.T) {
+		_, err := NewKeyCodec(reflect.TypeFor[float64]())
+		Expect(t, IsError(ErrUnsupportedKeyType, err))
+	})
+	t.Run("invalid keys", func(t *testing.T) {
+		codec, err := NewKeyCodec(reflect.TypeFor[int64](), reflect.TypeFor[int8]())
+		Require(t, NoError(err))
+		_, err = codec.Decode("1")
+		Expect(t, IsError(errKeyArity, err))
+		_, err = codec.Decode("1:2:3")
+		Expect(t, IsError(errKeyArity, err))
+		_, err = codec.Decode("1:x")
+		Expect(t, Equal(true, err != nil))
+		_, err = codec.Decode("1:300")
+		Expect(t, Equal(true, err != nil))
+	})
+}
@@ -24,7 +24,10 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"reflect"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect"
@@ -40,39 +43,265 @@ import (
 type KeyValueStore[T any] interface {
 	BaseKeyValueStore[T]
 	Lister[T]
+	WhereDeleter
+	WhereUpdater[T]
 	ErrorMapSetter
 	Resetter
+	ClockSetter
+	SchemaEnsurer
+	SoftDeleter
+
+	// WithTx returns a view of the store that runs all its operations in
+	// tx. Transactions opened by the view, such as in UpdateMany, become
+	// savepoints of tx, which run with the isolation level of tx rather than
+	// the serializable one of the store. They aren't retried on conflicts,
+	// since a serialization failure aborts tx as a whole: the error of the
+	// database is returned unchanged, and the caller is expected to retry
+	// its whole transaction.
+	WithTx(tx bun.IDB) KeyValueStore[T]
+}
+
+// SoftDeleter is implemented by stores that can recover deleted items.
+//
+// When the model of a KeyValueStore has a field tagged with bun's
+// soft_delete option, Delete and DeleteWhere set this field instead of
+// removing rows, and soft-deleted rows are hidden from reads. Setting a
+// soft-deleted key overwrites it as if it were absent.
+type SoftDeleter interface {
+	// Restore brings back the soft-deleted items with the given keys.
+	Restore(ctx context.Context, keys ...string) error
+
+	// Purge permanently removes the soft-deleted items with the given keys.
+	// Items that haven't been deleted are left untouched.
+	Purge(ctx context.Context, keys ...string) error
+}
+
+var errNoSoftDelete = errors.New("model doesn't have a soft_delete field")
+
+// StoreOptions configures a KeyValueStore.
+type StoreOptions struct {
+	// KeyCodec maps the keys of the store to the primary key columns of the
+	// table. It defaults to NewKeyCodec for the types of the primary key
+	// fields of T.
+	KeyCodec KeyCodec
+
+	// Isolation is the isolation level of the transactions used by
+	// UpdateMany and UpdateWhere. It defaults to sql.LevelSerializable.
+	Isolation sql.IsolationLevel
+
+	// Retry configures how transactions are retried after serialization
+	// failures and deadlocks.
+	Retry *RetryOptions
+}
+
+// NewKeyValueStore is like TryNewKeyValueStore, but panics if T is not a
+// valid model.
+func NewKeyValueStore[T any](db *bun.DB, opts ...*StoreOptions) KeyValueStore[T] {
+	k, err := TryNewKeyValueStore[T](db, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return k
 }
 
-func NewKeyValueStore[T any](db *bun.DB) KeyValueStore[T] {
+// TryNewKeyValueStore returns a KeyValueStore backed by the table of the bun
+// model T. If T can't be used as a model, it returns a *ModelError.
+func TryNewKeyValueStore[T any](db *bun.DB, opts ...*StoreOptions) (KeyValueStore[T], error) {
 	k := &keyValueStore[T]{
-		db: db,
+		db:   db,
+		conn: db,
 		txOptions: &sql.TxOptions{
 			Isolation: sql.LevelSerializable,
 		},
+		retry: defaultRetryOptions,
+		now:   time.Now,
 	}
+	typ := reflect.TypeFor[T]()
+	modelErr := &ModelError{Model: typ.String()}
 	var err error
 	if k.spec, err = libbun.GetTableSpec[T](); err != nil {
-		panic(err)
+		modelErr.Problems = append(modelErr.Problems, err)
+		return nil, modelErr
 	}
-	if err = k.spec.Validate(); err != nil {
-		panic(err)
+	modelErr.Problems = k.spec.Problems()
+	keyTypes := make([]reflect.Type, len(k.spec.KeyFields))
+	for i, name := range k.spec.KeyFields {
+		field, _ := typ.FieldByName(name)
+		k.keyIndex = append(k.keyIndex, field.Index)
+		keyTypes[i] = field.Type
 	}
-	if k.getKey, err = inspect.FieldSelector[T, string](k.spec.KeyField); err != nil {
-		panic(err)
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if opt.KeyCodec != nil {
+			k.codec = opt.KeyCodec
+		}
+		if opt.Isolation != sql.LevelDefault {
+			k.txOptions.Isolation = opt.Isolation
+		}
+		if opt.Retry != nil {
+			k.retry = opt.Retry.withDefaults()
+		}
+	}
+	if k.codec == nil && len(keyTypes) > 0 {
+		if k.codec, err = NewKeyCodec(keyTypes...); err != nil {
+			modelErr.Problems = append(modelErr.Problems, err)
+		}
+	}
+	if k.auto, err = inspect.NewAutoFields[T](); err != nil {
+		modelErr.Problems = append(modelErr.Problems, err)
+	}
+	if len(modelErr.Problems) > 0 {
+		return nil, modelErr
 	}
-	k.setKey, _ = inspect.StringFieldSetter[T](k.spec.KeyField)
 	k.InitDefaultErrors()
-	return k
+	return k, nil
 }
 
 type keyValueStore[T any] struct {
 	db   *bun.DB
+	conn bun.IDB
 	spec *libbun.TableSpec
 	ErrorMap
-	getKey    func(*T) string
-	setKey    func(*T, string)
+	codec     KeyCodec
+	keyIndex  [][]int
 	txOptions *sql.TxOptions
+	retry     RetryOptions
+	inTx      bool
+	auto      *inspect.AutoFields[T]
+	now       func() time.Time
+}
+
+func (k *keyValueStore[T]) SetClock(now func() time.Time) {
+	k.now = now
+}
+
+// touch returns a copy of item with its automatic fields updated, prev being
+// the row it replaces, if known. When it isn't, handleInsertConflict keeps
+// the creation time and increments the version of existing rows that aren't
+// soft-deleted.
+func (k *keyValueStore[T]) touch(item *T, prev *T) *T {
+	if k.auto == nil {
+		return item
+	}
+	touched := *item
+	k.auto.Touch(&touched, prev, k.now())
+	return &touched
+}
+
+func (k *keyValueStore[T]) WithTx(tx bun.IDB) KeyValueStore[T] {
+	view := *k
+	view.conn = tx
+	switch tx.(type) {
+	case bun.Tx, *bun.Tx:
+		view.inTx = true
+	}
+	return &view
+}
+
+// getKey returns the encoded primary key of item.
+func (k *keyValueStore[T]) getKey(item *T) (string, error) {
+	val := reflect.ValueOf(item).Elem()
+	values := make([]any, len(k.keyIndex))
+	for i, index := range k.keyIndex {
+		values[i] = val.FieldByIndex(index).Interface()
+	}
+	return k.codec.Encode(values)
+}
+
+// decodeKey returns the primary key values that key stands for.
+func (k *keyValueStore[T]) decodeKey(key string) ([]any, error) {
+	if key == "" {
+		return nil, k.ErrEmptyKey
+	}
+	values, err := k.codec.Decode(key)
+	if err == nil && len(values) != len(k.keyIndex) {
+		err = fmt.Errorf("%w: got %d, want %d", errKeyArity, len(values), len(k.keyIndex))
+	}
+	if err != nil {
+		return nil, errors.Join(k.ErrInvalidKey, err)
+	}
+	return values, nil
+}
+
+// setKey sets the primary key fields of item to the values of key.
+func (k *keyValueStore[T]) setKey(item *T, key string) error {
+	values, err := k.decodeKey(key)
+	if err != nil {
+		return err
+	}
+	val := reflect.ValueOf(item).Elem()
+	for i, index := range k.keyIndex {
+		field := val.FieldByIndex(index)
+		v := reflect.ValueOf(values[i])
+		if !v.IsValid() || !v.Type().ConvertibleTo(field.Type()) {
+			return fmt.Errorf("%w: cannot use %T as %s",
+				k.ErrInvalidKey, values[i], field.Type(),
+			)
+		}
+		field.Set(v.Convert(field.Type()))
+	}
+	return nil
+}
+
+// requestedKeys holds decoded keys, along with the keys the caller used for
+// them, indexed by their canonical form.
+type requestedKeys struct {
+	values [][]any
+	byKey  map[string][]string
+}
+
+// parseKeys decodes keys, reporting invalid ones in batch.
+func (k *keyValueStore[T]) parseKeys(keys []string, batch *BatchError) requestedKeys {
+	req := requestedKeys{
+		values: make([][]any, 0, len(keys)),
+		byKey:  make(map[string][]string, len(keys)),
+	}
+	for _, key := range keys {
+		values, err := k.decodeKey(key)
+		if err != nil {
+			batch.Add(key, err)
+			continue
+		}
+		canonical, err := k.codec.Encode(values)
+		if err != nil {
+			batch.Add(key, errors.Join(k.ErrInvalidKey, err))
+			continue
+		}
+		if _, ok := req.byKey[canonical]; !ok {
+			req.values = append(req.values, values)
+		}
+		req.byKey[canonical] = append(req.byKey[canonical], key)
+	}
+	return req
+}
+
+// whereKeys restricts a query to the rows matching the given primary keys.
+func (k *keyValueStore[T]) whereKeys(keys [][]any) func(bun.QueryBuilder) bun.QueryBuilder {
+	return func(q bun.QueryBuilder) bun.QueryBuilder {
+		if len(k.spec.KeyColumns) == 1 {
+			column := make([]any, len(keys))
+			for i, values := range keys {
+				column[i] = values[0]
+			}
+			return q.Where("? IN (?)", bun.Ident(k.spec.KeyColumns[0]), bun.In(column))
+		}
+		conds := make([]string, 0, len(k.spec.KeyColumns))
+		for range k.spec.KeyColumns {
+			conds = append(conds, "? = ?")
+		}
+		group := "(" + strings.Join(conds, " AND ") + ")"
+		groups := make([]string, len(keys))
+		args := make([]any, 0, 2*len(keys)*len(k.spec.KeyColumns))
+		for i, values := range keys {
+			groups[i] = group
+			for j, column := range k.spec.KeyColumns {
+				args = append(args, bun.Ident(column), values[j])
+			}
+		}
+		return q.Where("("+strings.Join(groups, " OR ")+")", args...)
+	}
 }
 
 func (k *keyValueStore[T]) SetErrorMap(errorMap ErrorMap) {
@@ -86,15 +315,21 @@ func (k *keyValueStore[T]) List(ctx context.Context, opts ...*Options) ([]*T, er
 		return nil, errors.Join(k.ErrInvalidOption, err)
 	}
 	var items []*T
-	query := k.db.NewSelect().Model(&items)
+	query := k.conn.NewSelect().Model(&items)
 	if opt.Filter != nil {
-		qb, err := libbun.BuilderForFilter(opt.Filter, k.spec)
+		qb, err := libbun.BuilderForFilter(opt.Filter, k.spec, k.conn.Dialect().Name())
 		if err != nil {
 			return nil, errors.Join(k.ErrInvalidFilter, err)
 		}
 		query.ApplyQueryBuilder(qb)
 	}
-	if order := opt.OrderBy; order != nil {
+	if order := opt.OrderBy; order != nil && order.Query != "" {
+		expr, args, err := libbun.RelevanceOrder(order, k.spec, k.conn.Dialect().Name())
+		if err != nil {
+			return nil, errors.Join(k.ErrInvalidOption, err)
+		}
+		query.OrderExpr(expr, args...)
+	} else if order != nil {
 		column, ok := k.spec.ColumnNames[order.Field]
 		if !ok {
 			return nil, fmt.Errorf("%w: no such column: %s",
@@ -114,12 +349,13 @@ func (k *keyValueStore[T]) List(ctx context.Context, opts ...*Options) ([]*T, er
 }
 
 func (k *keyValueStore[T]) GetOne(ctx context.Context, key string) (*T, error) {
-	if key == "" {
-		return nil, k.ErrEmptyKey
+	values, err := k.decodeKey(key)
+	if err != nil {
+		return nil, err
 	}
 	var item T
-	query := k.db.NewSelect().Model(&item).Where("? = ?", bun.Ident(k.spec.KeySQL), key)
-	err := query.Scan(ctx)
+	query := k.conn.NewSelect().Model(&item).ApplyQueryBuilder(k.whereKeys([][]any{values}))
+	err = query.Scan(ctx)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			err = k.ErrNotFound
@@ -130,54 +366,82 @@ func (k *keyValueStore[T]) GetOne(ctx context.Context, key string) (*T, error) {
 }
 
 func (k *keyValueStore[T]) GetMany(ctx context.Context, keys []string) (map[string]*T, error) {
+	batch := &BatchError{}
+	req := k.parseKeys(keys, batch)
+	result := make(map[string]*T, len(req.values))
+	if len(req.values) == 0 {
+		return result, batch.ErrOrNil()
+	}
 	var items []T
-	err := k.db.NewSelect().Model(&items).
-		Where("? IN (?)", bun.Ident(k.spec.KeySQL), bun.In(keys)).
+	err := k.conn.NewSelect().Model(&items).
+		ApplyQueryBuilder(k.whereKeys(req.values)).
 		Scan(ctx)
-	result := make(map[string]*T, len(items))
+	if err != nil {
+		return nil, err
+	}
 	for i := range items {
 		item := &items[i]
-		result[k.getKey(item)] = item
+		key, err := k.getKey(item)
+		if err != nil {
+			return nil, err
+		}
+		for _, requested := range req.byKey[key] {
+			result[requested] = item
+		}
 	}
-	return result, err
+	for _, requested := range req.byKey {
+		for _, key := range requested {
+			if result[key] == nil {
+				batch.Add(key, k.ErrNotFound)
+			}
+		}
+	}
+	return result, batch.ErrOrNil()
 }
 
 func (k *keyValueStore[T]) GetAll(ctx context.Context) (map[string]*T, error) {
 	var items []T
-	err := k.db.NewSelect().Model(&items).Scan(ctx)
+	err := k.conn.NewSelect().Model(&items).Scan(ctx)
 	result := make(map[string]*T, len(items))
 	for i := range items {
 		item := &items[i]
-		result[k.getKey(item)] = item
+		key, err := k.getKey(item)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = item
 	}
 	return result, err
 }
 
 func (k *keyValueStore[T]) SetOne(ctx context.Context, key string, value *T) error {
-	if key == "" {
-		return k.ErrEmptyKey
+	if err := k.setKey(value, key); err != nil {
+		return err
 	}
-	k.setKey(value, key)
-	return k.setRequest(ctx, value)
+	return k.setRequest(ctx, k.touch(value, nil))
 }
 
 func (k *keyValueStore[T]) SetMany(ctx context.Context, items map[string]*T) error {
 	values := make([]T, 0, len(items))
+	batch := &BatchError{}
 	for key, val := range items {
-		if key == "" {
+		if err := k.setKey(val, key); err != nil {
+			batch.Add(key, err)
 			continue
 		}
-		k.setKey(val, key)
-		values = append(values, *val)
+		values = append(values, *k.touch(val, nil))
 	}
 	if len(values) == 0 {
-		return nil
+		return batch.ErrOrNil()
 	}
-	return k.setRequest(ctx, &values)
+	if err := k.setRequest(ctx, &values); err != nil {
+		return err
+	}
+	return batch.ErrOrNil()
 }
 
 func (k *keyValueStore[T]) setRequest(ctx context.Context, model any) error {
-	query := k.db.NewInsert().Model(model)
+	query := k.conn.NewInsert().Model(model)
 	k.handleInsertConflict(query)
 	_, err := query.Exec(ctx)
 	return err
@@ -194,16 +458,23 @@ func (k *keyValueStore[T]) UpdateMany(ctx context.Context, keys []string, update
 	if len(keys) == 0 {
 		return nil
 	}
-	keys = slices.DeleteFunc(keys, func(e string) bool { return e == "" })
-	return k.db.RunInTx(ctx, k.txOptions, func(ctx context.Context, tx bun.Tx) error {
+	batch := &BatchError{}
+	req := k.parseKeys(keys, batch)
+	if len(req.values) == 0 {
+		return batch
+	}
+	err := k.runInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
 		var rows []*T
-		selectQuery := tx.NewSelect().Model(&rows).Where("? IN (?)", bun.Ident(k.spec.KeySQL), bun.In(keys))
+		selectQuery := tx.NewSelect().Model(&rows).ApplyQueryBuilder(k.whereKeys(req.values))
 		k.handleLocking(selectQuery)
 		err := selectQuery.Scan(ctx)
 		if err != nil {
 			return err
 		}
-		initialRows := k.makeRowMap(keys, rows)
+		initialRows, err := k.makeRowMap(req, rows)
+		if err != nil {
+			return err
+		}
 		updatedRows := make([]*T, 0, len(initialRows))
 		for key, row := range initialRows {
 			newRow, err := update(key, row)
@@ -213,60 +484,225 @@ func (k *keyValueStore[T]) UpdateMany(ctx context.Context, keys []string, update
 			if newRow == nil {
 				continue
 			}
-			k.setKey(newRow, key)
-			updatedRows = append(updatedRows, newRow)
-		}
-		if len(updatedRows) == 0 {
-			return nil
+			if err := k.setKey(newRow, key); err != nil {
+				return err
+			}
+			updatedRows = append(updatedRows, k.touch(newRow, row))
 		}
-		insertQuery := tx.NewInsert().Model(&updatedRows)
-		k.handleInsertConflict(insertQuery)
-		_, err = insertQuery.Exec(ctx)
+		return k.upsertRows(ctx, tx, updatedRows)
+	})
+	if err != nil {
 		return err
+	}
+	return batch.ErrOrNil()
+}
+
+func (k *keyValueStore[T]) UpdateWhere(ctx context.Context, filter *FilterSpec, update func(string, *T) (*T, error)) (int, error) {
+	qb, err := libbun.BuilderForFilter(filter, k.spec, k.conn.Dialect().Name())
+	if err != nil {
+		return 0, errors.Join(k.ErrInvalidFilter, err)
+	}
+	var count int
+	err = k.runInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
+		var rows []*T
+		selectQuery := tx.NewSelect().Model(&rows).ApplyQueryBuilder(qb)
+		k.handleLocking(selectQuery)
+		if err := selectQuery.Scan(ctx); err != nil {
+			return err
+		}
+		updatedRows := make([]*T, 0, len(rows))
+		for _, row := range rows {
+			key, err := k.getKey(row)
+			if err != nil {
+				return err
+			}
+			newRow, err := update(key, row)
+			if err != nil {
+				return err
+			}
+			if newRow == nil {
+				continue
+			}
+			if err := k.setKey(newRow, key); err != nil {
+				return err
+			}
+			updatedRows = append(updatedRows, k.touch(newRow, row))
+		}
+		count = len(updatedRows)
+		return k.upsertRows(ctx, tx, updatedRows)
 	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
-func (k *keyValueStore[T]) makeRowMap(keys []string, rows []*T) map[string]*T {
-	result := make(map[string]*T, len(keys))
-	for _, key := range keys {
-		result[key] = nil
+func (k *keyValueStore[T]) upsertRows(ctx context.Context, tx bun.Tx, rows []*T) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	insertQuery := tx.NewInsert().Model(&rows)
+	k.handleInsertConflict(insertQuery)
+	_, err := insertQuery.Exec(ctx)
+	return err
+}
+
+func (k *keyValueStore[T]) makeRowMap(req requestedKeys, rows []*T) (map[string]*T, error) {
+	result := make(map[string]*T, len(req.byKey))
+	for _, requested := range req.byKey {
+		for _, key := range requested {
+			result[key] = nil
+		}
 	}
 	for _, row := range rows {
-		key := k.getKey(row)
-		result[key] = row
+		key, err := k.getKey(row)
+		if err != nil {
+			return nil, err
+		}
+		for _, requested := range req.byKey[key] {
+			result[requested] = row
+		}
 	}
-	return result
+	return result, nil
 }
 
+// Delete removes the given keys. Keys that can't be decoded are ignored, as
+// they can't match any row.
 func (k *keyValueStore[T]) Delete(ctx context.Context, keys ...string) error {
-	_, err := k.db.NewDelete().Table(k.spec.TableName).
-		Where("? IN (?)", bun.Ident(k.spec.KeySQL), bun.In(keys)).
+	req := k.parseKeys(keys, &BatchError{})
+	if len(req.values) == 0 {
+		return nil
+	}
+	_, err := k.delete(ctx, k.whereKeys(req.values))
+	return err
+}
+
+// delete removes the rows selected by qb, or marks them as deleted at the
+// time of the store's clock when the model has a soft_delete field.
+func (k *keyValueStore[T]) delete(ctx context.Context, qb func(bun.QueryBuilder) bun.QueryBuilder) (sql.Result, error) {
+	if k.spec.SoftDeleteColumn == "" {
+		return k.conn.NewDelete().Model((*T)(nil)).ApplyQueryBuilder(qb).Exec(ctx)
+	}
+	// The field is set on a zero value so that bun converts the time to the
+	// type of the field, as it does for its own soft deletes.
+	table := k.db.Table(reflect.TypeFor[T]())
+	var zero T
+	field := table.SoftDeleteField.Value(reflect.ValueOf(&zero).Elem())
+	if err := table.UpdateSoftDeleteField(field, k.now()); err != nil {
+		return nil, err
+	}
+	return k.conn.NewUpdate().Model((*T)(nil)).
+		Set("? = ?", bun.Ident(k.spec.SoftDeleteColumn), field.Interface()).
+		ApplyQueryBuilder(qb).
+		Exec(ctx)
+}
+
+func (k *keyValueStore[T]) Restore(ctx context.Context, keys ...string) error {
+	if k.spec.SoftDeleteColumn == "" {
+		return errNoSoftDelete
+	}
+	req := k.parseKeys(keys, &BatchError{})
+	if len(req.values) == 0 {
+		return nil
+	}
+	_, err := k.conn.NewUpdate().Model((*T)(nil)).
+		Set("? = NULL", bun.Ident(k.spec.SoftDeleteColumn)).
+		ApplyQueryBuilder(k.whereKeys(req.values)).
+		WhereDeleted().
 		Exec(ctx)
 	return err
 }
 
-func (k *keyValueStore[T]) Reset(ctx context.Context) error {
-	err := k.db.ResetModel(ctx, (*T)(nil))
+func (k *keyValueStore[T]) Purge(ctx context.Context, keys ...string) error {
+	if k.spec.SoftDeleteColumn == "" {
+		return errNoSoftDelete
+	}
+	req := k.parseKeys(keys, &BatchError{})
+	if len(req.values) == 0 {
+		return nil
+	}
+	_, err := k.conn.NewDelete().Model((*T)(nil)).
+		ApplyQueryBuilder(k.whereKeys(req.values)).
+		WhereDeleted().
+		ForceDelete().
+		Exec(ctx)
 	return err
 }
 
+func (k *keyValueStore[T]) DeleteWhere(ctx context.Context, filter *FilterSpec) (int, error) {
+	qb, err := libbun.BuilderForFilter(filter, k.spec, k.conn.Dialect().Name())
+	if err != nil {
+		return 0, errors.Join(k.ErrInvalidFilter, err)
+	}
+	res, err := k.delete(ctx, qb)
+	if err != nil {
+		return 0, err
+	}
+	count, err := res.RowsAffected()
+	return int(count), err
+}
+
+func (k *keyValueStore[T]) EnsureSchema(ctx context.Context, opts ...*SchemaOptions) error {
+	return ensureSchema(ctx, k.db, k.conn, (*T)(nil), k.spec, opts...)
+}
+
+// Reset drops the table and creates it again from the model, like
+// bun.DB.ResetModel, along with its indexes and full-text objects. With
+// databases whose schema can't be inspected, full-text columns aren't indexed.
+func (k *keyValueStore[T]) Reset(ctx context.Context) error {
+	if !canInspect(k.conn) {
+		return resetModel(ctx, k.conn, (*T)(nil), k.spec)
+	}
+	_, err := k.conn.NewDropTable().Model((*T)(nil)).IfExists().Cascade().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if len(k.spec.FullTextColumns) > 0 && k.conn.Dialect().Name() == dialect.SQLite {
+		_, err = k.conn.NewDropTable().Table(k.spec.FullTextTable()).IfExists().Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return k.EnsureSchema(ctx)
+}
+
 func (k *keyValueStore[T]) handleInsertConflict(query *bun.InsertQuery) {
-	if k.db.HasFeature(feature.InsertOnConflict) {
-		query.On("CONFLICT (?) DO UPDATE", bun.Ident(k.spec.KeySQL))
+	if k.conn.Dialect().Features().Has(feature.InsertOnConflict) {
+		keys := make([]string, len(k.spec.KeyColumns))
+		args := make([]any, len(k.spec.KeyColumns))
+		for i, column := range k.spec.KeyColumns {
+			keys[i] = "?"
+			args[i] = bun.Ident(column)
+		}
+		query.On("CONFLICT ("+strings.Join(keys, ", ")+") DO UPDATE", args...)
+		// Soft-deleted rows are overwritten as if they were absent, so their
+		// creation time and version start over.
+		created, version := "?TableAlias.?0", "?TableAlias.?0 + 1"
+		if k.spec.SoftDeleteColumn != "" {
+			created = "CASE WHEN ?TableAlias.?1 IS NULL THEN ?TableAlias.?0 ELSE EXCLUDED.?0 END"
+			version = "CASE WHEN ?TableAlias.?1 IS NULL THEN ?TableAlias.?0 + 1 ELSE EXCLUDED.?0 END"
+		}
+		softDelete := bun.Ident(k.spec.SoftDeleteColumn)
 		for _, column := range k.spec.ColumnNames {
-			if column == k.spec.KeySQL {
+			switch {
+			case slices.Contains(k.spec.KeyColumns, column):
 				continue
+			case column == k.spec.CreatedAtColumn:
+				query.Set("?0 = "+created, bun.Ident(column), softDelete)
+			case column == k.spec.VersionColumn:
+				query.Set("?0 = "+version, bun.Ident(column), softDelete)
+			default:
+				query.Set("?0 = EXCLUDED.?0", bun.Ident(column))
 			}
-			query.Set("?0 = EXCLUDED.?0", bun.Ident(column))
 		}
 	}
-	if k.db.HasFeature(feature.InsertOnDuplicateKey) {
+	if k.conn.Dialect().Features().Has(feature.InsertOnDuplicateKey) {
 		query.On("DUPLICATE KEY UPDATE")
 	}
 }
 
 func (k *keyValueStore[T]) handleLocking(query *bun.SelectQuery) {
-	switch k.db.Dialect().Name() {
+	switch k.conn.Dialect().Name() {
 	case dialect.SQLite:
 		return
 	case dialect.PG, dialect.MySQL:
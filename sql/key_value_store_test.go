@@ -23,9 +23,13 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	. "github.com/ArnaudCalmettes/store"
+	. "github.com/ArnaudCalmettes/store/test"
 	. "github.com/ArnaudCalmettes/store/test/helpers"
+	"github.com/google/uuid"
+	"github.com/rubenv/pgtest"
 	"github.com/uptrace/bun"
 )
 
@@ -48,11 +52,11 @@ func TestSQLNewKeyValueStore(t *testing.T) {
 			}),
 		)
 	})
-	t.Run("not a string key", func(t *testing.T) {
+	t.Run("unsupported key type", func(t *testing.T) {
 		type Model struct {
 			bun.BaseModel `bun:"table:models"`
 
-			ID   int `bun:",pk"`
+			ID   float64 `bun:",pk"`
 			Name string
 		}
 		Expect(t,
@@ -76,6 +80,54 @@ func TestSQLNewKeyValueStore(t *testing.T) {
 	})
 }
 
+func TestSQLTryNewKeyValueStore(t *testing.T) {
+	var db *bun.DB
+	t.Run("not a struct", func(t *testing.T) {
+		_, err := TryNewKeyValueStore[int](db)
+		var modelErr *ModelError
+		Expect(t,
+			IsError(ErrNotAStruct, err),
+			Equal(true, errors.As(err, &modelErr)),
+		)
+	})
+	t.Run("every problem is reported", func(t *testing.T) {
+		type Model struct {
+			ID   string
+			Name string
+		}
+		_, err := TryNewKeyValueStore[Model](db)
+		var modelErr *ModelError
+		Require(t, Equal(true, errors.As(err, &modelErr)))
+		Expect(t,
+			Equal("sql.Model", modelErr.Model),
+			IsError(ErrMissingTableName, err),
+			IsError(ErrNoPrimaryKey, err),
+			Equal(
+				"invalid model sql.Model: missing table name; primary key not configured",
+				err.Error(),
+			),
+		)
+	})
+	t.Run("unsupported key type", func(t *testing.T) {
+		type Model struct {
+			ID   float64 `bun:",pk"`
+			Name string
+		}
+		_, err := TryNewKeyValueStoreWithProxy[Item, Model](db, nil, nil)
+		Expect(t,
+			IsError(ErrMissingTableName, err),
+			IsError(ErrUnsupportedKeyType, err),
+		)
+	})
+	t.Run("nominal", func(t *testing.T) {
+		store, err := TryNewKeyValueStore[Item](db)
+		Expect(t,
+			NoError(err),
+			Equal(true, store != nil),
+		)
+	})
+}
+
 type Item struct {
 	bun.BaseModel `bun:"table:entries"`
 
@@ -130,3 +182,363 @@ func TestSQLKeyValueStoreReset(t *testing.T) {
 		Equal(map[string]*Item{}, all),
 	)
 }
+
+type intKeyItem struct {
+	bun.BaseModel `bun:"table:int_key_items"`
+
+	ID   int64 `bun:",pk"`
+	Name string
+}
+
+type uuidKeyItem struct {
+	bun.BaseModel `bun:"table:uuid_key_items"`
+
+	ID   uuid.UUID `bun:",pk,type:uuid"`
+	Name string
+}
+
+type membership struct {
+	bun.BaseModel `bun:"table:memberships"`
+
+	UserID  int64  `bun:",pk"`
+	GroupID string `bun:",pk"`
+	Role    string
+}
+
+func testTypedKeys(t *testing.T, db *bun.DB) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	t.Run("int64", func(t *testing.T) {
+		store := NewKeyValueStore[intKeyItem](db)
+		Require(t, NoError(store.Reset(ctx)))
+		Require(t, NoError(store.SetMany(ctx, map[string]*intKeyItem{
+			"1":  {Name: "one"},
+			"42": {Name: "forty-two"},
+		})))
+
+		item, err := store.GetOne(ctx, "42")
+		Expect(t,
+			NoError(err),
+			Equal(&intKeyItem{ID: 42, Name: "forty-two"}, item),
+		)
+		_, err = store.GetOne(ctx, "forty-two")
+		Expect(t, IsError(ErrInvalidKey, err))
+
+		items, err := store.GetMany(ctx, []string{"01", "2", "x"})
+		Expect(t,
+			IsBatchError(map[string]error{
+				"2": ErrNotFound,
+				"x": ErrInvalidKey,
+			}, err),
+			Equal(map[string]*intKeyItem{"01": {ID: 1, Name: "one"}}, items),
+		)
+
+		err = store.UpdateOne(ctx, "1", func(key string, item *intKeyItem) (*intKeyItem, error) {
+			item.Name = "uno"
+			return item, nil
+		})
+		Expect(t, NoError(err))
+
+		Require(t, NoError(store.Delete(ctx, "42", "x")))
+		all, err := store.GetAll(ctx)
+		Expect(t,
+			NoError(err),
+			Equal(map[string]*intKeyItem{"1": {ID: 1, Name: "uno"}}, all),
+		)
+	})
+	t.Run("uuid", func(t *testing.T) {
+		store := NewKeyValueStore[uuidKeyItem](db)
+		Require(t, NoError(store.Reset(ctx)))
+		id := uuid.New()
+		Require(t, NoError(store.SetOne(ctx, id.String(), &uuidKeyItem{Name: "one"})))
+
+		all, err := store.GetAll(ctx)
+		Expect(t,
+			NoError(err),
+			Equal(map[string]*uuidKeyItem{id.String(): {ID: id, Name: "one"}}, all),
+		)
+		err = store.SetOne(ctx, "not-a-uuid", &uuidKeyItem{})
+		Expect(t, IsError(ErrInvalidKey, err))
+	})
+	t.Run("composite", func(t *testing.T) {
+		store := NewKeyValueStore[membership](db)
+		Require(t, NoError(store.Reset(ctx)))
+		err := store.SetMany(ctx, map[string]*membership{
+			"1:admins": {Role: "owner"},
+			"1:users":  {Role: "member"},
+			`2:a\:b`:   {Role: "member"},
+			"2":        {Role: "invalid"},
+			"x:users":  {Role: "invalid"},
+		})
+		Expect(t,
+			IsBatchError(map[string]error{
+				"2":       ErrInvalidKey,
+				"x:users": ErrInvalidKey,
+			}, err),
+		)
+
+		items, err := store.GetMany(ctx, []string{"1:users", `2:a\:b`, "2:users"})
+		Expect(t,
+			IsBatchError(map[string]error{"2:users": ErrNotFound}, err),
+			Equal(map[string]*membership{
+				"1:users": {UserID: 1, GroupID: "users", Role: "member"},
+				`2:a\:b`:  {UserID: 2, GroupID: "a:b", Role: "member"},
+			}, items),
+		)
+
+		err = store.UpdateMany(ctx, []string{"1:users", "2:users"},
+			func(key string, item *membership) (*membership, error) {
+				if item == nil {
+					item = &membership{}
+				}
+				item.Role = "admin"
+				return item, nil
+			},
+		)
+		Expect(t, NoError(err))
+
+		Require(t, NoError(store.Delete(ctx, "1:admins", `2:a\:b`)))
+		all, err := store.GetAll(ctx)
+		Expect(t,
+			NoError(err),
+			Equal(map[string]*membership{
+				"1:users": {UserID: 1, GroupID: "users", Role: "admin"},
+				"2:users": {UserID: 2, GroupID: "users", Role: "admin"},
+			}, all),
+		)
+	})
+}
+
+func TestSQLiteTypedKeys(t *testing.T) {
+	testTypedKeys(t, newSQLite(t))
+}
+
+func TestPGTypedKeys(t *testing.T) {
+	pg, err := pgtest.Start()
+	Require(t,
+		NoError(err),
+	)
+	t.Cleanup(func() { pg.Stop() })
+	testTypedKeys(t, newPostgres(t, pg))
+}
+
+type softDeleteItem struct {
+	bun.BaseModel `bun:"table:soft_delete_items"`
+
+	ID        string `bun:",pk"`
+	Name      string
+	DeletedAt time.Time `bun:",soft_delete,nullzero"`
+}
+
+func testSoftDelete(t *testing.T, db *bun.DB) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	store := NewKeyValueStore[softDeleteItem](db)
+	Require(t, NoError(store.Reset(ctx)))
+	Require(t, NoError(store.SetMany(ctx, map[string]*softDeleteItem{
+		"one":   {Name: "one"},
+		"two":   {Name: "two"},
+		"three": {Name: "three"},
+	})))
+	Require(t, NoError(store.Delete(ctx, "one", "two")))
+
+	var deleted []softDeleteItem
+	err := db.NewSelect().Model(&deleted).WhereDeleted().Order("id").Scan(ctx)
+	Expect(t,
+		NoError(err),
+		SliceHasLength(2, deleted),
+	)
+	for _, item := range deleted {
+		Expect(t, Equal(false, item.DeletedAt.IsZero()))
+	}
+
+	_, err = store.GetOne(ctx, "one")
+	Expect(t, IsError(ErrNotFound, err))
+	items, err := store.List(ctx)
+	Expect(t,
+		NoError(err),
+		Equal([]*softDeleteItem{{ID: "three", Name: "three"}}, items),
+	)
+
+	Require(t, NoError(store.Restore(ctx, "one", "three")))
+	item, err := store.GetOne(ctx, "one")
+	Expect(t,
+		NoError(err),
+		Equal(&softDeleteItem{ID: "one", Name: "one"}, item),
+	)
+
+	// Only soft-deleted items are purged.
+	Require(t, NoError(store.Purge(ctx, "two", "three")))
+	count, err := db.NewSelect().Model((*softDeleteItem)(nil)).WhereAllWithDeleted().Count(ctx)
+	Expect(t,
+		NoError(err),
+		Equal(2, count),
+	)
+	Require(t, NoError(store.Restore(ctx, "two")))
+	_, err = store.GetOne(ctx, "two")
+	Expect(t, IsError(ErrNotFound, err))
+
+	err = NewKeyValueStore[Item](db).Purge(ctx, "one")
+	Expect(t, IsError(errNoSoftDelete, err))
+}
+
+type softDeleteAutoItem struct {
+	bun.BaseModel `bun:"table:soft_delete_auto_items"`
+
+	ID        string `bun:",pk"`
+	Name      string
+	CreatedAt time.Time `store:"created_at"`
+	Version   int       `store:"version"`
+	DeletedAt time.Time `bun:",soft_delete,nullzero"`
+}
+
+func testSoftDeleteAutoFields(t *testing.T, db *bun.DB) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	store := NewKeyValueStore[softDeleteAutoItem](db)
+	Require(t, NoError(store.Reset(ctx)))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.SetClock(func() time.Time { return now })
+
+	Require(t,
+		NoError(store.SetOne(ctx, "one", &softDeleteAutoItem{Name: "first"})),
+		NoError(store.SetOne(ctx, "one", &softDeleteAutoItem{Name: "second"})),
+	)
+	item, err := store.GetOne(ctx, "one")
+	Expect(t,
+		NoError(err),
+		Equal(&softDeleteAutoItem{ID: "one", Name: "second", CreatedAt: now, Version: 2}, item),
+	)
+
+	deletedAt := now.Add(time.Hour)
+	store.SetClock(func() time.Time { return deletedAt })
+	Require(t, NoError(store.Delete(ctx, "one")))
+	var deleted softDeleteAutoItem
+	err = db.NewSelect().Model(&deleted).WhereDeleted().Where("id = ?", "one").Scan(ctx)
+	Expect(t,
+		NoError(err),
+		Equalf(true, deleted.DeletedAt.Equal(deletedAt), "deleted_at should come from the store's clock, got %v", deleted.DeletedAt),
+	)
+
+	recreatedAt := now.Add(2 * time.Hour)
+	store.SetClock(func() time.Time { return recreatedAt })
+	Require(t, NoError(store.SetOne(ctx, "one", &softDeleteAutoItem{Name: "third"})))
+	item, err = store.GetOne(ctx, "one")
+	Expect(t,
+		NoError(err),
+		Equalf(&softDeleteAutoItem{ID: "one", Name: "third", CreatedAt: recreatedAt, Version: 1}, item,
+			"soft-deleted items should be overwritten as if they were absent",
+		),
+	)
+}
+
+func TestSQLiteSoftDelete(t *testing.T) {
+	testSoftDelete(t, newSQLite(t))
+}
+
+func TestSQLiteSoftDeleteAutoFields(t *testing.T) {
+	testSoftDeleteAutoFields(t, newSQLite(t))
+}
+
+func TestPGSoftDeleteAutoFields(t *testing.T) {
+	pg, err := pgtest.Start()
+	Require(t,
+		NoError(err),
+	)
+	t.Cleanup(func() { pg.Stop() })
+	testSoftDeleteAutoFields(t, newPostgres(t, pg))
+}
+
+func TestPGSoftDelete(t *testing.T) {
+	pg, err := pgtest.Start()
+	Require(t,
+		NoError(err),
+	)
+	t.Cleanup(func() { pg.Stop() })
+	testSoftDelete(t, newPostgres(t, pg))
+}
+
+func testWithTx(t *testing.T, db *bun.DB) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	store := NewKeyValueStore[Item](db)
+	Require(t, NoError(store.Reset(ctx)))
+	errRollback := errors.New("rollback")
+
+	t.Run("rollback", func(t *testing.T) {
+		err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			txStore := store.WithTx(tx)
+			if err := txStore.SetOne(ctx, "one", &Item{Name: "one"}); err != nil {
+				return err
+			}
+			item, err := txStore.GetOne(ctx, "one")
+			Expect(t,
+				NoError(err),
+				Equal(&Item{ID: "one", Name: "one"}, item),
+			)
+			return errRollback
+		})
+		Require(t, IsError(errRollback, err))
+		_, err = store.GetOne(ctx, "one")
+		Expect(t, IsError(ErrNotFound, err))
+	})
+	t.Run("savepoint", func(t *testing.T) {
+		err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			txStore := store.WithTx(tx)
+			if err := txStore.SetOne(ctx, "one", &Item{Name: "one"}); err != nil {
+				return err
+			}
+			err := txStore.UpdateMany(ctx, []string{"one", "two"},
+				func(key string, item *Item) (*Item, error) {
+					if item == nil {
+						return nil, errRollback
+					}
+					return &Item{Name: "updated"}, nil
+				},
+			)
+			Expect(t, IsError(errRollback, err))
+			return txStore.UpdateOne(ctx, "two", func(key string, _ *Item) (*Item, error) {
+				return &Item{Name: key}, nil
+			})
+		})
+		Require(t, NoError(err))
+		all, err := store.GetAll(ctx)
+		Expect(t,
+			NoError(err),
+			Equal(map[string]*Item{
+				"one": {ID: "one", Name: "one"},
+				"two": {ID: "two", Name: "two"},
+			}, all),
+		)
+	})
+	t.Run("conflict", func(t *testing.T) {
+		calls := 0
+		err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			return store.WithTx(tx).UpdateOne(ctx, "one", func(string, *Item) (*Item, error) {
+				calls++
+				return nil, sqlStateError("40001")
+			})
+		})
+		Expect(t,
+			Equalf(error(sqlStateError("40001")), err, "conflicts should be returned unchanged"),
+			Equalf(1, calls, "savepoints shouldn't be retried"),
+		)
+	})
+}
+
+func TestSQLiteWithTx(t *testing.T) {
+	testWithTx(t, newSQLite(t))
+}
+
+func TestPGWithTx(t *testing.T) {
+	pg, err := pgtest.Start()
+	Require(t,
+		NoError(err),
+	)
+	t.Cleanup(func() { pg.Stop() })
+	testWithTx(t, newPostgres(t, pg))
+}
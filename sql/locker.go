@@ -0,0 +1,181 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+
+	//lint:ignore ST1001 common definitions
+	. "github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/lock"
+)
+
+type Locker interface {
+	lock.Locker
+	Resetter
+}
+
+func NewLocker(db *bun.DB) Locker {
+	return &locker{
+		db: db,
+		txOptions: &sql.TxOptions{
+			Isolation: sql.LevelSerializable,
+		},
+	}
+}
+
+// leaseRow is a row of the leases table. Rows are never deleted so that
+// fencing tokens keep increasing after a lease is released.
+type leaseRow struct {
+	bun.BaseModel `bun:"table:leases"`
+
+	Name      string `bun:",pk"`
+	Owner     string `bun:",notnull"`
+	Token     int64  `bun:",notnull"`
+	ExpiresAt int64  `bun:",notnull"`
+}
+
+type locker struct {
+	db        *bun.DB
+	txOptions *sql.TxOptions
+}
+
+func (l *locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*lock.Lease, error) {
+	if name == "" {
+		return nil, lock.ErrEmptyName
+	}
+	if ttl <= 0 {
+		return nil, lock.ErrInvalidTTL
+	}
+	now, err := l.now()
+	if err != nil {
+		return nil, err
+	}
+	lease := &lock.Lease{Name: name, Owner: uuid.NewString()}
+	err = l.db.RunInTx(ctx, l.txOptions, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewInsert().Model(&leaseRow{Name: name}).Ignore().Exec(ctx)
+		if err != nil {
+			return err
+		}
+		res, err := tx.NewUpdate().Model((*leaseRow)(nil)).
+			Set("owner = ?", lease.Owner).
+			Set("token = token + 1").
+			Set("expires_at = ? + ?", now, ttl.Milliseconds()).
+			Where("name = ?", name).
+			Where("expires_at <= ?", now).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return lock.ErrLocked
+		}
+		return tx.NewSelect().Model((*leaseRow)(nil)).
+			Column("token").
+			Where("name = ?", name).
+			Scan(ctx, &lease.Token)
+	})
+	if isConflict(err) {
+		// A concurrent transaction acquired or touched the lease first, which
+		// means that it isn't ours to take right now.
+		return nil, errors.Join(lock.ErrLocked, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+func (l *locker) Renew(ctx context.Context, lease *lock.Lease, ttl time.Duration) error {
+	if ttl <= 0 {
+		return lock.ErrInvalidTTL
+	}
+	now, err := l.now()
+	if err != nil {
+		return err
+	}
+	res, err := l.db.NewUpdate().Model((*leaseRow)(nil)).
+		Set("expires_at = ? + ?", now, ttl.Milliseconds()).
+		Where("name = ?", lease.Name).
+		Where("owner = ?", lease.Owner).
+		Where("expires_at > ?", now).
+		Exec(ctx)
+	return l.checkHeld(res, err)
+}
+
+func (l *locker) Release(ctx context.Context, lease *lock.Lease) error {
+	now, err := l.now()
+	if err != nil {
+		return err
+	}
+	res, err := l.db.NewUpdate().Model((*leaseRow)(nil)).
+		Set("owner = ''").
+		Set("expires_at = 0").
+		Where("name = ?", lease.Name).
+		Where("owner = ?", lease.Owner).
+		Where("expires_at > ?", now).
+		Exec(ctx)
+	return l.checkHeld(res, err)
+}
+
+// now returns an expression of the current time of the database, in
+// milliseconds since the epoch. Expiration times are computed by the database
+// rather than by the clients, whose clocks may disagree.
+func (l *locker) now() (bun.Safe, error) {
+	switch l.db.Dialect().Name() {
+	case dialect.SQLite:
+		return "CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)", nil
+	case dialect.PG:
+		return "(extract(epoch FROM clock_timestamp()) * 1000)::bigint", nil
+	case dialect.MySQL:
+		return "CAST(UNIX_TIMESTAMP(NOW(3)) * 1000 AS SIGNED)", nil
+	}
+	return "", fmt.Errorf("%w: %s", errUnsupportedDialect, l.db.Dialect().Name())
+}
+
+func (l *locker) checkHeld(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return lock.ErrNotHeld
+	}
+	return nil
+}
+
+func (l *locker) Reset(ctx context.Context) error {
+	return l.db.ResetModel(ctx, (*leaseRow)(nil))
+}
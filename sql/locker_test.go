@@ -0,0 +1,111 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ArnaudCalmettes/store/lock"
+	. "github.com/ArnaudCalmettes/store/test"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+	"github.com/rubenv/pgtest"
+	"github.com/uptrace/bun"
+)
+
+func TestSQLiteLocker(t *testing.T) {
+	newLocker := func(t *testing.T) lock.Locker {
+		locker := NewLocker(newSQLite(t))
+		err := locker.Reset(context.Background())
+		Require(t,
+			NoError(err),
+		)
+		return locker
+	}
+	TestLocker(t, newLocker, time.Sleep)
+}
+
+func TestPGLocker(t *testing.T) {
+	pg, err := pgtest.Start()
+	Require(t,
+		NoError(err),
+	)
+	t.Cleanup(func() { pg.Stop() })
+
+	newLocker := func(t *testing.T) lock.Locker {
+		locker := NewLocker(newPostgres(t, pg))
+		err := locker.Reset(context.Background())
+		Require(t,
+			NoError(err),
+		)
+		return locker
+	}
+	TestLocker(t, newLocker, time.Sleep)
+}
+
+func testConcurrentAcquire(t *testing.T, db *bun.DB) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+	locker := NewLocker(db)
+	Require(t,
+		NoError(locker.Reset(ctx)),
+	)
+
+	const acquirers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, acquirers)
+	for i := range acquirers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = locker.Acquire(ctx, "concurrent", time.Minute)
+		}()
+	}
+	wg.Wait()
+
+	var acquired int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			acquired++
+		case !errors.Is(err, lock.ErrLocked):
+			t.Errorf("expected %v, got: %v", lock.ErrLocked, err)
+		}
+	}
+	Expect(t,
+		Equal(1, acquired),
+	)
+}
+
+func TestSQLiteConcurrentAcquire(t *testing.T) {
+	testConcurrentAcquire(t, newSQLite(t))
+}
+
+func TestPGConcurrentAcquire(t *testing.T) {
+	pg, err := pgtest.Start()
+	Require(t,
+		NoError(err),
+	)
+	t.Cleanup(func() { pg.Stop() })
+	testConcurrentAcquire(t, newPostgres(t, pg))
+}
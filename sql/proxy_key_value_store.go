@@ -20,18 +20,68 @@
 package sql
 
 import (
-	"github.com/ArnaudCalmettes/store/proxy"
 	"github.com/uptrace/bun"
+
+	//lint:ignore ST1001 common definitions
+	. "github.com/ArnaudCalmettes/store"
+	"github.com/ArnaudCalmettes/store/proxy"
 )
 
+// NewKeyValueStoreWithProxy is like TryNewKeyValueStoreWithProxy, but panics
+// if P is not a valid model.
 func NewKeyValueStoreWithProxy[T, P any](
 	db *bun.DB,
 	toProxy func(*T) *P,
 	fromProxy func(*P) *T,
+	opts ...*StoreOptions,
 ) KeyValueStore[T] {
-	return proxy.NewKeyValueStoreWithProxy[T, P](
-		NewKeyValueStore[P](db),
-		toProxy,
-		fromProxy,
-	)
+	k, err := TryNewKeyValueStoreWithProxy(db, toProxy, fromProxy, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// TryNewKeyValueStoreWithProxy returns a KeyValueStore of T backed by the
+// table of the bun model P. If P can't be used as a model, it returns a
+// *ModelError.
+func TryNewKeyValueStoreWithProxy[T, P any](
+	db *bun.DB,
+	toProxy func(*T) *P,
+	fromProxy func(*P) *T,
+	opts ...*StoreOptions,
+) (KeyValueStore[T], error) {
+	inner, err := TryNewKeyValueStore[P](db, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newProxyKeyValueStore(inner, toProxy, fromProxy), nil
+}
+
+func newProxyKeyValueStore[T, P any](
+	inner KeyValueStore[P],
+	toProxy func(*T) *P,
+	fromProxy func(*P) *T,
+) KeyValueStore[T] {
+	return proxyKeyValueStore[T]{
+		KeyValueStore: proxy.NewKeyValueStoreWithProxy[T, P](inner, toProxy, fromProxy),
+		SchemaEnsurer: inner,
+		SoftDeleter:   inner,
+		ClockSetter:   inner,
+		withTx: func(tx bun.IDB) KeyValueStore[T] {
+			return newProxyKeyValueStore(inner.WithTx(tx), toProxy, fromProxy)
+		},
+	}
+}
+
+type proxyKeyValueStore[T any] struct {
+	proxy.KeyValueStore[T]
+	SchemaEnsurer
+	SoftDeleter
+	ClockSetter
+	withTx func(bun.IDB) KeyValueStore[T]
+}
+
+func (p proxyKeyValueStore[T]) WithTx(tx bun.IDB) KeyValueStore[T] {
+	return p.withTx(tx)
 }
@@ -27,7 +27,6 @@ import (
 	"fmt"
 	"testing"
 
-	. "github.com/ArnaudCalmettes/store"
 	. "github.com/ArnaudCalmettes/store/test"
 	. "github.com/ArnaudCalmettes/store/test/helpers"
 	"github.com/rubenv/pgtest"
@@ -58,16 +57,10 @@ func fromEntryProxy(p *EntryProxy) *Entry {
 	return &p.Entry
 }
 
-func TestSQLiteKeyValueStore(t *testing.T) {
-	newStore := func(t *testing.T) BaseKeyValueStore[Entry] {
-		db := newSQLite(t)
-		err := db.ResetModel(context.Background(), (*EntryProxy)(nil))
-		Require(t,
-			NoError(err),
-		)
-		return NewKeyValueStoreWithProxy[Entry, EntryProxy](db, toEntryProxy, fromEntryProxy)
-	}
-	TestBaseKeyValueStore(t, newStore)
+func TestProxyKeyValueStore(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *bun.DB) {
+		TestBaseKeyValueStore(t, proxyFactory(db, toEntryProxy, fromEntryProxy))
+	})
 }
 
 type PersonProxy struct {
@@ -80,61 +73,174 @@ type PersonProxy struct {
 }
 
 func toPersonProxy(p *Person) *PersonProxy {
+	if p == nil {
+		return nil
+	}
 	return &PersonProxy{
 		ID: p.ID, Name: p.Name, Age: p.Age, Referent: p.Referent,
 	}
 }
 
 func fromPersonProxy(p *PersonProxy) *Person {
+	if p == nil {
+		return nil
+	}
 	return &Person{
 		ID: p.ID, Name: p.Name, Age: p.Age, Referent: p.Referent,
 	}
 }
 
-func TestSQLiteProxyKeyValueLister(t *testing.T) {
-	newStore := func(t *testing.T) TestListerInterface[Person] {
-		db := newSQLite(t)
-		err := db.ResetModel(context.Background(), (*PersonProxy)(nil))
-		Require(t,
-			NoError(err),
-		)
-		return NewKeyValueStoreWithProxy(db, toPersonProxy, fromPersonProxy)
+func TestProxyKeyValueLister(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *bun.DB) {
+		TestLister(t, proxyFactory(db, toPersonProxy, fromPersonProxy))
+	})
+}
+
+func TestProxyKeyValueWhereDeleter(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *bun.DB) {
+		TestWhereDeleter(t, proxyFactory(db, toPersonProxy, fromPersonProxy))
+	})
+}
+
+func TestProxyKeyValueWhereUpdater(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *bun.DB) {
+		TestWhereUpdater(t, proxyFactory(db, toPersonProxy, fromPersonProxy))
+	})
+}
+
+type DocumentProxy struct {
+	bun.BaseModel `bun:"table:documents,alias:d"`
+
+	ID string `bun:",pk"`
+	Document
+}
+
+func toDocumentProxy(d *Document) *DocumentProxy {
+	if d == nil {
+		return nil
 	}
-	TestLister(t, newStore)
+	return &DocumentProxy{Document: *d}
 }
 
-func TestPGKeyValueStore(t *testing.T) {
-	pg, err := pgtest.Start()
-	Require(t,
-		NoError(err),
-	)
-	t.Cleanup(func() { pg.Stop() })
+func fromDocumentProxy(p *DocumentProxy) *Document {
+	if p == nil {
+		return nil
+	}
+	return &p.Document
+}
 
-	newStore := func(t *testing.T) BaseKeyValueStore[Entry] {
-		db := newPostgres(t, pg)
-		err := db.ResetModel(context.Background(), (*EntryProxy)(nil))
-		Require(t, NoError(err))
-		return NewKeyValueStoreWithProxy(db, toEntryProxy, fromEntryProxy)
+func TestProxyAutoFields(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *bun.DB) {
+		TestAutoFields(t, proxyFactory(db, toDocumentProxy, fromDocumentProxy))
+	})
+}
+
+type ArticleProxy struct {
+	bun.BaseModel `bun:"table:articles,alias:a"`
+
+	ID string `bun:",pk"`
+	Article
+}
+
+func toArticleProxy(a *Article) *ArticleProxy {
+	if a == nil {
+		return nil
 	}
-	TestBaseKeyValueStore(t, newStore)
+	return &ArticleProxy{Article: *a}
 }
 
-func TestPGProxyKeyValueLister(t *testing.T) {
-	pg, err := pgtest.Start()
-	Require(t,
-		NoError(err),
-	)
+func fromArticleProxy(p *ArticleProxy) *Article {
+	if p == nil {
+		return nil
+	}
+	return &p.Article
+}
+
+func TestProxyFullText(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *bun.DB) {
+		TestFullText(t, proxyFactory(db, toArticleProxy, fromArticleProxy))
+	})
+}
+
+type ProductProxy struct {
+	bun.BaseModel `bun:"table:products,alias:p"`
+
+	ID string `bun:",pk"`
+	Product
+}
+
+func toProductProxy(p *Product) *ProductProxy {
+	if p == nil {
+		return nil
+	}
+	return &ProductProxy{Product: *p}
+}
+
+func fromProductProxy(p *ProductProxy) *Product {
+	if p == nil {
+		return nil
+	}
+	return &p.Product
+}
+
+func TestProxyKindFilters(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *bun.DB) {
+		TestKindFilters(t, proxyFactory(db, toProductProxy, fromProductProxy))
+	})
+}
 
-	t.Cleanup(func() { pg.Stop() })
-	newStore := func(t *testing.T) TestListerInterface[Person] {
-		db := newPostgres(t, pg)
-		err := db.ResetModel(context.Background(), (*PersonProxy)(nil))
+type TaggedProxy struct {
+	bun.BaseModel `bun:"table:tagged_items,alias:t"`
+
+	ID string `bun:",pk"`
+	Tagged
+}
+
+func toTaggedProxy(t *Tagged) *TaggedProxy {
+	if t == nil {
+		return nil
+	}
+	return &TaggedProxy{Tagged: *t}
+}
+
+func fromTaggedProxy(p *TaggedProxy) *Tagged {
+	if p == nil {
+		return nil
+	}
+	return &p.Tagged
+}
+
+func TestProxyCollectionFilters(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *bun.DB) {
+		TestCollectionFilters(t, proxyFactory(db, toTaggedProxy, fromTaggedProxy))
+	})
+}
+
+// proxyFactory returns a constructor of stores of T backed by the table of
+// the proxy P in db, which is reset for every new store.
+func proxyFactory[T, P any](db *bun.DB, toProxy func(*T) *P, fromProxy func(*P) *T) func(*testing.T) KeyValueStore[T] {
+	return func(t *testing.T) KeyValueStore[T] {
+		store := NewKeyValueStoreWithProxy(db, toProxy, fromProxy)
 		Require(t,
-			NoError(err),
+			NoError(store.Reset(context.Background())),
 		)
-		return NewKeyValueStoreWithProxy(db, toPersonProxy, fromPersonProxy)
+		return store
 	}
-	TestLister(t, newStore)
+}
+
+// forEachDB runs test against an SQLite database, then a PostgreSQL one.
+func forEachDB(t *testing.T, test func(*testing.T, *bun.DB)) {
+	t.Run("SQLite", func(t *testing.T) {
+		test(t, newSQLite(t))
+	})
+	t.Run("PG", func(t *testing.T) {
+		pg, err := pgtest.Start()
+		Require(t,
+			NoError(err),
+		)
+		t.Cleanup(func() { pg.Stop() })
+		test(t, newPostgres(t, pg))
+	})
 }
 
 func newSQLite(t *testing.T) *bun.DB {
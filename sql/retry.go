@@ -0,0 +1,104 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// RetryOptions configures how transactions are retried after serialization
+// failures and deadlocks. Each retry waits for a random duration up to a
+// backoff that starts at MinBackoff and doubles on each attempt, up to
+// MaxBackoff.
+//
+// Since the whole transaction is retried, the update callbacks given to
+// UpdateMany and UpdateWhere may be called more than once for the same key.
+type RetryOptions struct {
+	// MaxRetries is the number of retries before giving up. Zero means
+	// DefaultMaxRetries, and a negative value disables retries: conflicts
+	// then fail with ErrConflict on the first attempt.
+	MaxRetries int
+
+	// MinBackoff defaults to DefaultMinBackoff.
+	MinBackoff time.Duration
+
+	// MaxBackoff defaults to DefaultMaxBackoff.
+	MaxBackoff time.Duration
+}
+
+const (
+	DefaultMaxRetries = 5
+	DefaultMinBackoff = 5 * time.Millisecond
+	DefaultMaxBackoff = 500 * time.Millisecond
+)
+
+var defaultRetryOptions = RetryOptions{
+	MaxRetries: DefaultMaxRetries,
+	MinBackoff: DefaultMinBackoff,
+	MaxBackoff: DefaultMaxBackoff,
+}
+
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.MaxRetries == 0 {
+		o.MaxRetries = DefaultMaxRetries
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = DefaultMinBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = DefaultMaxBackoff
+	}
+	return o
+}
+
+// runInTx runs fn in a transaction, retrying it when it fails because of a
+// concurrent transaction. Once retries are exhausted, the last error is
+// returned along with ErrConflict.
+//
+// Views returned by WithTx run fn in a savepoint instead, once, and return
+// its error as is.
+func (k *keyValueStore[T]) runInTx(ctx context.Context, fn func(context.Context, bun.Tx) error) error {
+	if k.inTx {
+		return k.conn.RunInTx(ctx, k.txOptions, fn)
+	}
+	backoff := k.retry.MinBackoff
+	for attempt := 0; ; attempt++ {
+		err := k.conn.RunInTx(ctx, k.txOptions, fn)
+		if err == nil || !isConflict(err) {
+			return err
+		}
+		if attempt >= k.retry.MaxRetries {
+			return errors.Join(k.ErrConflict, err)
+		}
+		timer := time.NewTimer(time.Duration(rand.Int63n(int64(backoff) + 1)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(ctx.Err(), err)
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, k.retry.MaxBackoff)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package sql
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/ArnaudCalmettes/store"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+	"github.com/rubenv/pgtest"
+	"github.com/uptrace/bun"
+)
+
+func TestSQLiteUpdateRetries(t *testing.T) {
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	db := newSQLite(t)
+	db.ResetModel(ctx, (*Item)(nil))
+
+	failures := 2
+	update := func(key string, item *Item) (*Item, error) {
+		if failures > 0 {
+			failures--
+			return nil, sqlStateError("40001")
+		}
+		return &Item{Name: key}, nil
+	}
+
+	t.Run("retried", func(t *testing.T) {
+		store := NewKeyValueStore[Item](db)
+		err := store.UpdateOne(ctx, "one", update)
+		item, _ := store.GetOne(ctx, "one")
+		Expect(t,
+			NoError(err),
+			Equal(0, failures),
+			Equal(&Item{ID: "one", Name: "one"}, item),
+		)
+	})
+	t.Run("exhausted", func(t *testing.T) {
+		failures = 2
+		store := NewKeyValueStore[Item](db, &StoreOptions{
+			Retry: &RetryOptions{MaxRetries: 1},
+		})
+		err := store.UpdateOne(ctx, "two", update)
+		Expect(t,
+			IsError(ErrConflict, err),
+			Equal(0, failures),
+		)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		failures = 2
+		store := NewKeyValueStore[Item](db, &StoreOptions{
+			Retry: &RetryOptions{MaxRetries: -1},
+		})
+		err := store.UpdateOne(ctx, "three", update)
+		Expect(t,
+			IsError(ErrConflict, err),
+			Equal(1, failures),
+		)
+	})
+}
+
+type counterItem struct {
+	bun.BaseModel `bun:"table:counter_items"`
+
+	ID    string `bun:",pk"`
+	Value int
+}
+
+func TestPGConcurrentUpdates(t *testing.T) {
+	pg, err := pgtest.Start()
+	Require(t,
+		NoError(err),
+	)
+	t.Cleanup(func() { pg.Stop() })
+
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	db := newPostgres(t, pg)
+	store := NewKeyValueStore[counterItem](db, &StoreOptions{
+		Retry: &RetryOptions{MaxRetries: 100},
+	})
+	Require(t, NoError(store.Reset(ctx)))
+
+	const workers, increments = 8, 10
+	keys := []string{"a", "b", "c"}
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*increments)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				errs <- store.UpdateMany(ctx, keys, func(_ string, item *counterItem) (*counterItem, error) {
+					if item == nil {
+						item = &counterItem{}
+					}
+					item.Value++
+					return item, nil
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		Require(t, NoError(err))
+	}
+
+	all, err := store.GetAll(ctx)
+	want := make(map[string]*counterItem, len(keys))
+	for _, key := range keys {
+		want[key] = &counterItem{ID: key, Value: workers * increments}
+	}
+	Expect(t,
+		NoError(err),
+		Equal(want, all),
+	)
+}
@@ -0,0 +1,356 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+	"golang.org/x/exp/maps"
+
+	"github.com/ArnaudCalmettes/store/internal/libbun"
+)
+
+type SchemaOptions struct {
+	// DryRun prints the DDL statements to Output instead of executing them.
+	DryRun bool
+
+	// Output receives the dry-run statements and the schema warnings, as SQL
+	// comments. It defaults to io.Discard.
+	Output io.Writer
+}
+
+type SchemaEnsurer interface {
+	EnsureSchema(ctx context.Context, opts ...*SchemaOptions) error
+}
+
+var (
+	errUnsupportedDialect = errors.New("unsupported dialect")
+	errNotNullColumn      = errors.New("cannot add a NOT NULL column without a default value")
+)
+
+// ensureSchema creates the table of model if it doesn't exist, and adds the
+// columns and indexes it is missing otherwise. It never drops nor alters the
+// table or its columns: columns that exist in the table but not in the model
+// are left as they are and reported as warnings to Output, and the types of
+// existing columns aren't checked. The full-text objects it creates are the
+// exception, since they only index the table: they are dropped and created
+// again when the full-text columns of the model change (see planFullText).
+//
+// The table is inspected and altered through conn, which is either db or one
+// of its transactions.
+func ensureSchema(ctx context.Context, db *bun.DB, conn bun.IDB, model any, spec *libbun.TableSpec, opts ...*SchemaOptions) error {
+	opt := &SchemaOptions{Output: io.Discard}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		opt.DryRun = opt.DryRun || o.DryRun
+		if o.Output != nil {
+			opt.Output = o.Output
+		}
+	}
+
+	statements, warnings, err := planSchema(ctx, db, conn, model, spec)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		if _, err := fmt.Fprintf(opt.Output, "-- warning: %s\n", warning); err != nil {
+			return err
+		}
+	}
+	if opt.DryRun {
+		for _, statement := range statements {
+			if _, err := fmt.Fprintf(opt.Output, "%s;\n", statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(statements) == 0 {
+		return nil
+	}
+	return conn.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func planSchema(ctx context.Context, db *bun.DB, conn bun.IDB, model any, spec *libbun.TableSpec) (statements, warnings []string, err error) {
+	columns, indexes, err := inspectTable(ctx, conn, spec.TableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(columns) == 0 {
+		statements = append(statements, db.NewCreateTable().Model(model).String())
+	} else {
+		var extra []string
+		statements, extra, err = planAddColumns(db, model, spec, columns)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(extra) > 0 {
+			warnings = append(warnings, fmt.Sprintf("columns %q of table %q aren't in the model", extra, spec.TableName))
+		}
+	}
+	for _, column := range spec.Indexes {
+		name := indexName(spec, column)
+		if slices.Contains(indexes, name) {
+			continue
+		}
+		statement, err := queryString(db, db.NewCreateIndex().Model(model).Index(name).Column(column))
+		if err != nil {
+			return nil, nil, err
+		}
+		statements = append(statements, statement)
+	}
+	fullText, err := planFullText(ctx, conn, spec, columns, indexes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(statements, fullText...), warnings, nil
+}
+
+func indexName(spec *libbun.TableSpec, column string) string {
+	return fmt.Sprintf("%s_%s_idx", spec.TableName, column)
+}
+
+func fullTextIndexName(spec *libbun.TableSpec, column string) string {
+	return fmt.Sprintf("%s_%s_fts_idx", spec.TableName, column)
+}
+
+// planFullText returns the statements that index the full-text columns of a
+// table, given its current columns and indexes: an external-content FTS5
+// table kept in sync by triggers with SQLite, and a GIN index per column with
+// PostgreSQL. FTS5 tables can't be altered, so they are recreated along with
+// their triggers when their columns change, and dropped when the model has no
+// full-text columns anymore. GIN indexes are dropped when their column isn't
+// full-text anymore. Only objects named the way this function names them are
+// ever dropped.
+func planFullText(ctx context.Context, conn bun.IDB, spec *libbun.TableSpec, tableColumns, indexes []string) ([]string, error) {
+	fmter := schema.NewFormatter(conn.Dialect())
+	format := func(query string, args ...any) string {
+		return fmter.FormatQuery(query, args...)
+	}
+	var statements []string
+	switch conn.Dialect().Name() {
+	case dialect.SQLite:
+	case dialect.PG:
+		for _, column := range tableColumns {
+			name := fullTextIndexName(spec, column)
+			if slices.Contains(indexes, name) && !slices.Contains(spec.FullTextColumns, column) {
+				statements = append(statements, format("DROP INDEX ?", bun.Ident(name)))
+			}
+		}
+		for _, column := range spec.FullTextColumns {
+			name := fullTextIndexName(spec, column)
+			if slices.Contains(indexes, name) {
+				continue
+			}
+			statements = append(statements, format(
+				"CREATE INDEX ? ON ? USING GIN (to_tsvector('simple', ?))",
+				bun.Ident(name), bun.Ident(spec.TableName), bun.Ident(column),
+			))
+		}
+		return statements, nil
+	default:
+		return nil, nil
+	}
+
+	var existing []string
+	err := conn.NewRaw("SELECT name FROM sqlite_master WHERE tbl_name IN (?)",
+		bun.In([]string{spec.TableName, spec.FullTextTable()}),
+	).Scan(ctx, &existing)
+	if err != nil {
+		return nil, err
+	}
+	table, fts := bun.Ident(spec.TableName), bun.Ident(spec.FullTextTable())
+	triggers := []string{spec.FullTextTable() + "_ai", spec.FullTextTable() + "_ad", spec.FullTextTable() + "_au"}
+	if slices.Contains(existing, spec.FullTextTable()) {
+		var indexed []string
+		err := conn.NewRaw("SELECT name FROM pragma_table_info(?)", spec.FullTextTable()).Scan(ctx, &indexed)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Equal(indexed, spec.FullTextColumns) {
+			for _, trigger := range triggers {
+				statements = append(statements, format("DROP TRIGGER IF EXISTS ?", bun.Ident(trigger)))
+			}
+			statements = append(statements, format("DROP TABLE ?", fts))
+			existing = nil
+		}
+	}
+	if len(spec.FullTextColumns) == 0 {
+		return statements, nil
+	}
+	columns := make([]bun.Ident, len(spec.FullTextColumns))
+	oldValues := make([]string, len(columns))
+	newValues := make([]string, len(columns))
+	for i, column := range spec.FullTextColumns {
+		columns[i] = bun.Ident(column)
+		oldValues[i] = format("old.?", columns[i])
+		newValues[i] = format("new.?", columns[i])
+	}
+	deleteOld := format("INSERT INTO ?0 (?0, rowid, ?1) VALUES ('delete', old.rowid, ?2);",
+		fts, bun.In(columns), bun.Safe(strings.Join(oldValues, ", ")),
+	)
+	insertNew := format("INSERT INTO ?0 (rowid, ?1) VALUES (new.rowid, ?2);",
+		fts, bun.In(columns), bun.Safe(strings.Join(newValues, ", ")),
+	)
+	planned := []struct{ name, statement string }{
+		{spec.FullTextTable(), format(
+			"CREATE VIRTUAL TABLE ? USING fts5(?, content=?, content_rowid='rowid')",
+			fts, bun.In(columns), spec.TableName,
+		)},
+		{triggers[0], format(
+			"CREATE TRIGGER ? AFTER INSERT ON ? BEGIN ? END",
+			bun.Ident(triggers[0]), table, bun.Safe(insertNew),
+		)},
+		{triggers[1], format(
+			"CREATE TRIGGER ? AFTER DELETE ON ? BEGIN ? END",
+			bun.Ident(triggers[1]), table, bun.Safe(deleteOld),
+		)},
+		{triggers[2], format(
+			"CREATE TRIGGER ? AFTER UPDATE ON ? BEGIN ? ? END",
+			bun.Ident(triggers[2]), table, bun.Safe(deleteOld), bun.Safe(insertNew),
+		)},
+	}
+	for _, p := range planned {
+		if !slices.Contains(existing, p.name) {
+			statements = append(statements, p.statement)
+		}
+	}
+	if len(statements) > 0 {
+		// Index the rows that were inserted before the triggers existed.
+		statements = append(statements, format("INSERT INTO ?0 (?0) VALUES ('rebuild')", fts))
+	}
+	return statements, nil
+}
+
+// planAddColumns returns the statements that add the missing columns of a
+// table, and the existing columns that aren't in the model.
+func planAddColumns(db *bun.DB, model any, spec *libbun.TableSpec, existing []string) (statements, extra []string, err error) {
+	wanted := maps.Values(spec.ColumnNames)
+	for _, column := range existing {
+		if !slices.Contains(wanted, column) {
+			extra = append(extra, column)
+		}
+	}
+	slices.Sort(extra)
+
+	slices.Sort(wanted)
+	table := db.Table(reflect.TypeOf(model).Elem())
+	for _, column := range wanted {
+		if slices.Contains(existing, column) {
+			continue
+		}
+		field := table.FieldMap[column]
+		expr := "? ?"
+		args := []any{bun.Ident(column), bun.Safe(field.CreateTableSQLType)}
+		if field.NotNull {
+			if field.SQLDefault == "" {
+				return nil, nil, fmt.Errorf("%w: %q", errNotNullColumn, column)
+			}
+			expr += " NOT NULL"
+		}
+		if field.SQLDefault != "" {
+			expr += " DEFAULT ?"
+			args = append(args, bun.Safe(field.SQLDefault))
+		}
+		statement, err := queryString(db, db.NewAddColumn().Model(model).ColumnExpr(expr, args...))
+		if err != nil {
+			return nil, nil, err
+		}
+		statements = append(statements, statement)
+	}
+	return statements, extra, nil
+}
+
+// resetModel drops the table of model and creates it again, along with its
+// indexes, like bun.DB.ResetModel. Unlike ensureSchema, it doesn't need to
+// inspect the table, but it doesn't index full-text columns.
+func resetModel(ctx context.Context, conn bun.IDB, model any, spec *libbun.TableSpec) error {
+	if _, err := conn.NewDropTable().Model(model).IfExists().Cascade().Exec(ctx); err != nil {
+		return err
+	}
+	if _, err := conn.NewCreateTable().Model(model).Exec(ctx); err != nil {
+		return err
+	}
+	for _, column := range spec.Indexes {
+		_, err := conn.NewCreateIndex().Model(model).Index(indexName(spec, column)).Column(column).Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// canInspect reports whether inspectTable supports the dialect of db.
+func canInspect(db bun.IDB) bool {
+	switch db.Dialect().Name() {
+	case dialect.SQLite, dialect.PG:
+		return true
+	default:
+		return false
+	}
+}
+
+// inspectTable returns the columns and indexes of a table. A table that
+// doesn't exist has no columns.
+func inspectTable(ctx context.Context, db bun.IDB, table string) (columns, indexes []string, err error) {
+	var columnsQuery, indexesQuery string
+	switch db.Dialect().Name() {
+	case dialect.SQLite:
+		columnsQuery = "SELECT name FROM pragma_table_info(?)"
+		indexesQuery = "SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ?"
+	case dialect.PG:
+		columnsQuery = "SELECT column_name FROM information_schema.columns " +
+			"WHERE table_schema = current_schema() AND table_name = ?"
+		indexesQuery = "SELECT indexname FROM pg_indexes " +
+			"WHERE schemaname = current_schema() AND tablename = ?"
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", errUnsupportedDialect, db.Dialect().Name())
+	}
+	if err := db.NewRaw(columnsQuery, table).Scan(ctx, &columns); err != nil {
+		return nil, nil, err
+	}
+	if err := db.NewRaw(indexesQuery, table).Scan(ctx, &indexes); err != nil {
+		return nil, nil, err
+	}
+	return columns, indexes, nil
+}
+
+func queryString(db *bun.DB, query schema.QueryAppender) (string, error) {
+	b, err := query.AppendQuery(db.Formatter(), nil)
+	return string(b), err
+}
@@ -0,0 +1,310 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package sql
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	//lint:ignore ST1001 common definitions
+	. "github.com/ArnaudCalmettes/store"
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+	"github.com/rubenv/pgtest"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+type schemaItemV1 struct {
+	bun.BaseModel `bun:"table:schema_items"`
+
+	ID   string `bun:",pk"`
+	Name string
+}
+
+type schemaItemV2 struct {
+	bun.BaseModel `bun:"table:schema_items"`
+
+	ID    string `bun:",pk"`
+	Name  string `store:"index"`
+	Count int
+}
+
+type schemaItemRemovedColumn struct {
+	bun.BaseModel `bun:"table:schema_items"`
+
+	ID string `bun:",pk"`
+}
+
+type schemaItemNotNull struct {
+	bun.BaseModel `bun:"table:schema_items"`
+
+	ID    string `bun:",pk"`
+	Name  string
+	Count int
+	Flag  bool `bun:",notnull"`
+}
+
+type schemaItemDefault struct {
+	bun.BaseModel `bun:"table:schema_items"`
+
+	ID    string `bun:",pk"`
+	Name  string
+	Count int
+	Flag  bool `bun:",notnull,default:false"`
+}
+
+type schemaArticleV1 struct {
+	bun.BaseModel `bun:"table:schema_articles"`
+
+	ID   string `bun:",pk"`
+	Body string
+}
+
+type schemaArticleV2 struct {
+	bun.BaseModel `bun:"table:schema_articles"`
+
+	ID   string `bun:",pk"`
+	Body string `store:"fulltext"`
+}
+
+type schemaArticleV3 struct {
+	bun.BaseModel `bun:"table:schema_articles"`
+
+	ID    string `bun:",pk"`
+	Title string `store:"fulltext"`
+	Body  string `store:"fulltext"`
+}
+
+func dryRun(t *testing.T, store SchemaEnsurer) string {
+	t.Helper()
+	var out bytes.Buffer
+	err := store.EnsureSchema(context.Background(), &SchemaOptions{DryRun: true, Output: &out})
+	Require(t, NoError(err))
+	return out.String()
+}
+
+func testEnsureSchema(t *testing.T, db *bun.DB) {
+	ctx := context.Background()
+	v1 := NewKeyValueStore[schemaItemV1](db)
+
+	ddl := dryRun(t, v1)
+	Expect(t,
+		Equalf(true, strings.HasPrefix(ddl, `CREATE TABLE "schema_items"`), "unexpected DDL: %s", ddl),
+		Equalf(ddl, dryRun(t, v1), "dry run shouldn't create the table"),
+	)
+
+	Require(t, NoError(v1.EnsureSchema(ctx)))
+	Require(t, NoError(v1.SetOne(ctx, "one", &schemaItemV1{Name: "one"})))
+	Expect(t, Equal("", dryRun(t, v1)))
+
+	v2 := NewKeyValueStore[schemaItemV2](db)
+	ddl = dryRun(t, v2)
+	Expect(t,
+		Equalf(true, strings.Contains(ddl, `ADD "count" `), "unexpected DDL: %s", ddl),
+		Equalf(true, strings.Contains(ddl, `CREATE INDEX "schema_items_name_idx"`), "unexpected DDL: %s", ddl),
+	)
+	Require(t, NoError(v2.EnsureSchema(ctx)))
+	Expect(t, Equal("", dryRun(t, v2)))
+
+	item, err := v2.GetOne(ctx, "one")
+	Expect(t,
+		NoError(err),
+		Equal(&schemaItemV2{ID: "one", Name: "one"}, item),
+	)
+
+	// Columns that aren't in the model are kept, with a warning.
+	var out bytes.Buffer
+	removed := NewKeyValueStore[schemaItemRemovedColumn](db)
+	err = removed.EnsureSchema(ctx, &SchemaOptions{Output: &out})
+	Expect(t,
+		NoError(err),
+		Equal("-- warning: columns [\"count\" \"name\"] of table \"schema_items\" aren't in the model\n", out.String()),
+	)
+	ids, err := removed.GetAll(ctx)
+	Expect(t,
+		NoError(err),
+		Equal(map[string]*schemaItemRemovedColumn{"one": {ID: "one"}}, ids),
+	)
+
+	err = NewKeyValueStore[schemaItemNotNull](db).EnsureSchema(ctx)
+	Expect(t, IsError(errNotNullColumn, err))
+
+	Require(t, NoError(v2.Reset(ctx)))
+	all, err := v2.GetAll(ctx)
+	Expect(t,
+		NoError(err),
+		Equal(map[string]*schemaItemV2{}, all),
+		Equal("", dryRun(t, v2)),
+	)
+
+	err = NewKeyValueStore[schemaItemDefault](db).EnsureSchema(ctx)
+	Expect(t, NoError(err))
+
+	// Reset recreates the table without the column added above.
+	Require(t,
+		NoError(v2.SetOne(ctx, "two", &schemaItemV2{Name: "two"})),
+		NoError(v2.Reset(ctx)),
+	)
+	all, err = v2.GetAll(ctx)
+	Expect(t,
+		NoError(err),
+		Equal(map[string]*schemaItemV2{}, all),
+		Equal("", dryRun(t, v2)),
+	)
+}
+
+func TestSQLiteEnsureSchema(t *testing.T) {
+	testEnsureSchema(t, newSQLite(t))
+}
+
+func TestPGEnsureSchema(t *testing.T) {
+	pg, err := pgtest.Start()
+	Require(t,
+		NoError(err),
+	)
+	t.Cleanup(func() { pg.Stop() })
+	testEnsureSchema(t, newPostgres(t, pg))
+}
+
+func TestSQLiteResetModel(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLite(t)
+	store := NewKeyValueStore[schemaItemV2](db)
+	spec := store.(*keyValueStore[schemaItemV2]).spec
+	Require(t,
+		NoError(resetModel(ctx, db, (*schemaItemV2)(nil), spec)),
+		NoError(store.SetOne(ctx, "one", &schemaItemV2{Name: "one"})),
+		NoError(resetModel(ctx, db, (*schemaItemV2)(nil), spec)),
+	)
+	all, err := store.GetAll(ctx)
+	Expect(t,
+		NoError(err),
+		Equal(map[string]*schemaItemV2{}, all),
+		Equalf("", dryRun(t, store), "the table should have its indexes"),
+	)
+}
+
+func testEnsureFullTextSchema(t *testing.T, db *bun.DB) {
+	ctx := context.Background()
+	v1 := NewKeyValueStore[schemaArticleV1](db)
+	Require(t,
+		NoError(v1.EnsureSchema(ctx)),
+		NoError(v1.SetOne(ctx, "one", &schemaArticleV1{Body: "hello world"})),
+	)
+
+	v2 := NewKeyValueStore[schemaArticleV2](db)
+	ddl := dryRun(t, v2)
+	Expect(t,
+		Equalf(true, strings.Contains(ddl, "schema_articles_"), "unexpected DDL: %s", ddl),
+	)
+	Require(t, NoError(v2.EnsureSchema(ctx)))
+	Expect(t, Equal("", dryRun(t, v2)))
+
+	// Rows that existed before the index was created are indexed too.
+	Require(t, NoError(v2.SetOne(ctx, "two", &schemaArticleV2{Body: "goodbye world"})))
+	items, err := v2.List(ctx, Filter(Match("Body", "world")), Order(By("ID")))
+	Expect(t,
+		NoError(err),
+		Equal([]*schemaArticleV2{
+			{ID: "one", Body: "hello world"},
+			{ID: "two", Body: "goodbye world"},
+		}, items),
+	)
+
+	_, err = v1.List(ctx, Filter(Match("Body", "world")))
+	Expect(t, IsError(ErrInvalidFilter, err))
+
+	// Adding a full-text column indexes the existing rows again.
+	v3 := NewKeyValueStore[schemaArticleV3](db)
+	Require(t,
+		NoError(v3.EnsureSchema(ctx)),
+		NoError(v3.SetOne(ctx, "one", &schemaArticleV3{Title: "greetings", Body: "hello world"})),
+	)
+	Expect(t, Equal("", dryRun(t, v3)))
+	titles, err := v3.List(ctx, Filter(Match("Title", "greetings")))
+	Expect(t,
+		NoError(err),
+		Equal([]*schemaArticleV3{{ID: "one", Title: "greetings", Body: "hello world"}}, titles),
+	)
+	bodies, err := v3.List(ctx, Filter(Match("Body", "world")), Order(By("ID")))
+	Expect(t,
+		NoError(err),
+		Equal([]*schemaArticleV3{
+			{ID: "one", Title: "greetings", Body: "hello world"},
+			{ID: "two", Body: "goodbye world"},
+		}, bodies),
+	)
+
+	Require(t,
+		NoError(v2.Reset(ctx)),
+		NoError(v2.SetOne(ctx, "three", &schemaArticleV2{Body: "hello again"})),
+	)
+	items, err = v2.List(ctx, Filter(Match("Body", "hello")))
+	Expect(t,
+		NoError(err),
+		Equal([]*schemaArticleV2{{ID: "three", Body: "hello again"}}, items),
+		Equal("", dryRun(t, v2)),
+	)
+
+	// Full-text objects are dropped once the model has no full-text columns
+	// anymore, but indexes that only look like them are kept.
+	_, err = db.NewRaw("CREATE INDEX ? ON ? (?)",
+		bun.Ident("schema_articles_manual_fts_idx"), bun.Ident("schema_articles"), bun.Ident("id"),
+	).Exec(ctx)
+	Require(t,
+		NoError(err),
+		NoError(v1.EnsureSchema(ctx)),
+	)
+	Expect(t, Equal("", dryRun(t, v1)))
+	_, indexes, err := inspectTable(ctx, db, "schema_articles")
+	var fullText []string
+	for _, name := range indexes {
+		if strings.HasSuffix(name, "_fts_idx") {
+			fullText = append(fullText, name)
+		}
+	}
+	Expect(t,
+		NoError(err),
+		Equal([]string{"schema_articles_manual_fts_idx"}, fullText),
+	)
+	if db.Dialect().Name() == dialect.SQLite {
+		var count int
+		err := db.NewRaw("SELECT count(*) FROM sqlite_master WHERE name LIKE 'schema_articles_fts%'").Scan(ctx, &count)
+		Expect(t,
+			NoError(err),
+			Equalf(0, count, "the FTS5 table and its triggers should be dropped"),
+		)
+	}
+}
+
+func TestSQLiteEnsureFullTextSchema(t *testing.T) {
+	testEnsureFullTextSchema(t, newSQLite(t))
+}
+
+func TestPGEnsureFullTextSchema(t *testing.T) {
+	pg, err := pgtest.Start()
+	Require(t,
+		NoError(err),
+	)
+	t.Cleanup(func() { pg.Stop() })
+	testEnsureFullTextSchema(t, newPostgres(t, pg))
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"testing"
+	"time"
+
+	//lint:ignore ST1001 shared definitions
+	. "github.com/ArnaudCalmettes/store"
+	//lint:ignore ST1001 test vocabulary
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+type TestAutoFieldsInterface[T any] interface {
+	BaseKeyValueStore[T]
+	ClockSetter
+}
+
+type Document struct {
+	Title     string
+	CreatedAt time.Time `store:"created_at"`
+	UpdatedAt time.Time `store:"updated_at"`
+	Version   int       `store:"version"`
+}
+
+func TestAutoFields[S TestAutoFieldsInterface[Document]](t *testing.T, newStore func(*testing.T) S) {
+	store := newStore(t)
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	store.SetClock(func() time.Time { return now })
+	at := func(hours int) time.Time {
+		return start.Add(time.Duration(hours) * time.Hour)
+	}
+	tick := func() { now = now.Add(time.Hour) }
+	expect := func(t *testing.T, want map[string]*Document) {
+		t.Helper()
+		all, err := store.GetAll(ctx)
+		Expect(t,
+			NoError(err),
+			Equal(want, all),
+		)
+	}
+
+	err := store.SetOne(ctx, "a", &Document{Title: "a"})
+	Require(t, NoError(err))
+	expect(t, map[string]*Document{
+		"a": {Title: "a", CreatedAt: at(0), UpdatedAt: at(0), Version: 1},
+	})
+
+	tick()
+	err = store.SetOne(ctx, "a", &Document{Title: "a2"})
+	Require(t, NoError(err))
+	expect(t, map[string]*Document{
+		"a": {Title: "a2", CreatedAt: at(0), UpdatedAt: at(1), Version: 2},
+	})
+
+	tick()
+	err = store.UpdateOne(ctx, "a", func(_ string, d *Document) (*Document, error) {
+		d.Title = "a3"
+		return d, nil
+	})
+	Require(t, NoError(err))
+	expect(t, map[string]*Document{
+		"a": {Title: "a3", CreatedAt: at(0), UpdatedAt: at(2), Version: 3},
+	})
+
+	tick()
+	err = store.SetMany(ctx, map[string]*Document{
+		"a": {Title: "a4"},
+		"b": {Title: "b"},
+	})
+	Require(t, NoError(err))
+	expect(t, map[string]*Document{
+		"a": {Title: "a4", CreatedAt: at(0), UpdatedAt: at(3), Version: 4},
+		"b": {Title: "b", CreatedAt: at(3), UpdatedAt: at(3), Version: 1},
+	})
+
+	tick()
+	err = store.UpdateMany(ctx, []string{"b", "c"}, func(key string, d *Document) (*Document, error) {
+		if d == nil {
+			return &Document{Title: key}, nil
+		}
+		d.Title += "2"
+		return d, nil
+	})
+	Require(t, NoError(err))
+	expect(t, map[string]*Document{
+		"a": {Title: "a4", CreatedAt: at(0), UpdatedAt: at(3), Version: 4},
+		"b": {Title: "b2", CreatedAt: at(3), UpdatedAt: at(4), Version: 2},
+		"c": {Title: "c", CreatedAt: at(4), UpdatedAt: at(4), Version: 1},
+	})
+}
@@ -44,6 +44,7 @@ func TestBaseKeyValueMap(t *testing.T, newMap func(*testing.T) BaseKeyValueMap)
 	run(t, "UpdateOne", testBaseKeyValueMapUpdateOne)
 	run(t, "UpdateMany", testBaseKeyValueMapUpdateMany)
 	run(t, "Delete", testBaseKeyValueMapDelete)
+	run(t, "DeleteIf", testBaseKeyValueMapDeleteIf)
 }
 
 func testBaseKeyValueMapGetSetOne(t *testing.T, newMap func(*testing.T) BaseKeyValueMap) {
@@ -115,11 +116,15 @@ func testBaseKeyValueMapGetSetMany(t *testing.T, newMap func(*testing.T) BaseKey
 		)
 	})
 	t.Run("set many empty key", func(t *testing.T) {
-		err := store.SetMany(ctx, map[string]string{
+		items := map[string]string{
 			"": "value",
-		})
+		}
+		err := store.SetMany(ctx, items)
 		Require(t,
-			NoError(err),
+			IsBatchError(map[string]error{"": ErrEmptyKey}, err),
+		)
+		Expect(t,
+			Equalf(map[string]string{"": "value"}, items, "SetMany shouldn't modify its input"),
 		)
 		all, err := store.GetAll(ctx)
 		Expect(t,
@@ -139,7 +144,7 @@ func testBaseKeyValueMapGetSetMany(t *testing.T, newMap func(*testing.T) BaseKey
 
 		result, err := store.GetMany(ctx, []string{"one", "two", "three", "four"})
 		Expect(t,
-			NoError(err),
+			IsBatchError(map[string]error{"four": ErrNotFound}, err),
 			Equal(
 				map[string]string{
 					"one":   "one",
@@ -161,7 +166,7 @@ func testBaseKeyValueMapGetSetMany(t *testing.T, newMap func(*testing.T) BaseKey
 
 		result, err := store.GetMany(ctx, []string{"one", "two", "three", "four"})
 		Require(t,
-			NoError(err),
+			IsBatchError(map[string]error{"four": ErrNotFound}, err),
 			Equal(
 				map[string]string{
 					"one":   "ONE",
@@ -294,7 +299,7 @@ func testBaseKeyValueMapUpdateMany(t *testing.T, newMap func(*testing.T) BaseKey
 	t.Run("empty keys", func(t *testing.T) {
 		err := store.UpdateMany(ctx, []string{""}, nil)
 		Expect(t,
-			NoError(err),
+			IsBatchError(map[string]error{"": ErrEmptyKey}, err),
 		)
 	})
 	t.Run("key does not exist", func(t *testing.T) {
@@ -418,8 +423,57 @@ func testBaseKeyValueMapDelete(t *testing.T, newMap func(*testing.T) BaseKeyValu
 
 		all, err := store.GetMany(ctx, []string{"one", "two", "three"})
 		Expect(t,
-			NoError(err),
+			IsBatchError(map[string]error{"two": ErrNotFound, "three": ErrNotFound}, err),
 			Equal(map[string]string{"one": "one"}, all),
 		)
 	})
 }
+
+func testBaseKeyValueMapDeleteIf(t *testing.T, newMap func(*testing.T) BaseKeyValueMap) {
+	store := newMap(t)
+	deleter, ok := store.(ConditionalDeleter)
+	if !ok {
+		t.Skip("map doesn't implement ConditionalDeleter")
+	}
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	t.Run("empty keys", func(t *testing.T) {
+		count, err := deleter.DeleteIf(ctx, nil, func(string, string) bool {
+			return true
+		})
+		Expect(t,
+			NoError(err),
+			Equal(0, count),
+		)
+	})
+	t.Run("nominal", func(t *testing.T) {
+		err := store.SetMany(ctx, map[string]string{
+			"one":   "keep",
+			"two":   "delete",
+			"three": "delete",
+		})
+		Require(t,
+			NoError(err),
+		)
+
+		seen := map[string]string{}
+		count, err := deleter.DeleteIf(ctx, []string{"", "one", "two", "four"}, func(key, value string) bool {
+			seen[key] = value
+			return value == "delete"
+		})
+		Expect(t,
+			NoError(err),
+			Equal(1, count),
+			Equalf(map[string]string{"one": "keep", "two": "delete"}, seen,
+				"cond should only be called on existing keys",
+			),
+		)
+
+		all, err := store.GetMany(ctx, []string{"one", "two", "three"})
+		Expect(t,
+			IsBatchError(map[string]error{"two": ErrNotFound}, err),
+			Equal(map[string]string{"one": "keep", "three": "delete"}, all),
+		)
+	})
+}
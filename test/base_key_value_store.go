@@ -39,11 +39,14 @@ type Entry struct {
 
 type baseStoreConstructor = func(*testing.T) BaseKeyValueStore[Entry]
 
-func TestBaseKeyValueStore(t *testing.T, newStore baseStoreConstructor) {
+func TestBaseKeyValueStore[S BaseKeyValueStore[Entry]](t *testing.T, newStore func(*testing.T) S) {
 	type TestFunc = func(*testing.T, baseStoreConstructor)
+	newBaseStore := func(t *testing.T) BaseKeyValueStore[Entry] {
+		return newStore(t)
+	}
 	run := func(t *testing.T, name string, testFunc TestFunc) {
 		t.Run(name, func(t *testing.T) {
-			testFunc(t, newStore)
+			testFunc(t, newBaseStore)
 		})
 	}
 	t.Parallel()
@@ -143,7 +146,7 @@ func testBaseKeyValueStoreGetSetMany(t *testing.T, newStore baseStoreConstructor
 			"": {String: "value"},
 		})
 		Require(t,
-			NoError(err),
+			IsBatchError(map[string]error{"": ErrEmptyKey}, err),
 		)
 		all, err := store.GetAll(ctx)
 		Expect(t,
@@ -163,7 +166,7 @@ func testBaseKeyValueStoreGetSetMany(t *testing.T, newStore baseStoreConstructor
 
 		result, err := store.GetMany(ctx, []string{"one", "two", "three", "four"})
 		Expect(t,
-			NoError(err),
+			IsBatchError(map[string]error{"four": ErrNotFound}, err),
 			Equal(
 				map[string]*Entry{
 					"one":   {String: "one"},
@@ -185,7 +188,7 @@ func testBaseKeyValueStoreGetSetMany(t *testing.T, newStore baseStoreConstructor
 
 		result, err := store.GetMany(ctx, []string{"one", "two", "three", "four"})
 		Require(t,
-			NoError(err),
+			IsBatchError(map[string]error{"four": ErrNotFound}, err),
 			Equal(
 				map[string]*Entry{
 					"one":   {String: "ONE"},
@@ -319,7 +322,7 @@ func testBaseKeyValueStoreUpdateMany(t *testing.T, newStore baseStoreConstructor
 	t.Run("empty keys", func(t *testing.T) {
 		err := store.UpdateMany(ctx, []string{""}, nil)
 		Expect(t,
-			NoError(err),
+			IsBatchError(map[string]error{"": ErrEmptyKey}, err),
 		)
 	})
 	t.Run("key does not exist", func(t *testing.T) {
@@ -445,7 +448,7 @@ func testBaseKeyValueStoreDelete(t *testing.T, newStore baseStoreConstructor) {
 
 		all, err := store.GetMany(ctx, []string{"one", "two", "three"})
 		Expect(t,
-			NoError(err),
+			IsBatchError(map[string]error{"two": ErrNotFound, "three": ErrNotFound}, err),
 			Equal(map[string]*Entry{"one": {String: "one"}}, all),
 		)
 	})
@@ -0,0 +1,52 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	//lint:ignore ST1001 common definitions
+	. "github.com/ArnaudCalmettes/store"
+	"golang.org/x/exp/maps"
+)
+
+// IsBatchError checks that got is a BatchError reporting exactly the keys of
+// want, each with the expected error.
+func IsBatchError(want map[string]error, got error) error {
+	var batch *BatchError
+	if !errors.As(got, &batch) {
+		return fmt.Errorf("expected a batch error, got: %v", got)
+	}
+	wantKeys := maps.Keys(want)
+	gotKeys := maps.Keys(batch.Errors)
+	slices.Sort(wantKeys)
+	slices.Sort(gotKeys)
+	if !slices.Equal(wantKeys, gotKeys) {
+		return fmt.Errorf("expected errors for keys %q, got %q", wantKeys, gotKeys)
+	}
+	for key, err := range want {
+		if !errors.Is(batch.Errors[key], err) {
+			return fmt.Errorf("key %q: expected error %q, got: %v", key, err, batch.Errors[key])
+		}
+	}
+	return nil
+}
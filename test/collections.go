@@ -0,0 +1,88 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"testing"
+
+	//lint:ignore ST1001 shared definitions
+	. "github.com/ArnaudCalmettes/store"
+	//lint:ignore ST1001 test vocabulary
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+type Tagged struct {
+	Name   string
+	Tags   []string
+	Sizes  []int
+	Labels map[string]string
+}
+
+func TestCollectionFilters[S TestListerInterface[Tagged]](t *testing.T, newStore func(*testing.T) S) {
+	store := newStore(t)
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	err := store.SetMany(ctx, map[string]*Tagged{
+		"a": {Name: "a", Tags: []string{"go", "sql"}, Sizes: []int{1, 2}, Labels: map[string]string{"env": "prod"}},
+		"b": {Name: "b", Tags: []string{"go"}, Sizes: []int{3}, Labels: map[string]string{"team": "core"}},
+		"c": {Name: "c"},
+	})
+	Require(t,
+		NoError(err),
+	)
+	names := func(items []*Tagged) []string {
+		result := make([]string, len(items))
+		for i, item := range items {
+			result[i] = item.Name
+		}
+		return result
+	}
+
+	testCases := []struct {
+		Name   string
+		Filter *FilterSpec
+		Expect []string
+	}{
+		{"contains", Contains("Tags", "go"), []string{"a", "b"}},
+		{"contains number", Where("Sizes", "contains", 3), []string{"b"}},
+		{"contains any", ContainsAny("Tags", []string{"sql", "rust"}), []string{"a"}},
+		{"contains any number", Where("Sizes", "contains_any", []int{2, 3}), []string{"a", "b"}},
+		{"contains all", ContainsAll("Tags", []string{"go", "sql"}), []string{"a"}},
+		{"contains all nothing", Where("Tags", "contains_all", []string{}), []string{"a", "b", "c"}},
+		{"has key", HasKey("Labels", "team"), []string{"b"}},
+		{"combined", All(Where("Tags", "contains", "go"), Where("Labels", "has_key", "env")), []string{"a"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			result, err := store.List(ctx, Filter(tc.Filter), Order(By("Name")))
+			Expect(t,
+				NoError(err),
+				Equal(tc.Expect, names(result)),
+			)
+		})
+	}
+	t.Run("invalid operand", func(t *testing.T) {
+		_, err := store.List(ctx, Filter(Where("Tags", "contains_any", "go")))
+		Expect(t,
+			Equal(true, err != nil),
+		)
+	})
+}
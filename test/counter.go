@@ -0,0 +1,170 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"math"
+	"testing"
+
+	//lint:ignore ST1001 common definitions
+	. "github.com/ArnaudCalmettes/store"
+	//lint:ignore ST1001 test vocabulary
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+	"github.com/google/uuid"
+)
+
+type counterConstructor = func(*testing.T) Counter
+
+func TestCounter(t *testing.T, newCounter counterConstructor) {
+	type TestFunc = func(*testing.T, counterConstructor)
+	run := func(t *testing.T, name string, testFunc TestFunc) {
+		t.Run(name, func(t *testing.T) {
+			testFunc(t, newCounter)
+		})
+	}
+	run(t, "Incr", testCounterIncr)
+	run(t, "IncrMany", testCounterIncrMany)
+	run(t, "Overflow", testCounterOverflow)
+}
+
+func testCounterIncr(t *testing.T, newCounter counterConstructor) {
+	counter := newCounter(t)
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	t.Run("empty key", func(t *testing.T) {
+		_, err := counter.Incr(ctx, "", 1)
+		Expect(t,
+			IsError(ErrEmptyKey, err),
+		)
+	})
+	t.Run("does not exist", func(t *testing.T) {
+		value, err := counter.Incr(ctx, uuid.NewString(), 3)
+		Expect(t,
+			NoError(err),
+			Equal(int64(3), value),
+		)
+	})
+	t.Run("nominal", func(t *testing.T) {
+		key := uuid.NewString()
+		_, err := counter.Incr(ctx, key, 40)
+		Require(t,
+			NoError(err),
+		)
+		value, err := counter.Incr(ctx, key, 2)
+		Expect(t,
+			NoError(err),
+			Equal(int64(42), value),
+		)
+		value, err = counter.Incr(ctx, key, -50)
+		Expect(t,
+			NoError(err),
+			Equal(int64(-8), value),
+		)
+	})
+}
+
+func testCounterIncrMany(t *testing.T, newCounter counterConstructor) {
+	counter := newCounter(t)
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	t.Run("empty", func(t *testing.T) {
+		result, err := counter.IncrMany(ctx, map[string]int64{})
+		Expect(t,
+			NoError(err),
+			Equal(map[string]int64{}, result),
+		)
+	})
+	t.Run("empty key", func(t *testing.T) {
+		deltas := map[string]int64{"": 1}
+		result, err := counter.IncrMany(ctx, deltas)
+		Expect(t,
+			IsBatchError(map[string]error{"": ErrEmptyKey}, err),
+			Equal(map[string]int64{}, result),
+			Equalf(map[string]int64{"": 1}, deltas, "IncrMany shouldn't modify its input"),
+		)
+	})
+	t.Run("nominal", func(t *testing.T) {
+		_, err := counter.Incr(ctx, "one", 1)
+		Require(t,
+			NoError(err),
+		)
+		result, err := counter.IncrMany(ctx, map[string]int64{
+			"one": 10,
+			"two": 20,
+		})
+		Expect(t,
+			NoError(err),
+			Equal(map[string]int64{"one": 11, "two": 20}, result),
+		)
+	})
+}
+
+func testCounterOverflow(t *testing.T, newCounter counterConstructor) {
+	counter := newCounter(t)
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	maxKey, minKey := uuid.NewString(), uuid.NewString()
+	_, err := counter.IncrMany(ctx, map[string]int64{
+		maxKey: math.MaxInt64,
+		minKey: math.MinInt64,
+	})
+	Require(t,
+		NoError(err),
+	)
+
+	t.Run("Incr", func(t *testing.T) {
+		_, err := counter.Incr(ctx, maxKey, 1)
+		Expect(t,
+			IsError(ErrOverflow, err),
+		)
+		_, err = counter.Incr(ctx, minKey, -1)
+		Expect(t,
+			IsError(ErrOverflow, err),
+		)
+	})
+	t.Run("IncrMany", func(t *testing.T) {
+		other := uuid.NewString()
+		for _, deltas := range []map[string]int64{
+			{maxKey: 1, other: 1},
+			{minKey: -1, other: 1},
+			{other: 1, maxKey: math.MaxInt64},
+		} {
+			_, err := counter.IncrMany(ctx, deltas)
+			Expect(t,
+				IsErrorf(ErrOverflow, err, "IncrMany(%v) should overflow", deltas),
+			)
+		}
+		value, err := counter.Incr(ctx, other, 0)
+		Expect(t,
+			NoError(err),
+			Equalf(int64(0), value, "IncrMany should not be partially applied"),
+		)
+	})
+	t.Run("bounds", func(t *testing.T) {
+		result, err := counter.IncrMany(ctx, map[string]int64{maxKey: -1, minKey: 1})
+		Expect(t,
+			NoError(err),
+			Equal(map[string]int64{maxKey: math.MaxInt64 - 1, minKey: math.MinInt64 + 1}, result),
+		)
+	})
+}
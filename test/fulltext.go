@@ -0,0 +1,118 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"testing"
+
+	//lint:ignore ST1001 shared definitions
+	. "github.com/ArnaudCalmettes/store"
+	//lint:ignore ST1001 test vocabulary
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+type Article struct {
+	Title string
+	Body  string `store:"fulltext"`
+}
+
+func TestFullText[S TestListerInterface[Article]](t *testing.T, newStore func(*testing.T) S) {
+	store := newStore(t)
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	err := store.SetMany(ctx, map[string]*Article{
+		"a": {Title: "a", Body: "Go is fun"},
+		"b": {Title: "b", Body: "Go, go, GO!"},
+		"c": {Title: "c", Body: "Rust is fun too"},
+		"d": {Title: "d", Body: "Learning Go, then Rust, then Python and a few more languages"},
+	})
+	Require(t,
+		NoError(err),
+	)
+	titles := func(articles []*Article) []string {
+		result := make([]string, len(articles))
+		for i, a := range articles {
+			result[i] = a.Title
+		}
+		return result
+	}
+
+	t.Run("match all words", func(t *testing.T) {
+		result, err := store.List(ctx,
+			Filter(Match("Body", "rust fun")),
+			Order(By("Title")),
+		)
+		Expect(t,
+			NoError(err),
+			Equal([]string{"c"}, titles(result)),
+		)
+	})
+	t.Run("case and punctuation", func(t *testing.T) {
+		result, err := store.List(ctx,
+			Filter(Match("Body", "GO!")),
+			Order(By("Title")),
+		)
+		Expect(t,
+			NoError(err),
+			Equal([]string{"a", "b", "d"}, titles(result)),
+		)
+	})
+	t.Run("by relevance", func(t *testing.T) {
+		result, err := store.List(ctx,
+			Filter(Match("Body", "go")),
+			Order(ByRelevance("Body", "go")),
+		)
+		Expect(t,
+			NoError(err),
+			Equal([]string{"b", "a", "d"}, titles(result)),
+		)
+	})
+	t.Run("by relevance descending", func(t *testing.T) {
+		result, err := store.List(ctx,
+			Filter(Match("Body", "go")),
+			Order(ByRelevance("Body", "go").Desc()),
+		)
+		Expect(t,
+			NoError(err),
+			Equal([]string{"d", "a", "b"}, titles(result)),
+		)
+	})
+	t.Run("after update", func(t *testing.T) {
+		err := store.SetOne(ctx, "c", &Article{Title: "c", Body: "Rust and Go"})
+		Require(t,
+			NoError(err),
+		)
+		result, err := store.List(ctx,
+			Filter(Match("Body", "go rust")),
+			Order(By("Title")),
+		)
+		Expect(t,
+			NoError(err),
+			Equal([]string{"c", "d"}, titles(result)),
+		)
+	})
+	t.Run("empty query", func(t *testing.T) {
+		_, err := store.List(ctx, Filter(Match("Body", " ?! ")))
+		Expect(t,
+			Equal(true, err != nil),
+		)
+	})
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"testing"
+
+	//lint:ignore ST1001 shared definitions
+	. "github.com/ArnaudCalmettes/store"
+	//lint:ignore ST1001 test vocabulary
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+type Status string
+
+// Amount is compared with its Compare method by stores that filter in Go,
+// and through its integer value by sql stores.
+type Amount struct {
+	Cents int64
+}
+
+func (a Amount) Compare(other Amount) int {
+	switch {
+	case a.Cents < other.Cents:
+		return -1
+	case a.Cents > other.Cents:
+		return 1
+	}
+	return 0
+}
+
+func (a Amount) Value() (driver.Value, error) {
+	return a.Cents, nil
+}
+
+func (a *Amount) Scan(src any) error {
+	cents, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("can't scan %T into Amount", src)
+	}
+	a.Cents = cents
+	return nil
+}
+
+type Product struct {
+	Name   string
+	Status Status
+	Stock  *int
+	Weight uint16
+	Price  Amount `bun:",type:bigint"`
+}
+
+func TestKindFilters[S TestListerInterface[Product]](t *testing.T, newStore func(*testing.T) S) {
+	store := newStore(t)
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	stock := func(n int) *int { return &n }
+	err := store.SetMany(ctx, map[string]*Product{
+		"a": {Name: "a", Status: "active", Stock: stock(10), Weight: 300, Price: Amount{1299}},
+		"b": {Name: "b", Status: "archived", Stock: stock(0), Weight: 20, Price: Amount{499}},
+		"c": {Name: "c", Status: "active", Weight: 5, Price: Amount{10000}},
+	})
+	Require(t,
+		NoError(err),
+	)
+	names := func(products []*Product) []string {
+		result := make([]string, len(products))
+		for i, p := range products {
+			result[i] = p.Name
+		}
+		return result
+	}
+
+	testCases := []struct {
+		Name   string
+		Filter *FilterSpec
+		Expect []string
+	}{
+		{"named type with string", Where("Status", "=", "active"), []string{"a", "c"}},
+		{"named type", Where("Status", "!=", Status("active")), []string{"b"}},
+		{"pointer with value", Where("Stock", ">", 5), []string{"a"}},
+		{"pointer with pointer", Where("Stock", "=", stock(0)), []string{"b"}},
+		{"nil pointer", Where("Stock", "=", nil), []string{"c"}},
+		{"small unsigned", Where("Weight", ">=", uint8(20)), []string{"a", "b"}},
+		{"custom comparable", Where("Price", "<", Amount{1300}), []string{"a", "b"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			result, err := store.List(ctx, Filter(tc.Filter), Order(By("Name")))
+			Expect(t,
+				NoError(err),
+				Equal(tc.Expect, names(result)),
+			)
+		})
+	}
+	t.Run("order by named type", func(t *testing.T) {
+		result, err := store.List(ctx, Order(By("Status").Desc()), Limit(1))
+		Expect(t,
+			NoError(err),
+			Equal([]string{"b"}, names(result)),
+		)
+	})
+	t.Run("order by custom comparable", func(t *testing.T) {
+		result, err := store.List(ctx, Order(By("Price")))
+		Expect(t,
+			NoError(err),
+			Equal([]string{"b", "a", "c"}, names(result)),
+		)
+	})
+	t.Run("order by pointer", func(t *testing.T) {
+		result, err := store.List(ctx, Filter(Where("Stock", "!=", nil)), Order(By("Stock")))
+		Expect(t,
+			NoError(err),
+			Equal([]string{"b", "a"}, names(result)),
+		)
+	})
+}
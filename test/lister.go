@@ -40,9 +40,7 @@ type Person struct {
 	Referent *string
 }
 
-type listerConstructor func(*testing.T) TestListerInterface[Person]
-
-func TestLister(t *testing.T, newLister listerConstructor) {
+func TestLister[S TestListerInterface[Person]](t *testing.T, newLister func(*testing.T) S) {
 	store := newLister(t)
 	ctx, cancel := NewTestContext()
 	defer cancel()
@@ -0,0 +1,221 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ArnaudCalmettes/store/lock"
+	//lint:ignore ST1001 test vocabulary
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+	"github.com/google/uuid"
+)
+
+type lockerConstructor = func(*testing.T) lock.Locker
+
+// TestLocker runs the conformance suite of lock.Locker implementations.
+// elapse is called whenever the suite needs leases to age by given duration.
+func TestLocker(t *testing.T, newLocker lockerConstructor, elapse func(time.Duration)) {
+	type TestFunc = func(*testing.T, lockerConstructor, func(time.Duration))
+	run := func(t *testing.T, name string, testFunc TestFunc) {
+		t.Run(name, func(t *testing.T) {
+			testFunc(t, newLocker, elapse)
+		})
+	}
+	run(t, "Acquire", testLockerAcquire)
+	run(t, "Renew", testLockerRenew)
+	run(t, "Release", testLockerRelease)
+	run(t, "Expire", testLockerExpire)
+}
+
+func testLockerAcquire(t *testing.T, newLocker lockerConstructor, _ func(time.Duration)) {
+	locker := newLocker(t)
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	t.Run("empty name", func(t *testing.T) {
+		lease, err := locker.Acquire(ctx, "", time.Second)
+		Expect(t,
+			IsNilPointer(lease),
+			IsError(lock.ErrEmptyName, err),
+		)
+	})
+	t.Run("invalid ttl", func(t *testing.T) {
+		lease, err := locker.Acquire(ctx, uuid.NewString(), 0)
+		Expect(t,
+			IsNilPointer(lease),
+			IsError(lock.ErrInvalidTTL, err),
+		)
+	})
+	t.Run("nominal", func(t *testing.T) {
+		name := uuid.NewString()
+		lease, err := locker.Acquire(ctx, name, time.Minute)
+		Require(t,
+			NoError(err),
+			IsNotNilPointer(lease),
+		)
+		Expect(t,
+			Equal(name, lease.Name),
+			IsNotZero(lease.Owner),
+			Equal(int64(1), lease.Token),
+		)
+	})
+	t.Run("already locked", func(t *testing.T) {
+		name := uuid.NewString()
+		_, err := locker.Acquire(ctx, name, time.Minute)
+		Require(t,
+			NoError(err),
+		)
+
+		lease, err := locker.Acquire(ctx, name, time.Minute)
+		Expect(t,
+			IsNilPointer(lease),
+			IsError(lock.ErrLocked, err),
+		)
+	})
+}
+
+func testLockerRenew(t *testing.T, newLocker lockerConstructor, elapse func(time.Duration)) {
+	locker := newLocker(t)
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	t.Run("invalid ttl", func(t *testing.T) {
+		lease, err := locker.Acquire(ctx, uuid.NewString(), time.Minute)
+		Require(t,
+			NoError(err),
+		)
+		err = locker.Renew(ctx, lease, -time.Second)
+		Expect(t,
+			IsError(lock.ErrInvalidTTL, err),
+		)
+	})
+	t.Run("nominal", func(t *testing.T) {
+		name := uuid.NewString()
+		lease, err := locker.Acquire(ctx, name, 200*time.Millisecond)
+		Require(t,
+			NoError(err),
+		)
+		elapse(120 * time.Millisecond)
+		err = locker.Renew(ctx, lease, time.Minute)
+		Require(t,
+			NoError(err),
+		)
+		elapse(120 * time.Millisecond)
+
+		_, err = locker.Acquire(ctx, name, time.Minute)
+		Expect(t,
+			IsErrorf(lock.ErrLocked, err, "renewed lease should still be held"),
+		)
+	})
+	t.Run("not held", func(t *testing.T) {
+		lease, err := locker.Acquire(ctx, uuid.NewString(), time.Minute)
+		Require(t,
+			NoError(err),
+		)
+		err = locker.Release(ctx, lease)
+		Require(t,
+			NoError(err),
+		)
+
+		err = locker.Renew(ctx, lease, time.Minute)
+		Expect(t,
+			IsError(lock.ErrNotHeld, err),
+		)
+	})
+}
+
+func testLockerRelease(t *testing.T, newLocker lockerConstructor, _ func(time.Duration)) {
+	locker := newLocker(t)
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	t.Run("nominal", func(t *testing.T) {
+		name := uuid.NewString()
+		first, err := locker.Acquire(ctx, name, time.Minute)
+		Require(t,
+			NoError(err),
+		)
+		err = locker.Release(ctx, first)
+		Require(t,
+			NoError(err),
+		)
+
+		second, err := locker.Acquire(ctx, name, time.Minute)
+		Require(t,
+			NoError(err),
+		)
+		Expect(t,
+			Equalf(first.Token+1, second.Token, "fencing token should increase"),
+		)
+	})
+	t.Run("twice", func(t *testing.T) {
+		lease, err := locker.Acquire(ctx, uuid.NewString(), time.Minute)
+		Require(t,
+			NoError(err),
+		)
+		err = locker.Release(ctx, lease)
+		Require(t,
+			NoError(err),
+		)
+
+		err = locker.Release(ctx, lease)
+		Expect(t,
+			IsError(lock.ErrNotHeld, err),
+		)
+	})
+	t.Run("not owner", func(t *testing.T) {
+		lease, err := locker.Acquire(ctx, uuid.NewString(), time.Minute)
+		Require(t,
+			NoError(err),
+		)
+
+		impostor := *lease
+		impostor.Owner = uuid.NewString()
+		err = locker.Release(ctx, &impostor)
+		Expect(t,
+			IsError(lock.ErrNotHeld, err),
+		)
+	})
+}
+
+func testLockerExpire(t *testing.T, newLocker lockerConstructor, elapse func(time.Duration)) {
+	locker := newLocker(t)
+	ctx, cancel := NewTestContext()
+	defer cancel()
+
+	name := uuid.NewString()
+	stale, err := locker.Acquire(ctx, name, 50*time.Millisecond)
+	Require(t,
+		NoError(err),
+	)
+	elapse(100 * time.Millisecond)
+
+	lease, err := locker.Acquire(ctx, name, time.Minute)
+	Require(t,
+		NoErrorf(err, "expired lease should be acquirable"),
+	)
+	Expect(t,
+		Equalf(stale.Token+1, lease.Token, "fencing token should increase"),
+		IsError(lock.ErrNotHeld, locker.Renew(ctx, stale, time.Minute)),
+		IsError(lock.ErrNotHeld, locker.Release(ctx, stale)),
+	)
+}
@@ -0,0 +1,69 @@
+// Code generated by storegen; DO NOT EDIT.
+
+package test
+
+import (
+	"cmp"
+	"time"
+
+	"github.com/ArnaudCalmettes/store/fields"
+)
+
+func init() {
+	fields.Register(&fields.Accessors[Person]{
+		Getters: map[string]any{
+			"ID":       func(obj *Person) string { return obj.ID },
+			"Name":     func(obj *Person) string { return obj.Name },
+			"Age":      func(obj *Person) int { return obj.Age },
+			"Referent": func(obj *Person) *string { return obj.Referent },
+		},
+		Comparators: map[string]func(a, b *Person) int{
+			"ID":   func(a, b *Person) int { return cmp.Compare(a.ID, b.ID) },
+			"Name": func(a, b *Person) int { return cmp.Compare(a.Name, b.Name) },
+			"Age":  func(a, b *Person) int { return cmp.Compare(a.Age, b.Age) },
+		},
+		NilCheckers: map[string]func(*Person) bool{
+			"Referent": func(obj *Person) bool { return obj.Referent == nil },
+		},
+	})
+	fields.Register(&fields.Accessors[Document]{
+		Getters: map[string]any{
+			"Title":     func(obj *Document) string { return obj.Title },
+			"CreatedAt": func(obj *Document) time.Time { return obj.CreatedAt },
+			"UpdatedAt": func(obj *Document) time.Time { return obj.UpdatedAt },
+			"Version":   func(obj *Document) int { return obj.Version },
+		},
+		Comparators: map[string]func(a, b *Document) int{
+			"Title":     func(a, b *Document) int { return cmp.Compare(a.Title, b.Title) },
+			"CreatedAt": func(a, b *Document) int { return a.CreatedAt.Compare(b.CreatedAt) },
+			"UpdatedAt": func(a, b *Document) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+			"Version":   func(a, b *Document) int { return cmp.Compare(a.Version, b.Version) },
+		},
+		NilCheckers: map[string]func(*Document) bool{},
+	})
+	fields.Register(&fields.Accessors[Article]{
+		Getters: map[string]any{
+			"Title": func(obj *Article) string { return obj.Title },
+			"Body":  func(obj *Article) string { return obj.Body },
+		},
+		Comparators: map[string]func(a, b *Article) int{
+			"Title": func(a, b *Article) int { return cmp.Compare(a.Title, b.Title) },
+			"Body":  func(a, b *Article) int { return cmp.Compare(a.Body, b.Body) },
+		},
+		NilCheckers: map[string]func(*Article) bool{},
+	})
+	fields.Register(&fields.Accessors[Product]{
+		Getters: map[string]any{
+			"Name":   func(obj *Product) string { return obj.Name },
+			"Stock":  func(obj *Product) *int { return obj.Stock },
+			"Weight": func(obj *Product) uint16 { return obj.Weight },
+		},
+		Comparators: map[string]func(a, b *Product) int{
+			"Name":   func(a, b *Product) int { return cmp.Compare(a.Name, b.Name) },
+			"Weight": func(a, b *Product) int { return cmp.Compare(a.Weight, b.Weight) },
+		},
+		NilCheckers: map[string]func(*Product) bool{
+			"Stock": func(obj *Product) bool { return obj.Stock == nil },
+		},
+	})
+}
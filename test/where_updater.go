@@ -0,0 +1,149 @@
+// Copyright (c) 2024 nohar
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"errors"
+	"testing"
+
+	//lint:ignore ST1001 shared definitions
+	. "github.com/ArnaudCalmettes/store"
+	//lint:ignore ST1001 test vocabulary
+	. "github.com/ArnaudCalmettes/store/test/helpers"
+)
+
+type TestWhereUpdaterInterface[T any] interface {
+	BaseKeyValueStore[T]
+	WhereUpdater[T]
+}
+
+func TestWhereUpdater[S TestWhereUpdaterInterface[Person]](t *testing.T, newStore func(*testing.T) S) {
+	fixture := func() map[string]*Person {
+		return map[string]*Person{
+			"001": {ID: "001", Name: "John Doe", Age: 42},
+			"002": {ID: "002", Name: "Willard", Age: 13},
+			"003": {ID: "003", Name: "Jane Smith", Age: 20},
+		}
+	}
+	birthday := func(_ string, p *Person) (*Person, error) {
+		p.Age++
+		return p, nil
+	}
+	t.Run("invalid filter", func(t *testing.T) {
+		store := newStore(t)
+		ctx, cancel := NewTestContext()
+		defer cancel()
+
+		count, err := store.UpdateWhere(ctx, Where("BankAccount", "!=", 42), birthday)
+		Expect(t,
+			IsError(ErrInvalidFilter, err),
+			Equal(0, count),
+		)
+	})
+	t.Run("nominal", func(t *testing.T) {
+		store := newStore(t)
+		ctx, cancel := NewTestContext()
+		defer cancel()
+		err := store.SetMany(ctx, fixture())
+		Require(t,
+			NoError(err),
+		)
+
+		count, err := store.UpdateWhere(ctx, Where("Age", ">=", 18), birthday)
+		Expect(t,
+			NoError(err),
+			Equal(2, count),
+		)
+		all, err := store.GetAll(ctx)
+		Expect(t,
+			NoError(err),
+			Equal(
+				map[string]*Person{
+					"001": {ID: "001", Name: "John Doe", Age: 43},
+					"002": {ID: "002", Name: "Willard", Age: 13},
+					"003": {ID: "003", Name: "Jane Smith", Age: 21},
+				},
+				all,
+			),
+		)
+	})
+	t.Run("callback returns nil", func(t *testing.T) {
+		store := newStore(t)
+		ctx, cancel := NewTestContext()
+		defer cancel()
+		err := store.SetMany(ctx, fixture())
+		Require(t,
+			NoError(err),
+		)
+
+		count, err := store.UpdateWhere(ctx, Where("Age", ">=", 18),
+			func(key string, p *Person) (*Person, error) {
+				if key == "001" {
+					return nil, nil
+				}
+				return birthday(key, p)
+			},
+		)
+		Expect(t,
+			NoError(err),
+			Equal(1, count),
+		)
+		all, err := store.GetAll(ctx)
+		Expect(t,
+			NoError(err),
+			Equal(
+				map[string]*Person{
+					"001": {ID: "001", Name: "John Doe", Age: 42},
+					"002": {ID: "002", Name: "Willard", Age: 13},
+					"003": {ID: "003", Name: "Jane Smith", Age: 21},
+				},
+				all,
+			),
+		)
+	})
+	t.Run("callback returns error", func(t *testing.T) {
+		store := newStore(t)
+		ctx, cancel := NewTestContext()
+		defer cancel()
+		err := store.SetMany(ctx, fixture())
+		Require(t,
+			NoError(err),
+		)
+
+		errUpdate := errors.New("update error")
+		count, err := store.UpdateWhere(ctx, Where("Age", ">=", 18),
+			func(key string, p *Person) (*Person, error) {
+				if key == "003" {
+					return nil, errUpdate
+				}
+				return birthday(key, p)
+			},
+		)
+		Expect(t,
+			IsError(errUpdate, err),
+			Equal(0, count),
+		)
+		all, err := store.GetAll(ctx)
+		Expect(t,
+			NoError(err),
+			Equalf(fixture(), all, "no entry should have been updated"),
+		)
+	})
+}